@@ -0,0 +1,198 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The exported API is recorded in testdata/api.txt, so that changing it has
+// to be done on purpose. After an intended change, run TestAPI with
+// -update-api and commit the new file along with the change.
+var updateAPI = flag.Bool("update-api", false, "rewrite testdata/api.txt")
+
+const apiFile = "testdata/api.txt"
+
+// TestAPI fails if the exported declarations in any build of the package
+// differ from testdata/api.txt. Exported names must be the same in the GPU
+// and stub builds, so every file is read whatever its build tags.
+func TestAPI(t *testing.T) {
+	api, err := exportedAPI(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updateAPI {
+		err = os.MkdirAll(filepath.Dir(apiFile), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(apiFile, []byte(api), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(apiFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api == string(expected) {
+		return
+	}
+	have := toSet(api)
+	want := toSet(string(expected))
+	for line := range want {
+		if !have[line] {
+			t.Errorf("Removed or changed: %v", line)
+		}
+	}
+	for line := range have {
+		if !want[line] {
+			t.Errorf("Added: %v", line)
+		}
+	}
+	t.Log("If these changes are intended, run go test -run TestAPI -update-api")
+}
+
+// exportedAPI returns one line for each exported declaration in the package
+// in dir, sorted
+func exportedAPI(dir string) (string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return "", err
+	}
+	lines := make(map[string]bool)
+	print := func(node interface{}) string {
+		var buf bytes.Buffer
+		_ = printer.Fprint(&buf, fset, node)
+		return strings.Join(strings.Fields(buf.String()), " ")
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if !d.Name.IsExported() || !exportedRecv(d.Recv) {
+						continue
+					}
+					d.Body = nil
+					d.Doc = nil
+					if d.Recv != nil {
+						d.Recv = unnamed(d.Recv)
+					}
+					lines[print(withoutNames(d))] = true
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							if s.Name.IsExported() {
+								s.Doc, s.Comment = nil, nil
+								lines["type "+print(withoutNames(exportedOnly(s)))] = true
+							}
+						case *ast.ValueSpec:
+							for _, name := range s.Names {
+								if name.IsExported() {
+									lines[d.Tok.String()+" "+name.Name] = true
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	sorted := make([]string, 0, len(lines))
+	for line := range lines {
+		sorted = append(sorted, line)
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\n") + "\n", nil
+}
+
+// exportedRecv returns true for functions and for methods on exported types
+func exportedRecv(recv *ast.FieldList) bool {
+	if recv == nil || len(recv.List) == 0 {
+		return true
+	}
+	typ := recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	ident, ok := typ.(*ast.Ident)
+	return ok && ident.IsExported()
+}
+
+// exportedOnly drops a struct's unexported fields, which aren't part of the
+// API, and field comments
+func exportedOnly(s *ast.TypeSpec) *ast.TypeSpec {
+	st, ok := s.Type.(*ast.StructType)
+	if !ok {
+		return s
+	}
+	fields := &ast.FieldList{}
+	for _, f := range st.Fields.List {
+		var names []*ast.Ident
+		for _, name := range f.Names {
+			if name.IsExported() {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			fields.List = append(fields.List, &ast.Field{Names: names, Type: f.Type})
+		}
+	}
+	return &ast.TypeSpec{Name: s.Name, Type: &ast.StructType{Fields: fields}}
+}
+
+// withoutNames drops the names of parameters and results from every function
+// type in node, since renaming them doesn't change the API
+func withoutNames(node ast.Node) ast.Node {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if f, ok := n.(*ast.FuncType); ok {
+			f.Params = unnamed(f.Params)
+			f.Results = unnamed(f.Results)
+		}
+		return true
+	})
+	return node
+}
+
+// unnamed returns fields with one unnamed field for each name in fields
+func unnamed(fields *ast.FieldList) *ast.FieldList {
+	if fields == nil {
+		return nil
+	}
+	result := &ast.FieldList{}
+	for _, f := range fields.List {
+		for i := 0; i < len(f.Names) || i == 0; i++ {
+			result.List = append(result.List, &ast.Field{Type: f.Type})
+		}
+	}
+	return result
+}
+
+func toSet(lines string) map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(lines), "\n") {
+		set[line] = true
+	}
+	return set
+}
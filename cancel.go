@@ -0,0 +1,64 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"strings"
+	"sync"
+)
+
+// cancel.go keeps the tags whose work has been cancelled, so that when a
+// round fails elsewhere in the network, its remaining batches don't hold up
+// the streams.
+
+// How many cancelled tags are remembered. Tags are usually round IDs, and a
+// round that was cancelled this long ago won't be sending more work.
+const maxCancelledTags = 1024
+
+type cancelledTags struct {
+	sync.RWMutex
+	tags map[string]bool
+	// Oldest first, for forgetting them
+	order []string
+}
+
+func newCancelledTags() *cancelledTags {
+	return &cancelledTags{tags: make(map[string]bool)}
+}
+
+func (c *cancelledTags) add(tag string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.tags[tag] {
+		return
+	}
+	c.tags[tag] = true
+	c.order = append(c.order, tag)
+	if len(c.order) > maxCancelledTags {
+		delete(c.tags, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// has returns true if any of tags, as joined by SetTags, was cancelled
+func (c *cancelledTags) has(tags string) bool {
+	if tags == "" {
+		return false
+	}
+	c.RLock()
+	defer c.RUnlock()
+	if len(c.tags) == 0 {
+		return false
+	}
+	for _, tag := range strings.Split(tags, ", ") {
+		if c.tags[tag] {
+			return true
+		}
+	}
+	return false
+}
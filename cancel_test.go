@@ -0,0 +1,34 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Any one of a batch's tags being cancelled should cancel it
+func TestCancelledTags(t *testing.T) {
+	c := newCancelledTags()
+	if c.has("round 7, realtime") {
+		t.Error("Nothing has been cancelled yet")
+	}
+	c.add("round 7")
+	if !c.has("round 7, realtime") || !c.has("round 7") {
+		t.Error("Batches tagged round 7 should be cancelled")
+	}
+	if c.has("round 8, realtime") || c.has("") || c.has("round 70") {
+		t.Error("Only batches tagged round 7 should be cancelled")
+	}
+	for i := 0; i < maxCancelledTags; i++ {
+		c.add(fmt.Sprint("round ", 100+i))
+	}
+	if c.has("round 7") {
+		t.Error("The oldest tag should have been forgotten")
+	}
+}
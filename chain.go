@@ -0,0 +1,162 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// chain.go builds a sequence of ops on a running value for every slot, like
+//   NewChain(g).Powm(x, y).Mul(z).Inverse().Run(p, result)
+// and runs it with as few kernels as it can. Runs of multiplications are
+// folded into mul3 kernels, which take two factors and the running value at
+// once. Steps that have no kernel, like Inverse, run on the CPU in between.
+
+type chainOp int
+
+const (
+	chainPowm chainOp = iota
+	chainMul
+	chainInverse
+)
+
+type chainStep struct {
+	op   chainOp
+	x, y *cyclic.IntBuffer
+}
+
+// Chain is a sequence of ops to run on a value for every slot. The value
+// starts out as 1.
+type Chain struct {
+	g     *cyclic.Group
+	steps []chainStep
+}
+
+// NewChain returns an empty chain of ops in g
+func NewChain(g *cyclic.Group) *Chain {
+	return &Chain{g: g}
+}
+
+// Powm multiplies the value by x**y
+func (c *Chain) Powm(x, y *cyclic.IntBuffer) *Chain {
+	c.steps = append(c.steps, chainStep{op: chainPowm, x: x, y: y})
+	return c
+}
+
+// Mul multiplies the value by x
+func (c *Chain) Mul(x *cyclic.IntBuffer) *Chain {
+	c.steps = append(c.steps, chainStep{op: chainMul, x: x})
+	return c
+}
+
+// Inverse replaces the value with its inverse. There's no inverse kernel,
+// so this always runs on the CPU.
+func (c *Chain) Inverse() *Chain {
+	c.steps = append(c.steps, chainStep{op: chainInverse})
+	return c
+}
+
+// Run runs the chain on p and puts the value for each slot in result. Every
+// operand must have as many slots as result.
+func (c *Chain) Run(p *StreamPool, result *cyclic.IntBuffer) error {
+	buffers := []intGetter{result}
+	for _, s := range c.steps {
+		if s.x != nil {
+			buffers = append(buffers, s.x)
+		}
+		if s.y != nil {
+			buffers = append(buffers, s.y)
+		}
+	}
+	err := checkLengths("Chain", buffers...)
+	if err != nil {
+		return err
+	}
+
+	r := chainRun{p: p, g: c.g, result: result, isOne: true}
+	for i, s := range c.steps {
+		switch s.op {
+		case chainPowm:
+			if r.isOne && len(r.factors) == 0 {
+				_, err = ExpChunk(p, c.g, s.x, s.y, result)
+				r.isOne = false
+			} else {
+				power := c.g.NewIntBuffer(uint32(result.Len()), c.g.NewInt(1))
+				_, err = ExpChunk(p, c.g, s.x, s.y, power)
+				r.factors = append(r.factors, power)
+			}
+		case chainMul:
+			r.factors = append(r.factors, s.x)
+		case chainInverse:
+			err = r.multiply()
+			if err == nil && !r.isOne {
+				for j := uint32(0); j < uint32(result.Len()); j++ {
+					c.g.Inverse(result.Get(j), result.Get(j))
+				}
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Chain: step %v", i)
+		}
+	}
+	err = r.multiply()
+	if err != nil {
+		return errors.Wrap(err, "Chain")
+	}
+	if r.isOne {
+		for j := uint32(0); j < uint32(result.Len()); j++ {
+			c.g.Set(result.Get(j), c.g.NewInt(1))
+		}
+	}
+	return nil
+}
+
+// chainRun is the state of a chain while it runs
+type chainRun struct {
+	p      *StreamPool
+	g      *cyclic.Group
+	result *cyclic.IntBuffer
+	// Whether result still holds nothing, and the value is 1
+	isOne bool
+	// Factors that haven't been multiplied into result yet
+	factors []*cyclic.IntBuffer
+}
+
+// multiply multiplies every waiting factor into result, two or three at a
+// time
+func (r *chainRun) multiply() error {
+	for len(r.factors) > 0 {
+		var err error
+		f := r.factors
+		switch {
+		case r.isOne && len(f) >= 3:
+			err = Mul3Chunk(r.p, r.g, f[0], f[1], f[2], r.result)
+			r.factors = f[3:]
+		case r.isOne && len(f) == 2:
+			err = Mul2Chunk(r.p, r.g, f[0], f[1], r.result)
+			r.factors = f[2:]
+		case r.isOne:
+			for j := uint32(0); j < uint32(r.result.Len()); j++ {
+				r.g.Set(r.result.Get(j), f[0].Get(j))
+			}
+			r.factors = f[1:]
+		case len(f) >= 2:
+			err = Mul3Chunk(r.p, r.g, f[0], f[1], r.result, r.result)
+			r.factors = f[2:]
+		default:
+			err = Mul2Chunk(r.p, r.g, f[0], r.result, r.result)
+			r.factors = f[1:]
+		}
+		if err != nil {
+			return err
+		}
+		r.isOne = false
+	}
+	return nil
+}
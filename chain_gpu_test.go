@@ -0,0 +1,42 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"testing"
+)
+
+// A chain run by the real chunk functions should give the right values for
+// the slots they send to the CPU. Multiplying into a result that already
+// holds a value passes it as an operand and the output at once.
+func TestChain_RunOnCPU(t *testing.T) {
+	const batchSize = 8
+	g := makeTestGroup2048()
+	x := initRandomIntBuffer(g, batchSize, 42, 0)
+	y := initRandomIntBuffer(g, batchSize, 43, 32)
+	a := initRandomIntBuffer(g, batchSize, 44, 0)
+	b := initRandomIntBuffer(g, batchSize, 45, 0)
+	result := g.NewIntBuffer(batchSize, g.NewInt(1))
+
+	pool := newCPUOnlyPool("ExpChunk", "Mul2Chunk", "Mul3Chunk")
+	err := NewChain(g).Powm(x, y).Mul(a).Mul(b).Inverse().Run(pool, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		expected := g.Exp(x.Get(i), y.Get(i), g.NewInt(1))
+		g.Mul(expected, a.Get(i), expected)
+		g.Mul(expected, b.Get(i), expected)
+		g.Inverse(expected, expected)
+		if result.Get(i).Cmp(expected) != 0 {
+			t.Errorf("Slot %v doesn't match doing each step by hand", i)
+		}
+	}
+}
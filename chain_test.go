@@ -0,0 +1,98 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// cpuOps swaps the ops a chain runs for CPU versions that count their
+// calls, and returns a function that puts the originals back
+func cpuOps(calls map[string]int) func() {
+	exp, mul2, mul3 := ExpChunk, Mul2Chunk, Mul3Chunk
+	ExpChunk = func(p *StreamPool, g *cyclic.Group,
+		x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
+		calls["ExpChunk"]++
+		for i := uint32(0); i < uint32(z.Len()); i++ {
+			g.Exp(x.Get(i), y.Get(i), z.Get(i))
+		}
+		return z, nil
+	}
+	Mul2Chunk = func(p *StreamPool, g *cyclic.Group,
+		x, y, result *cyclic.IntBuffer) error {
+		calls["Mul2Chunk"]++
+		for i := uint32(0); i < uint32(result.Len()); i++ {
+			g.Mul(x.Get(i), y.Get(i), result.Get(i))
+		}
+		return nil
+	}
+	Mul3Chunk = func(p *StreamPool, g *cyclic.Group,
+		x, y, z, result *cyclic.IntBuffer) error {
+		calls["Mul3Chunk"]++
+		for i := uint32(0); i < uint32(result.Len()); i++ {
+			xy := g.Mul(x.Get(i), y.Get(i), g.NewInt(1))
+			g.Mul(xy, z.Get(i), result.Get(i))
+		}
+		return nil
+	}
+	return func() { ExpChunk, Mul2Chunk, Mul3Chunk = exp, mul2, mul3 }
+}
+
+// A chain should give the same values as running each step by hand, and
+// fold its multiplications into as few kernels as it can
+func TestChain_Run(t *testing.T) {
+	calls := make(map[string]int)
+	defer cpuOps(calls)()
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 2, 3)
+	y := newDedupBuffer(g, 5, 7)
+	a := newDedupBuffer(g, 11, 13)
+	b := newDedupBuffer(g, 17, 19)
+	result := g.NewIntBuffer(2, g.NewInt(1))
+
+	err := NewChain(g).Powm(x, y).Mul(a).Mul(b).Inverse().Run(nil, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < 2; i++ {
+		expected := g.Exp(x.Get(i), y.Get(i), g.NewInt(1))
+		g.Mul(expected, a.Get(i), expected)
+		g.Mul(expected, b.Get(i), expected)
+		g.Inverse(expected, expected)
+		if result.Get(i).Cmp(expected) != 0 {
+			t.Errorf("Slot %v was %v, expected %v", i,
+				result.Get(i).Text(10), expected.Text(10))
+		}
+	}
+	if calls["ExpChunk"] != 1 || calls["Mul3Chunk"] != 1 ||
+		calls["Mul2Chunk"] != 0 {
+		t.Errorf("Expected one exp and one mul3 kernel, got %v", calls)
+	}
+}
+
+// A chain with nothing to multiply should leave 1 in every slot
+func TestChain_Empty(t *testing.T) {
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	result := newDedupBuffer(g, 5, 6)
+	err := NewChain(g).Inverse().Run(nil, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < 2; i++ {
+		if result.Get(i).Cmp(g.NewInt(1)) != 0 {
+			t.Errorf("Slot %v was %v, expected 1", i, result.Get(i).Text(10))
+		}
+	}
+
+	err = NewChain(g).Mul(newDedupBuffer(g, 2)).Run(nil, result)
+	if _, ok := err.(ErrSizeMismatch); !ok {
+		t.Errorf("Expected ErrSizeMismatch for a short operand, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import "errors"
+
+// Client is stubbed unless GPU is present.
+func (sm *StreamPool) Client(name string, quota int) (*StreamPool, error) {
+	return nil, errors.New(NoGpuErrStr)
+}
@@ -0,0 +1,70 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"time"
+	"unsafe"
+)
+
+// client_gpu.go lets subsystems such as precomputation, realtime and key
+// generation share one pool, each with a limit on how many of its streams
+// they can hold at once. Streams that are free go to waiting callers in the
+// order they asked, earliest deadline first, so with every subsystem under
+// a quota, none of them can keep the others waiting for long.
+
+// Client returns a pool for one subsystem, named name, that takes its
+// streams from this pool but never holds more than quota of them at once.
+// Passing it to the chunk functions runs their batches on this pool's
+// streams, tagged with the client's name. Failing streams are still taken
+// out of rotation in this pool. The client has no streams of its own, so it
+// can't be destroyed or reloaded, and round sessions can't be made from it.
+func (sm *StreamPool) Client(name string, quota int) (*StreamPool, error) {
+	numStreams := sm.numStreams()
+	if quota <= 0 || quota > numStreams {
+		return nil, errors.Errorf("can't give client %v a quota of %v "+
+			"streams from a pool with %v streams", name, quota, numStreams)
+	}
+	return &StreamPool{
+		parent:         sm,
+		quota:          make(chan struct{}, quota),
+		memSize:        sm.memSize,
+		throughput:     sm.throughput,
+		governor:       sm.governor,
+		estimates:      sm.estimates,
+		failures:       make(map[unsafe.Pointer]int),
+		disabled:       make(map[unsafe.Pointer]bool),
+		borrowed:       true,
+		deadlineMisses: sm.deadlineMisses,
+		cancelled:      sm.cancelled,
+		blindExponents: sm.blindExponents,
+		dedupSlots:     sm.dedupSlots,
+		tags:           name,
+	}, nil
+}
+
+// takeFromParent waits for the client to be under its quota, then takes a
+// stream from the pool it's a client of
+func (sm *StreamPool) takeFromParent(deadline time.Time,
+	exclude unsafe.Pointer) Stream {
+	sm.quota <- struct{}{}
+	s := sm.parent.takeStream(deadline, exclude)
+	sm.waitLock.Lock()
+	s.tags = sm.tags
+	sm.waitLock.Unlock()
+	return s
+}
+
+// returnToParent gives a stream back to the pool the client took it from
+func (sm *StreamPool) returnToParent(s Stream, dirty bool) {
+	sm.parent.returnStream(s, dirty)
+	<-sm.quota
+}
@@ -0,0 +1,65 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"testing"
+	"time"
+)
+
+// A client at its quota should wait for one of its own streams to come back,
+// even if the pool has more free
+func TestStreamPool_Client(t *testing.T) {
+	pool := newDummyPool(3)
+	client, err := pool.Client("precomp", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := client.TakeStream()
+	if first.tags != "precomp" {
+		t.Errorf("Stream was tagged %q, expected the client's name", first.tags)
+	}
+	if !client.busy() {
+		t.Error("Client at its quota should be busy")
+	}
+	if pool.busy() {
+		t.Error("Pool shouldn't be busy with only one stream taken")
+	}
+
+	taken := make(chan Stream)
+	go func() {
+		taken <- client.TakeStream()
+	}()
+	select {
+	case <-taken:
+		t.Fatal("Client took more streams than its quota")
+	case <-time.After(50 * time.Millisecond):
+	}
+	client.ReturnStream(first)
+	second := <-taken
+	client.ReturnStream(second)
+	if len(pool.streamChan) != 3 {
+		t.Errorf("Expected all 3 streams back in the pool, got %v",
+			len(pool.streamChan))
+	}
+	if client.Destroy() == nil {
+		t.Error("Client shouldn't be able to destroy the pool's streams")
+	}
+}
+
+func TestStreamPool_ClientQuota(t *testing.T) {
+	pool := newDummyPool(2)
+	for _, quota := range []int{0, 3} {
+		_, err := pool.Client("keygen", quota)
+		if err == nil {
+			t.Errorf("Quota of %v should be rejected for a pool of 2", quota)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// bindgen reads powm_odd_export.h and writes the Go wrappers that call into
+// the library for each bit length it supports: enqueue, which uploads, runs
+// and downloads a batch, and the queries for each kernel's memory sizes.
+// Run it with go generate from the repository root after the library's
+// header changes.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/template"
+)
+
+var (
+	enqueueDecl = regexp.MustCompile(
+		`const\s+char\s*\*\s*enqueue(\d+)\s*\(`)
+	sizeDecl = regexp.MustCompile(
+		`size_t\s+get(Input|Output|Constants)Size(\d+)\s*\(`)
+)
+
+// bitLengths returns the bit lengths that the header has a complete set of
+// functions for, in ascending order. A bit length that's missing some of
+// them is an error, as the Go side needs all of them.
+func bitLengths(header []byte) ([]int, error) {
+	found := make(map[int]map[string]bool)
+	add := func(bitLen, function string) {
+		n, _ := strconv.Atoi(bitLen)
+		if found[n] == nil {
+			found[n] = make(map[string]bool)
+		}
+		found[n][function] = true
+	}
+	for _, m := range enqueueDecl.FindAllSubmatch(header, -1) {
+		add(string(m[1]), "enqueue")
+	}
+	for _, m := range sizeDecl.FindAllSubmatch(header, -1) {
+		add(string(m[2]), "get"+string(m[1])+"Size")
+	}
+
+	var lengths []int
+	for n, functions := range found {
+		for _, f := range []string{"enqueue", "getInputSize",
+			"getOutputSize", "getConstantsSize"} {
+			if !functions[f] {
+				return nil, fmt.Errorf("header has some functions for "+
+					"%v bits, but not %v%v", n, f, n)
+			}
+		}
+		lengths = append(lengths, n)
+	}
+	if len(lengths) == 0 {
+		return nil, fmt.Errorf("header doesn't have any enqueue functions")
+	}
+	sort.Ints(lengths)
+	return lengths, nil
+}
+
+var wrappers = template.Must(template.New("wrappers").Parse(
+	`///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// Code generated by bindgen from {{.Header}}. DO NOT EDIT.
+
+//+build linux,gpu
+
+package gpumaths
+
+/*
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#cgo LDFLAGS: -L/opt/xxnetwork/lib -lpowmosm75 -Wl,-rpath,./lib:/opt/xxnetwork/lib
+#include <powm_odd_export.h>
+*/
+import "C"
+{{range .BitLengths}}
+// Upload some items to the next stream, run the kernel, and download the
+// results, all together
+func (gpumaths{{.}}) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
+	debugCheckEnqueue(&gpumathsEnv{{.}}, stream, whichToRun, numSlots)
+	uploadError := C.enqueue{{.}}(C.uint(numSlots), stream.s, whichToRun)
+	if uploadError != nil {
+		return goError(uploadError)
+	} else {
+		return nil
+	}
+}
+
+func (g *gpumaths{{.}}) populateSizeData(kernel C.enum_kernel) {
+	input, output, constants := g.querySizes(kernel)
+	// If any size is zero, the library was built without the kernel at this
+	// bit length. The sizes stay zero, so envFor won't choose it and
+	// runKernel won't run it.
+	if input == 0 || output == 0 || constants == 0 {
+		return
+	}
+	g.sizeData[kernel].inputSize = input
+	g.sizeData[kernel].outputSize = output
+	g.sizeData[kernel].constantsSize = constants
+	g.sizeData.populateWordSizes(kernel)
+}
+
+func (gpumaths{{.}}) querySizes(kernel C.enum_kernel) (input, output, constants int) {
+	return int(C.getInputSize{{.}}(kernel)), int(C.getOutputSize{{.}}(kernel)),
+		int(C.getConstantsSize{{.}}(kernel))
+}
+{{end}}`))
+
+// generate returns the Go source of the wrappers for the header. The
+// template is already formatted the way gofmt wants, apart from the build
+// constraint, which is left in the form the rest of the package uses.
+func generate(headerName string, header []byte) ([]byte, error) {
+	lengths, err := bitLengths(header)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = wrappers.Execute(&buf, struct {
+		Header     string
+		BitLengths []int
+	}{headerName, lengths})
+	if err != nil {
+		return nil, err
+	}
+	// Catch mistakes in the template before they get written out
+	_, err = parser.ParseFile(token.NewFileSet(), "", buf.Bytes(),
+		parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func main() {
+	headerPath := flag.String("header",
+		"/opt/xxnetwork/include/powm_odd_export.h",
+		"path to the library's export header")
+	out := flag.String("out", "env_gen_gpu.go", "file to write")
+	flag.Parse()
+
+	header, err := ioutil.ReadFile(*headerPath)
+	if err == nil {
+		var src []byte
+		src, err = generate("powm_odd_export.h", header)
+		if err == nil {
+			err = ioutil.WriteFile(*out, src, 0644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bindgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const testHeader = `
+const char* enqueue4096(uint32_t numSlots, void *stream, enum kernel whichToRun);
+const char *enqueue2048(uint32_t numSlots, void *stream, enum kernel whichToRun);
+size_t getInputSize2048(enum kernel op);
+size_t getOutputSize2048(enum kernel op);
+size_t getConstantsSize2048(enum kernel op);
+size_t getInputSize4096(enum kernel op);
+size_t getOutputSize4096(enum kernel op);
+size_t getConstantsSize4096(enum kernel op);
+`
+
+// Every bit length with a full set of functions should be found, in order
+func TestBitLengths(t *testing.T) {
+	lengths, err := bitLengths([]byte(testHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lengths, []int{2048, 4096}) {
+		t.Errorf("Expected 2048 and 4096 bits, got %v", lengths)
+	}
+}
+
+// A bit length that's missing a function should be an error
+func TestBitLengths_Incomplete(t *testing.T) {
+	header := testHeader + "size_t getInputSize3200(enum kernel op);\n"
+	_, err := bitLengths([]byte(header))
+	if err == nil {
+		t.Error("Header without enqueue3200 should have been an error")
+	}
+	_, err = bitLengths(nil)
+	if err == nil {
+		t.Error("Header without any functions should have been an error")
+	}
+}
+
+// The generated source should have wrappers for each bit length
+func TestGenerate(t *testing.T) {
+	src, err := generate("test.h", []byte(testHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"func (gpumaths2048) enqueue(",
+		"func (g *gpumaths4096) populateSizeData(",
+		"C.getConstantsSize4096(kernel)",
+		"DO NOT EDIT",
+	} {
+		if !bytes.Contains(src, []byte(s)) {
+			t.Errorf("Generated source doesn't contain %q", s)
+		}
+	}
+}
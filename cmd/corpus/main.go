@@ -0,0 +1,96 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// corpus adds a slot from a dumped batch to the regression corpus in
+// testvectors. The dump is a batch frame, as written by MarshalBatch, for an
+// exp, mul2 or mul3 batch, with the op's operands first in the order the op
+// takes them. Run it from the repository root, and commit the corpus with the
+// fix for whatever the slot broke.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/elixxir/gpumathsgo/testvectors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Names of the ops the corpus has cases for
+var wireOpNames = map[gpumaths.WireOp]string{
+	gpumaths.WireExp:  "Exp",
+	gpumaths.WireMul2: "Mul2",
+	gpumaths.WireMul3: "Mul3",
+}
+
+// Bit lengths of the groups in RFC 3526 that testvectors has
+var rfc3526Sizes = map[uint32]bool{2048: true, 3072: true, 4096: true}
+
+// caseFromFrame makes a case called name from one slot of a batch frame
+func caseFromFrame(frame []byte, slot uint32, name,
+	note string) (testvectors.Case, error) {
+	// Cases are checked in the RFC 3526 group of their bit length, so the
+	// operands have to be in it
+	_, bitLen, err := gpumaths.PeekBatch(frame)
+	if err != nil {
+		return testvectors.Case{}, err
+	}
+	if !rfc3526Sizes[bitLen] {
+		return testvectors.Case{}, fmt.Errorf("the corpus has no %v bit "+
+			"group", bitLen)
+	}
+	op, bitLen, operands, err := gpumaths.UnmarshalBatch(
+		testvectors.RFC3526Group(int(bitLen)), frame)
+	if err != nil {
+		return testvectors.Case{}, err
+	}
+	opName, ok := wireOpNames[op]
+	if !ok {
+		return testvectors.Case{}, fmt.Errorf("the corpus has no cases "+
+			"for op %v", op)
+	}
+	numOperands := 2
+	if op == gpumaths.WireMul3 {
+		numOperands = 3
+	}
+	if len(operands) < numOperands {
+		return testvectors.Case{}, fmt.Errorf("%v batch has %v operands, "+
+			"but needs %v", opName, len(operands), numOperands)
+	}
+	if len(operands) > 0 && slot >= uint32(operands[0].Len()) {
+		return testvectors.Case{}, fmt.Errorf("batch has no slot %v", slot)
+	}
+	c := testvectors.CaseFromSlot(name, opName, int(bitLen),
+		operands[:numOperands], slot)
+	c.Note = note
+	return c, nil
+}
+
+func main() {
+	framePath := flag.String("frame", "", "path to the dumped batch frame")
+	slot := flag.Uint("slot", 0, "slot in the batch to add")
+	name := flag.String("name", "", "name for the new case")
+	note := flag.String("note", "", "where the case came from")
+	corpus := flag.String("corpus", filepath.Join("testvectors",
+		testvectors.CorpusFile), "corpus file to add to")
+	flag.Parse()
+
+	frame, err := ioutil.ReadFile(*framePath)
+	if err == nil {
+		var c testvectors.Case
+		c, err = caseFromFrame(frame, uint32(*slot), *name, *note)
+		if err == nil {
+			err = testvectors.AppendCase(*corpus, c)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "corpus:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,61 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/elixxir/gpumathsgo/testvectors"
+	"reflect"
+	"testing"
+)
+
+// A slot from a frame should become a case with that slot's operands, and
+// only the ones the op takes
+func TestCaseFromFrame(t *testing.T) {
+	g := testvectors.RFC3526Group(2048)
+	x := g.NewIntBuffer(2, g.NewInt(3))
+	y := g.NewIntBuffer(2, g.NewInt(4))
+	g.Set(y.Get(1), g.NewInt(9))
+	result := g.NewIntBuffer(2, g.NewInt(1))
+	frame, err := gpumaths.MarshalBatch(gpumaths.WireExp, 2048, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := caseFromFrame(frame, 1, "dump", "from a test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testvectors.Case{Name: "dump", Op: "Exp", Bits: 2048,
+		Operands: []string{"3", "9"}, Note: "from a test"}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Got %+v, expected %+v", c, expected)
+	}
+
+	_, err = caseFromFrame(frame, 2, "dump", "")
+	if err == nil {
+		t.Error("A slot past the end of the batch should be an error")
+	}
+	frame, err = gpumaths.MarshalBatch(gpumaths.WireReveal, 2048,
+		x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = caseFromFrame(frame, 0, "dump", "")
+	if err == nil {
+		t.Error("An op the corpus doesn't have should be an error")
+	}
+	frame, err = gpumaths.MarshalBatch(gpumaths.WireExp, 1024, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = caseFromFrame(frame, 0, "dump", "")
+	if err == nil {
+		t.Error("A bit length the corpus doesn't have should be an error")
+	}
+}
@@ -0,0 +1,83 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"sync"
+)
+
+// combine.go combines key shares from several nodes, for harnesses that
+// simulate a whole mix in one process. The library has no kernel that takes
+// more than one base and exponent per slot, so each node's share is a
+// separate exponentiation, but they run at the same time on different
+// streams instead of one after another.
+
+// CombineShares sets result[i] to the product over j of
+// bases[j][i]**exponents[j][i] mod p. Each of bases and exponents has one
+// buffer for each node, and every buffer must have as many slots as result.
+// Up to one share per stream in the pool is computed at once.
+func CombineShares(p *StreamPool, g *cyclic.Group, bases,
+	exponents []*cyclic.IntBuffer, result *cyclic.IntBuffer) error {
+	if len(bases) == 0 || len(bases) != len(exponents) {
+		return errors.Errorf("CombineShares: can't combine %v bases with %v "+
+			"exponents", len(bases), len(exponents))
+	}
+	buffers := []intGetter{result}
+	for j := range bases {
+		buffers = append(buffers, bases[j], exponents[j])
+	}
+	err := checkLengths("CombineShares", buffers...)
+	if err != nil {
+		return err
+	}
+
+	numSlots := uint32(result.Len())
+	shares := make([]*cyclic.IntBuffer, len(bases))
+	errs := make([]error, len(bases))
+	var wg sync.WaitGroup
+	for j := range bases {
+		shares[j] = g.NewIntBuffer(numSlots, g.NewInt(1))
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			_, errs[j] = ExpChunk(p, g, bases[j], exponents[j], shares[j])
+		}(j)
+	}
+	wg.Wait()
+	for j, err := range errs {
+		if err != nil {
+			return errors.Wrapf(err, "CombineShares: share %v", j)
+		}
+	}
+
+	// Multiply the shares together pairwise, halving their number each time
+	for len(shares) > 1 {
+		half := (len(shares) + 1) / 2
+		errs = make([]error, len(shares)/2)
+		for j := 0; j < len(shares)/2; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				errs[j] = Mul2Chunk(p, g, shares[j], shares[half+j], shares[j])
+			}(j)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return errors.Wrap(err, "CombineShares")
+			}
+		}
+		shares = shares[:half]
+	}
+	for i := uint32(0); i < numSlots; i++ {
+		g.Set(result.Get(i), shares[0].Get(i))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// Combining an odd number of shares should match doing it on the CPU
+func TestCombineShares(t *testing.T) {
+	const batchSize = 32
+	const numNodes = 5
+	grp := initTestGroup()
+	var bases, exponents []*cyclic.IntBuffer
+	for j := int64(0); j < numNodes; j++ {
+		bases = append(bases, initRandomIntBuffer(grp, batchSize, 2*j, 0))
+		exponents = append(exponents, initRandomIntBuffer(grp, batchSize, 2*j+1, 32))
+	}
+	expected := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	share := grp.NewInt(1)
+	for i := uint32(0); i < batchSize; i++ {
+		for j := range bases {
+			grp.Exp(bases[j].Get(i), exponents[j].Get(i), share)
+			grp.Mul(expected.Get(i), share, expected.Get(i))
+		}
+	}
+
+	streamPool, err := NewStreamPool(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	err = CombineShares(streamPool, grp, bases, exponents, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		if result.Get(i).Cmp(expected.Get(i)) != 0 {
+			t.Errorf("combined shares mismatch on index %d", i)
+		}
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,51 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/gpumathsgo/testvectors"
+	"path/filepath"
+	"testing"
+)
+
+// loadCorpus returns every case in the regression corpus
+func loadCorpus(t *testing.T) []testvectors.Case {
+	cases, err := testvectors.LoadCorpus(filepath.Join("testvectors",
+		testvectors.CorpusFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+// The CPU fallbacks the ops use should get every case in the corpus right
+func TestCorpus_CPU(t *testing.T) {
+	for _, c := range loadCorpus(t) {
+		v, err := c.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := v.Group
+		z := g.NewInt(1)
+		switch c.Op {
+		case "Exp":
+			cryptops.Exp(g, v.X.Get(0), v.Y.Get(0), z)
+		case "Mul2":
+			g.Set(z, v.Y.Get(0))
+			cryptops.Mul2(g, v.X.Get(0), z)
+		case "Mul3":
+			g.Set(z, v.Z.Get(0))
+			cryptops.Mul3(g, v.X.Get(0), g.NewIntFromLargeInt(
+				v.Y.Get(0).GetLargeInt()), z)
+		}
+		if z.Cmp(v.Expected.Get(0)) != 0 {
+			t.Errorf("%v: got %v", c.Name, z.Text(16))
+		}
+	}
+}
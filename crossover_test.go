@@ -0,0 +1,138 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// crossover_test.go times each op on the GPU and on the CPU over growing
+// batch sizes, and reports the smallest batch size at which the GPU wins on
+// this machine. It takes a while, so it only runs when GPUMATHS_CROSSOVER is
+// set in the environment. If GPUMATHS_CROSSOVER_TUNING names a tuning file,
+// the crossovers are saved into it, so a node started with that file as
+// Config.TuningFile runs smaller chunks on the CPU.
+
+// Largest batch size that the crossover report tries
+const maxCrossoverBatch = 4096
+
+// crossoverOp sets up inputs for a batch of an op of the given size, and
+// returns functions that run the batch on the GPU and on the CPU
+type crossoverOp func(t *testing.T, pool *StreamPool, batchSize uint32) (gpu, cpu func())
+
+func crossoverOps() map[string]crossoverOp {
+	grp := initTestGroup()
+	return map[string]crossoverOp{
+		"ExpChunk": func(t *testing.T, pool *StreamPool, batchSize uint32) (gpu, cpu func()) {
+			x := initRandomIntBuffer(grp, batchSize, 42, 0)
+			y := initRandomIntBuffer(grp, batchSize, 43, 0)
+			z := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+			gpu = func() { expGPU(t, pool, grp, x, y, z) }
+			cpu = func() { expCPU(batchSize, grp, x, y, z) }
+			return gpu, cpu
+		},
+		"ElGamalChunk": func(t *testing.T, pool *StreamPool, batchSize uint32) (gpu, cpu func()) {
+			_, publicCypherKey, phaseKeys, shareKeys := initElGamal(batchSize)
+			keys := initRandomIntBuffer(grp, batchSize, 42, 256/8)
+			cypher := initRandomIntBuffer(grp, batchSize, 43, 256/8)
+			gpu = func() {
+				elgamalGPU(t, pool, grp, publicCypherKey, phaseKeys, shareKeys, keys, cypher)
+			}
+			cpu = func() {
+				elgamalCPU(batchSize, grp, publicCypherKey, phaseKeys, shareKeys, keys, cypher)
+			}
+			return gpu, cpu
+		},
+		"RevealChunk": func(t *testing.T, pool *StreamPool, batchSize uint32) (gpu, cpu func()) {
+			_, publicCypherKey := initReveal()
+			cypher := initRandomIntBuffer(grp, batchSize, 11, 0)
+			gpu = func() { revealGPU(t, pool, grp, publicCypherKey, cypher) }
+			cpu = func() { revealCPU(batchSize, grp, publicCypherKey, cypher) }
+			return gpu, cpu
+		},
+		"Mul2Chunk": func(t *testing.T, pool *StreamPool, batchSize uint32) (gpu, cpu func()) {
+			x := initRandomIntBuffer(grp, batchSize, 42, 0)
+			y := initRandomIntBuffer(grp, batchSize, 43, 0)
+			result := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+			gpu = func() { mul2GPU(t, pool, grp, x, y, result) }
+			cpu = func() { mul2CPU(batchSize, grp, x, y) }
+			return gpu, cpu
+		},
+		"Mul3Chunk": func(t *testing.T, pool *StreamPool, batchSize uint32) (gpu, cpu func()) {
+			x := initRandomIntBuffer(grp, batchSize, 42, 0)
+			y := initRandomIntBuffer(grp, batchSize, 43, 0)
+			z := initRandomIntBuffer(grp, batchSize, 44, 0)
+			result := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+			gpu = func() { mul3GPU(t, pool, grp, x, y, z, result) }
+			cpu = func() { mul3CPU(batchSize, grp, x, y, z) }
+			return gpu, cpu
+		},
+	}
+}
+
+// Reports the batch size at which each op starts running faster on the GPU
+// than on the CPU
+func TestCrossoverReport(t *testing.T) {
+	if os.Getenv("GPUMATHS_CROSSOVER") == "" {
+		t.Skip("Set GPUMATHS_CROSSOVER to measure GPU/CPU crossover batch sizes")
+	}
+	streamPool, err := NewStreamPool(1, 6553600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crossovers := make(map[string]uint32)
+	for name, op := range crossoverOps() {
+		crossover := uint32(0)
+		for batchSize := uint32(1); batchSize <= maxCrossoverBatch; batchSize *= 2 {
+			gpu, cpu := op(t, streamPool, batchSize)
+			start := time.Now()
+			gpu()
+			gpuTime := time.Since(start)
+			start = time.Now()
+			cpu()
+			cpuTime := time.Since(start)
+			t.Logf("%v: %v slots took %v on the GPU and %v on the CPU",
+				name, batchSize, gpuTime, cpuTime)
+			if gpuTime < cpuTime {
+				crossover = batchSize
+				break
+			}
+		}
+		if crossover == 0 {
+			t.Logf("%v: the GPU didn't beat the CPU at up to %v slots",
+				name, maxCrossoverBatch)
+			crossover = 2 * maxCrossoverBatch
+		} else {
+			t.Logf("%v: the GPU beats the CPU from %v slots", name, crossover)
+		}
+		crossovers[name] = crossover
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("GPUMATHS_CROSSOVER_TUNING")
+	if path == "" {
+		return
+	}
+	tuning, err := LoadTuning(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	tuning.Crossovers = crossovers
+	err = SaveTuning(path, tuning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Saved the crossovers to %v", path)
+}
@@ -0,0 +1,33 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+// Cryptop is implemented by every op prototype in this package, so that code
+// building a graph can ask an op how to schedule it instead of having a case
+// for each op
+type Cryptop interface {
+	// GetName returns the name of the op
+	GetName() string
+	// GetInputSize is the size of each chunk for the op
+	GetInputSize() uint32
+	// RequiresGPU is true if the op can't run without a GPU. It's true for
+	// every op in this package, as they all run on a StreamPool and return
+	// NoGpuErrStr in a build without the GPU. Running on the CPU instead
+	// means calling the cryptops equivalent, which is a different op.
+	RequiresGPU() bool
+	// PrefersGPU is true if the op should go on the GPU when there is one.
+	// It's true for every op in this package, as they only exist to run
+	// there.
+	PrefersGPU() bool
+}
+
+// Cryptops returns every op in this package
+func Cryptops() []Cryptop {
+	return []Cryptop{ExpChunk, ExpSharedChunk, ElGamalChunk, RevealChunk,
+		Mul2Chunk, Mul2Slice, Mul3Chunk, Mul3Slice}
+}
@@ -0,0 +1,33 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "testing"
+
+// Only ExpSharedChunk has nothing in cryptops to fall back on
+func TestCryptops(t *testing.T) {
+	names := make(map[string]bool)
+	for _, op := range Cryptops() {
+		if names[op.GetName()] {
+			t.Errorf("%v is listed twice", op.GetName())
+		}
+		names[op.GetName()] = true
+		if op.GetInputSize() == 0 {
+			t.Errorf("%v has no input size", op.GetName())
+		}
+		if !op.PrefersGPU() {
+			t.Errorf("%v doesn't prefer the GPU", op.GetName())
+		}
+		if !op.RequiresGPU() {
+			t.Errorf("%v doesn't require the GPU", op.GetName())
+		}
+	}
+	if len(names) != 8 {
+		t.Errorf("Got %v ops, expected 8", len(names))
+	}
+}
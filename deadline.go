@@ -0,0 +1,55 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"time"
+	"unsafe"
+)
+
+// deadline.go orders callers that are waiting for a stream so that the one
+// with the earliest deadline gets the next stream that's returned. Callers
+// without a deadline are served after everyone with one, in the order that
+// they started waiting.
+
+// waiter is a caller waiting for a stream
+type waiter struct {
+	deadline time.Time
+	// Order the caller started waiting in, to break ties
+	seq uint64
+	// The stream is sent here when it's this caller's turn
+	stream chan Stream
+	// A stream that mustn't be sent, because the caller's batch just
+	// failed on it. Nil if any stream will do.
+	exclude unsafe.Pointer
+}
+
+// before returns true if w should get a stream before other
+func (w *waiter) before(other *waiter) bool {
+	if w.deadline.IsZero() != other.deadline.IsZero() {
+		return !w.deadline.IsZero()
+	}
+	if !w.deadline.Equal(other.deadline) {
+		return w.deadline.Before(other.deadline)
+	}
+	return w.seq < other.seq
+}
+
+// waiters implements heap.Interface, with the next waiter to serve on top
+type waiters []*waiter
+
+func (w waiters) Len() int            { return len(w) }
+func (w waiters) Less(i, j int) bool  { return w[i].before(w[j]) }
+func (w waiters) Swap(i, j int)       { w[i], w[j] = w[j], w[i] }
+func (w *waiters) Push(x interface{}) { *w = append(*w, x.(*waiter)) }
+func (w *waiters) Pop() interface{} {
+	old := *w
+	last := old[len(old)-1]
+	*w = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,35 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+// Waiters should come off the heap earliest deadline first, then the ones
+// without a deadline in the order they started waiting
+func TestWaiters(t *testing.T) {
+	now := time.Now()
+	var w waiters
+	heap.Push(&w, &waiter{seq: 0})
+	heap.Push(&w, &waiter{deadline: now.Add(2 * time.Second), seq: 1})
+	heap.Push(&w, &waiter{seq: 2})
+	heap.Push(&w, &waiter{deadline: now.Add(time.Second), seq: 3})
+	heap.Push(&w, &waiter{deadline: now.Add(time.Second), seq: 4})
+
+	expected := []uint64{3, 4, 1, 0, 2}
+	for i := range expected {
+		next := heap.Pop(&w).(*waiter)
+		if next.seq != expected[i] {
+			t.Errorf("Waiter %v off the heap was %v, expected %v", i,
+				next.seq, expected[i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu,gpudebug
+
+package gpumaths
+
+/*
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#cgo LDFLAGS: -L/opt/xxnetwork/lib -lpowmosm75 -Wl,-rpath,./lib:/opt/xxnetwork/lib
+#include <powm_odd_export.h>
+*/
+import "C"
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// debug_gpu.go holds checks on what gets passed across cgo. They're only
+// built in with `-tags gpudebug`, and they panic as soon as something is
+// wrong, rather than letting the C side crash or corrupt memory later.
+
+// liveStreams holds every stream pointer that the library has created and
+// that hasn't been destroyed yet. Anything else passed to the library as a
+// stream is either Go memory or a stream that's already been freed.
+var liveStreams = struct {
+	sync.Mutex
+	m map[unsafe.Pointer]bool
+}{m: make(map[unsafe.Pointer]bool)}
+
+func debugStreamCreated(s Stream) {
+	liveStreams.Lock()
+	liveStreams.m[s.s] = true
+	liveStreams.Unlock()
+}
+
+func debugStreamDestroyed(s Stream) {
+	liveStreams.Lock()
+	if !liveStreams.m[s.s] {
+		liveStreams.Unlock()
+		panic(fmt.Sprintf("gpumaths debug: destroying stream %p that "+
+			"the library didn't create, or that was already destroyed", s.s))
+	}
+	delete(liveStreams.m, s.s)
+	liveStreams.Unlock()
+}
+
+// debugCheckEnqueue makes sure the stream passed to the library is a live
+// stream that it created, and that the kernel won't read or write past the
+// end of the stream's memory
+func debugCheckEnqueue(env gpumathsEnv, s Stream, kernel C.enum_kernel,
+	numSlots int) {
+	liveStreams.Lock()
+	live := liveStreams.m[s.s]
+	liveStreams.Unlock()
+	if !live {
+		panic(fmt.Sprintf("gpumaths debug: enqueueing to stream %p that the "+
+			"library didn't create, or that was already destroyed", s.s))
+	}
+	size := env.streamSizeContaining(numSlots, int(kernel))
+	if size > len(s.cpuData) {
+		panic(fmt.Sprintf("gpumaths debug: %v slots of kernel %v need %v "+
+			"bytes, but the stream only has %v", numSlots, kernel, size,
+			len(s.cpuData)))
+	}
+}
@@ -0,0 +1,88 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"encoding/binary"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// dedup.go finds the slots of a chunk that have the same operands as an
+// earlier slot, so that each distinct set of operands only goes through the
+// GPU once. Test traffic and the dummy slots that pad out a round often
+// repeat the same values many times.
+
+// uniqueSlots returns the first slot with each distinct set of operands, in
+// slot order, and for every slot, the index in unique of the slot that has
+// the same operands
+func uniqueSlots(operands ...intGetter) (unique []uint32, copyOf []uint32) {
+	if len(operands) == 0 {
+		return nil, nil
+	}
+	numSlots := uint32(operands[0].Len())
+	copyOf = make([]uint32, numSlots)
+	seen := make(map[string]uint32, numSlots)
+	var key []byte
+	var lenBuf [4]byte
+	for i := uint32(0); i < numSlots; i++ {
+		key = key[:0]
+		for _, operand := range operands {
+			value := operand.Get(i).Bytes()
+			// Lengths keep operands from running into each other
+			binary.BigEndian.PutUint32(lenBuf[:], uint32(len(value)))
+			key = append(key, lenBuf[:]...)
+			key = append(key, value...)
+		}
+		u, ok := seen[string(key)]
+		if !ok {
+			u = uint32(len(unique))
+			seen[string(key)] = u
+			unique = append(unique, i)
+		}
+		copyOf[i] = u
+	}
+	return unique, copyOf
+}
+
+// compactSlots returns a new buffer with the values of the unique slots
+func compactSlots(g *cyclic.Group, buffer intGetter,
+	unique []uint32) *cyclic.IntBuffer {
+	compact := g.NewIntBuffer(uint32(len(unique)), g.NewInt(1))
+	for u, slot := range unique {
+		g.Set(compact.Get(uint32(u)), buffer.Get(slot))
+	}
+	return compact
+}
+
+// fanOut copies each result in compact to every slot of results that it's
+// the result for
+func fanOut(g *cyclic.Group, compact, results intGetter, copyOf []uint32) {
+	for i, u := range copyOf {
+		g.Set(results.Get(uint32(i)), compact.Get(u))
+	}
+}
+
+// fanOutErr turns slot errors for the compacted slots into errors for every
+// slot that shares their operands. Other errors are returned as they are.
+func fanOutErr(err error, copyOf []uint32) error {
+	compactErrs, ok := err.(SlotErrors)
+	if !ok {
+		return err
+	}
+	bySlot := make(map[uint32][]error, len(compactErrs))
+	for _, e := range compactErrs {
+		bySlot[e.Slot] = append(bySlot[e.Slot], e.Err)
+	}
+	var slotErrs SlotErrors
+	for i, u := range copyOf {
+		for _, e := range bySlot[u] {
+			slotErrs = append(slotErrs, SlotError{Slot: uint32(i), Err: e})
+		}
+	}
+	return slotErrs
+}
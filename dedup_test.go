@@ -0,0 +1,75 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"reflect"
+	"testing"
+)
+
+func newDedupBuffer(g *cyclic.Group, values ...int64) *cyclic.IntBuffer {
+	b := g.NewIntBuffer(uint32(len(values)), g.NewInt(1))
+	for i, v := range values {
+		g.Set(b.Get(uint32(i)), g.NewInt(v))
+	}
+	return b
+}
+
+// Slots only count as copies if every operand matches
+func TestUniqueSlots(t *testing.T) {
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 5, 5, 6, 5, 5)
+	y := newDedupBuffer(g, 3, 3, 3, 4, 3)
+	unique, copyOf := uniqueSlots(x, y)
+	if !reflect.DeepEqual(unique, []uint32{0, 2, 3}) {
+		t.Errorf("Unique slots were %v", unique)
+	}
+	if !reflect.DeepEqual(copyOf, []uint32{0, 0, 1, 2, 0}) {
+		t.Errorf("Copies were %v", copyOf)
+	}
+
+	// 0x0102 and 0x03 shouldn't look the same as 0x01 and 0x0203, which
+	// needs a group big enough to hold them
+	g = cyclic.NewGroup(large.NewInt(65537), large.NewInt(3))
+	a := newDedupBuffer(g, 0x0102, 0x01)
+	b := newDedupBuffer(g, 0x03, 0x0203)
+	unique, _ = uniqueSlots(a, b)
+	if len(unique) != 2 {
+		t.Errorf("Operands ran into each other: %v", unique)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 5, 5, 6, 5)
+	unique, copyOf := uniqueSlots(x)
+	compact := compactSlots(g, x, unique)
+	if compact.Len() != 2 || compact.Get(1).Cmp(g.NewInt(6)) != 0 {
+		t.Errorf("Compacted to %v slots", compact.Len())
+	}
+	results := g.NewIntBuffer(4, g.NewInt(1))
+	fanOut(g, compact, results, copyOf)
+	for i := uint32(0); i < 4; i++ {
+		if results.Get(i).Cmp(x.Get(i)) != 0 {
+			t.Errorf("Slot %v got %v", i, results.Get(i).Text(10))
+		}
+	}
+
+	bad := errors.New("not in group")
+	err := fanOutErr(SlotErrors{{Slot: 0, Err: bad}}, copyOf)
+	slotErrs, ok := err.(SlotErrors)
+	if !ok || len(slotErrs) != 3 || slotErrs[2].Slot != 3 {
+		t.Errorf("Expected errors for slots 0, 1 and 3, got %v", err)
+	}
+	if fanOutErr(bad, copyOf) != bad {
+		t.Error("Errors that aren't for slots should be left alone")
+	}
+}
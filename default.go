@@ -0,0 +1,55 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "gitlab.com/elixxir/crypto/cyclic"
+
+// default.go has top-level versions of the common ops that run on a pool the
+// package creates for itself, for callers that just want some maths done on
+// the GPU and don't need to size or share pools.
+
+// DefaultConfig is used to create the default pool if Init hasn't been
+// called first. Change it before the first call that uses the default pool.
+var DefaultConfig = Config{
+	NumStreams: 2,
+	StreamSize: 16 << 20,
+}
+
+// Default returns the pool created by Init, or creates it with DefaultConfig
+// if Init hasn't been called. It's safe to call from many goroutines at once,
+// and they all get the same pool. If creating the pool fails, the next call
+// tries again.
+func Default() (*StreamPool, error) {
+	initialized.Lock()
+	defer initialized.Unlock()
+	if initialized.pool != nil {
+		return initialized.pool, nil
+	}
+	pool, err := initLocked(DefaultConfig)
+	initialized.err = err
+	return pool, err
+}
+
+// Exp sets z[i] = x[i]**y[i] mod p for each slot on the default pool
+func Exp(g *cyclic.Group, x, y, z *cyclic.IntBuffer) error {
+	pool, err := Default()
+	if err != nil {
+		return err
+	}
+	_, err = ExpChunk(pool, g, x, y, z)
+	return err
+}
+
+// Mul2 sets result[i] = x[i]*y[i] mod p for each slot on the default pool
+func Mul2(g *cyclic.Group, x, y, result *cyclic.IntBuffer) error {
+	pool, err := Default()
+	if err != nil {
+		return err
+	}
+	return Mul2Chunk(pool, g, x, y, result)
+}
@@ -0,0 +1,61 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"sync"
+	"testing"
+)
+
+// Calls from many goroutines should share one lazily created pool, and get
+// the same results as the CPU
+func TestDefault(t *testing.T) {
+	grp := initTestGroup()
+	const batchSize = 64
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+
+	var wg sync.WaitGroup
+	pools := make([]*StreamPool, 4)
+	results := make([]error, 4)
+	for i := range pools {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pools[i], results[i] = Default()
+		}(i)
+	}
+	wg.Wait()
+	defer func() {
+		if err := Shutdown(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for i := range pools {
+		if results[i] != nil {
+			t.Fatal(results[i])
+		}
+		if pools[i] != pools[0] {
+			t.Error("Every caller should get the same default pool")
+		}
+	}
+
+	result := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	err := Mul2(grp, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mul2CPU(batchSize, grp, x, y)
+	for i := uint32(0); i < batchSize; i++ {
+		if result.Get(i).Cmp(y.Get(i)) != 0 {
+			t.Errorf("Slot %v differed from the CPU", i)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "fmt"
+
+// Diagnosis is one finding about the environment from Diagnose
+type Diagnosis struct {
+	// Set if the finding will stop the GPU from being used or slow it down
+	Problem bool
+	// What was found, and what to do about it if it's a problem
+	Message string
+}
+
+func (d Diagnosis) String() string {
+	if d.Problem {
+		return "problem: " + d.Message
+	}
+	return "ok: " + d.Message
+}
+
+// cudaVersionProblem explains why a driver that supports CUDA versions up to
+// driver can't run the runtime the kernel library loaded, or returns "" if
+// it can. Versions are 1000*major + 10*minor, and a runtime of 0 is unknown.
+func cudaVersionProblem(driver, runtime int) string {
+	if runtime == 0 || driver >= runtime {
+		return ""
+	}
+	return fmt.Sprintf("the driver supports CUDA %v, but the kernel "+
+		"library uses CUDA %v, so its kernels won't launch. Upgrade the "+
+		"driver on the host, or use a library built for CUDA %v",
+		cudaVersionString(driver), cudaVersionString(runtime),
+		cudaVersionString(driver))
+}
+
+// cudaVersionString formats a version given as 1000*major + 10*minor
+func cudaVersionString(version int) string {
+	return fmt.Sprintf("%v.%v", version/1000, version%1000/10)
+}
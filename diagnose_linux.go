@@ -0,0 +1,259 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// diagnose_linux.go looks at the environment the process is running in, to
+// explain failures that come from how the machine or container is set up
+// rather than from the GPU itself.
+
+// Where the driver's device nodes and version are found. These are variables
+// so that tests can point them somewhere else.
+var (
+	nvidiaDevDir      = "/dev"
+	nvidiaVersionFile = "/proc/driver/nvidia/version"
+	nvidiaCapsDir     = "/proc/driver/nvidia/capabilities"
+	// Has a directory named after each GPU's PCI address
+	nvidiaGpusDir = "/proc/driver/nvidia/gpus"
+	pciDevicesDir = "/sys/bus/pci/devices"
+	numaNodesDir  = "/sys/devices/system/node"
+)
+
+// Locked memory limits below this are too low for a useful stream pool
+const lowMemlockLimit = 64 << 20
+
+// Where the MPS control daemon puts its pipes unless
+// CUDA_MPS_PIPE_DIRECTORY says otherwise
+const defaultMpsPipeDir = "/tmp/nvidia-mps"
+
+// Diagnose checks the things that most often stop the GPU from working when
+// a node runs in a container: whether the device nodes are visible, whether
+// the driver is loaded, whether enough memory can be locked for streams, and
+// whether MPS or MIG are sharing the GPU. On hosts with more than one NUMA
+// node, it also says which node each GPU is attached to. It doesn't touch the
+// GPU itself, so it can be run when NewStreamPool fails.
+func Diagnose() []Diagnosis {
+	var diagnoses []Diagnosis
+	diagnoses = append(diagnoses, diagnoseDeviceNodes()...)
+	diagnoses = append(diagnoses, diagnoseDriver())
+	diagnoses = append(diagnoses, diagnoseMemlock())
+	diagnoses = append(diagnoses, diagnoseMps())
+	diagnoses = append(diagnoses, diagnoseMig())
+	diagnoses = append(diagnoses, diagnoseNuma()...)
+	return diagnoses
+}
+
+// diagnoseDeviceNodes checks that the control node and at least one GPU's
+// node are there and can be opened
+func diagnoseDeviceNodes() []Diagnosis {
+	var diagnoses []Diagnosis
+	gpus, _ := filepath.Glob(filepath.Join(nvidiaDevDir, "nvidia[0-9]*"))
+	if len(gpus) == 0 {
+		diagnoses = append(diagnoses, Diagnosis{
+			Problem: true,
+			Message: fmt.Sprintf("no GPU device nodes in %v. If this is "+
+				"a container, run it with the NVIDIA runtime (e.g. docker "+
+				"--gpus all) and check NVIDIA_VISIBLE_DEVICES", nvidiaDevDir),
+		})
+	}
+	nodes := append([]string{filepath.Join(nvidiaDevDir, "nvidiactl"),
+		filepath.Join(nvidiaDevDir, "nvidia-uvm")}, gpus...)
+	for _, node := range nodes {
+		f, err := os.OpenFile(node, os.O_RDWR, 0)
+		if err != nil {
+			diagnoses = append(diagnoses, Diagnosis{
+				Problem: true,
+				Message: fmt.Sprintf("can't open %v: %v. Check that it's "+
+					"mounted into the container and that the device cgroup "+
+					"allows it", node, err),
+			})
+			continue
+		}
+		f.Close()
+		diagnoses = append(diagnoses, Diagnosis{
+			Message: fmt.Sprintf("%v can be opened", node),
+		})
+	}
+	return diagnoses
+}
+
+// diagnoseDriver reports the version of the loaded kernel driver. The CUDA
+// runtime that the library was built against needs a driver at least as new
+// as it, so this is what to compare when the library reports a version
+// mismatch.
+func diagnoseDriver() Diagnosis {
+	version, err := ioutil.ReadFile(nvidiaVersionFile)
+	if err != nil {
+		return Diagnosis{
+			Problem: true,
+			Message: fmt.Sprintf("can't read the driver version from %v: "+
+				"%v. The NVIDIA kernel driver may not be loaded on the host",
+				nvidiaVersionFile, err),
+		}
+	}
+	firstLine := strings.SplitN(string(version), "\n", 2)[0]
+	return Diagnosis{
+		Message: "driver: " + strings.TrimSpace(firstLine),
+	}
+}
+
+// diagnoseMemlock reports whether streams of a typical size can have their
+// host memory pinned
+func diagnoseMemlock() Diagnosis {
+	limit, unlimited, err := lockedMemoryLimit()
+	if err != nil {
+		return Diagnosis{
+			Problem: true,
+			Message: fmt.Sprintf("can't get the locked memory limit: %v", err),
+		}
+	}
+	if unlimited {
+		return Diagnosis{Message: "locked memory is unlimited"}
+	}
+	return Diagnosis{
+		Problem: limit < lowMemlockLimit,
+		Message: fmt.Sprintf("the locked memory limit (ulimit -l) is %v "+
+			"bytes, so streams can't have more than that much pinned memory "+
+			"between them. Raise the limit if that's too low, e.g. with "+
+			"--ulimit memlock=-1 for docker", limit),
+	}
+}
+
+// diagnoseMps reports whether the Multi-Process Service is running, in which
+// case other processes may be sharing the GPU with this one
+func diagnoseMps() Diagnosis {
+	pipeDir := os.Getenv("CUDA_MPS_PIPE_DIRECTORY")
+	if pipeDir == "" {
+		pipeDir = defaultMpsPipeDir
+	}
+	_, err := os.Stat(filepath.Join(pipeDir, "control"))
+	if err != nil {
+		return Diagnosis{Message: "MPS isn't running"}
+	}
+	return Diagnosis{
+		Message: fmt.Sprintf("MPS is running with pipes in %v, so other "+
+			"processes may be sharing the GPU. The pipe directory must be "+
+			"mounted into the container for streams to be created", pipeDir),
+	}
+}
+
+// preflightDiagnoses runs the checks from Diagnose that Init needs to pass
+// before it creates streams that lock lockedBytes of host memory between
+// them. problems would stop the pool from working, while warnings may only
+// mean it gets less of the GPU than expected.
+func preflightDiagnoses(lockedBytes int) (problems, warnings []string) {
+	for _, d := range diagnoseDeviceNodes() {
+		if d.Problem {
+			problems = append(problems, d.Message)
+		}
+	}
+	if d := diagnoseDriver(); d.Problem {
+		problems = append(problems, d.Message)
+	}
+	if msg := memlockDiagnosis(lockedBytes); msg != "" {
+		problems = append(problems, msg)
+	}
+	if d := diagnoseMig(); d.Problem {
+		warnings = append(warnings, d.Message)
+	}
+	return problems, warnings
+}
+
+// rlimitMemlock is RLIMIT_MEMLOCK on x86 and arm, which the syscall package
+// doesn't export
+const rlimitMemlock = 8
+
+// rlimInfinity is RLIM_INFINITY as an unsigned limit
+const rlimInfinity = ^uint64(0)
+
+// lockedMemoryLimit returns the soft limit on the number of bytes the process
+// can lock in memory. unlimited is true if there's no limit.
+func lockedMemoryLimit() (limit uint64, unlimited bool, err error) {
+	var rlimit syscall.Rlimit
+	err = syscall.Getrlimit(rlimitMemlock, &rlimit)
+	if err != nil {
+		return 0, false, err
+	}
+	return rlimit.Cur, rlimit.Cur == rlimInfinity, nil
+}
+
+// memlockDiagnosis explains why pinning needed bytes of host memory failed,
+// if the locked memory limit is the reason. Otherwise it returns "".
+func memlockDiagnosis(needed int) string {
+	limit, unlimited, err := lockedMemoryLimit()
+	if err != nil || unlimited || limit >= uint64(needed) {
+		return ""
+	}
+	return fmt.Sprintf("the locked memory limit (ulimit -l) is %v bytes, "+
+		"but the streams need %v bytes of pinned memory. Raise the limit, "+
+		"e.g. with --ulimit memlock=-1 for docker", limit, needed)
+}
+
+// diagnoseMig reports whether the GPUs are split into Multi-Instance GPU
+// partitions. A CUDA process can only use one partition, so a stream pool
+// only gets that partition's share of memory and SMs.
+func diagnoseMig() Diagnosis {
+	instances, _ := filepath.Glob(filepath.Join(nvidiaCapsDir,
+		"gpu[0-9]*", "mig", "gi[0-9]*", "ci[0-9]*"))
+	if len(instances) == 0 {
+		return Diagnosis{Message: "MIG isn't in use"}
+	}
+	visible := os.Getenv("CUDA_VISIBLE_DEVICES")
+	if !strings.Contains(visible, "MIG-") {
+		return Diagnosis{
+			Problem: true,
+			Message: fmt.Sprintf("the GPUs are split into %v MIG "+
+				"instances, but CUDA_VISIBLE_DEVICES doesn't name one. Set it "+
+				"to the MIG UUID (from nvidia-smi -L) that this node should "+
+				"use", len(instances)),
+		}
+	}
+	return Diagnosis{
+		Message: fmt.Sprintf("the GPUs are split into %v MIG instances, and "+
+			"this process uses the first of %v. Streams share that "+
+			"instance's memory, and NewStreamPool makes them smaller if "+
+			"they don't fit", len(instances), visible),
+	}
+}
+
+// diagnoseNuma reports the NUMA node that each GPU's PCIe root is on, if the
+// host has more than one. Staging memory and the goroutines that pack it are
+// fastest on that node, and the way to get them there is to start the
+// process bound to it.
+func diagnoseNuma() []Diagnosis {
+	nodes, _ := filepath.Glob(filepath.Join(numaNodesDir, "node[0-9]*"))
+	if len(nodes) < 2 {
+		return nil
+	}
+	gpus, _ := ioutil.ReadDir(nvidiaGpusDir)
+	var diagnoses []Diagnosis
+	for _, gpu := range gpus {
+		numaNode, err := ioutil.ReadFile(filepath.Join(pciDevicesDir,
+			gpu.Name(), "numa_node"))
+		node := strings.TrimSpace(string(numaNode))
+		// The kernel reports -1 if it doesn't know
+		if err != nil || node == "-1" {
+			continue
+		}
+		diagnoses = append(diagnoses, Diagnosis{
+			Message: fmt.Sprintf("GPU %v is attached to NUMA node %v of "+
+				"%v. For the fastest uploads, run the node with numactl "+
+				"--cpunodebind=%v --membind=%v", gpu.Name(), node,
+				len(nodes), node, node),
+		})
+	}
+	return diagnoses
+}
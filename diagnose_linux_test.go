@@ -0,0 +1,232 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Streams that fit under the locked memory limit shouldn't be diagnosed, and
+// streams that don't should be, unless there's no limit
+func TestMemlockDiagnosis(t *testing.T) {
+	if memlockDiagnosis(0) != "" {
+		t.Error("Streams that need no memory shouldn't have a diagnosis")
+	}
+	_, unlimited, err := lockedMemoryLimit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnosis := memlockDiagnosis(math.MaxInt32)
+	if unlimited && diagnosis != "" {
+		t.Errorf("Unlimited locked memory shouldn't have a diagnosis: %v",
+			diagnosis)
+	} else if !unlimited && diagnosis == "" {
+		t.Error("Streams bigger than the limit should have a diagnosis")
+	}
+}
+
+// Missing device nodes and driver should be reported as problems, and nodes
+// that are there shouldn't be
+func TestDiagnose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpumaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldDevDir, oldVersionFile := nvidiaDevDir, nvidiaVersionFile
+	defer func() {
+		nvidiaDevDir, nvidiaVersionFile = oldDevDir, oldVersionFile
+	}()
+	nvidiaDevDir = dir
+	nvidiaVersionFile = filepath.Join(dir, "version")
+
+	for _, d := range diagnoseDeviceNodes() {
+		if !d.Problem {
+			t.Errorf("Empty device directory shouldn't be ok: %v", d)
+		}
+	}
+	if !diagnoseDriver().Problem {
+		t.Error("Missing driver version should be a problem")
+	}
+
+	for _, node := range []string{"nvidiactl", "nvidia-uvm", "nvidia0"} {
+		err = ioutil.WriteFile(filepath.Join(dir, node), nil, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = ioutil.WriteFile(nvidiaVersionFile, []byte("NVRM version: "+
+		"NVIDIA UNIX x86_64 Kernel Module  450.51.06\nGCC version: 9.3.0\n"),
+		0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnoses := diagnoseDeviceNodes()
+	if len(diagnoses) != 3 {
+		t.Errorf("Expected a diagnosis for each of 3 nodes, got %v", diagnoses)
+	}
+	for _, d := range diagnoses {
+		if d.Problem {
+			t.Errorf("Device node that can be opened shouldn't be a problem: %v", d)
+		}
+	}
+	driver := diagnoseDriver()
+	if driver.Problem || !strings.Contains(driver.Message, "450.51.06") ||
+		strings.Contains(driver.Message, "GCC") {
+		t.Errorf("Expected only the driver version line, got %v", driver)
+	}
+}
+
+// MIG instances should be counted, and not naming one should be a problem
+func TestDiagnoseMig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpumaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldCapsDir := nvidiaCapsDir
+	defer func() { nvidiaCapsDir = oldCapsDir }()
+	nvidiaCapsDir = dir
+	oldVisible, wasSet := os.LookupEnv("CUDA_VISIBLE_DEVICES")
+	defer func() {
+		if wasSet {
+			os.Setenv("CUDA_VISIBLE_DEVICES", oldVisible)
+		} else {
+			os.Unsetenv("CUDA_VISIBLE_DEVICES")
+		}
+	}()
+	os.Unsetenv("CUDA_VISIBLE_DEVICES")
+
+	if diagnoseMig().Problem {
+		t.Error("No MIG instances shouldn't be a problem")
+	}
+	for _, ci := range []string{"gpu0/mig/gi1/ci0", "gpu0/mig/gi2/ci0"} {
+		err = os.MkdirAll(filepath.Join(dir, ci), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mig := diagnoseMig()
+	if !mig.Problem {
+		t.Error("MIG without an instance in CUDA_VISIBLE_DEVICES should be a problem")
+	}
+	if !strings.Contains(mig.Message, "2 MIG instances") {
+		t.Errorf("Expected 2 MIG instances, got %v", mig)
+	}
+	os.Setenv("CUDA_VISIBLE_DEVICES",
+		"MIG-GPU-5c89852c-d268-c3f3-1b07-005d5ae1dc3f/1/0")
+	if diagnoseMig().Problem {
+		t.Error("MIG with an instance named shouldn't be a problem")
+	}
+}
+
+// Each GPU's NUMA node should be reported, but only on multi-node hosts
+func TestDiagnoseNuma(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpumaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldGpus, oldPci, oldNodes := nvidiaGpusDir, pciDevicesDir, numaNodesDir
+	defer func() {
+		nvidiaGpusDir, pciDevicesDir, numaNodesDir = oldGpus, oldPci, oldNodes
+	}()
+	nvidiaGpusDir = filepath.Join(dir, "gpus")
+	pciDevicesDir = filepath.Join(dir, "pci")
+	numaNodesDir = filepath.Join(dir, "nodes")
+
+	const addr = "0000:81:00.0"
+	for _, d := range []string{filepath.Join(nvidiaGpusDir, addr),
+		filepath.Join(pciDevicesDir, addr), filepath.Join(numaNodesDir, "node0")} {
+		err = os.MkdirAll(d, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(pciDevicesDir, addr, "numa_node"),
+		[]byte("1\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnoseNuma()) != 0 {
+		t.Error("Single node host shouldn't have NUMA diagnoses")
+	}
+
+	err = os.MkdirAll(filepath.Join(numaNodesDir, "node1"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnoses := diagnoseNuma()
+	if len(diagnoses) != 1 || diagnoses[0].Problem ||
+		!strings.Contains(diagnoses[0].Message, "--membind=1") {
+		t.Errorf("Expected GPU on node 1, got %v", diagnoses)
+	}
+}
+
+// Init should refuse to run on a machine without device nodes, and say why.
+// The locked memory limit should be checked against the pool Init would
+// make, and MIG without an instance named shouldn't stop it.
+func TestPreflight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpumaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldDevDir, oldCapsDir := nvidiaDevDir, nvidiaCapsDir
+	defer func() { nvidiaDevDir, nvidiaCapsDir = oldDevDir, oldCapsDir }()
+	nvidiaDevDir = dir
+	nvidiaCapsDir = dir
+	err = os.MkdirAll(filepath.Join(dir, "gpu0/mig/gi1/ci0"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = preflight(Config{NumStreams: 1, StreamSize: 1})
+	if err == nil || !strings.Contains(err.Error(), "no GPU device nodes") {
+		t.Fatalf("Expected preflight to report missing nodes, got %v", err)
+	}
+	if strings.Contains(err.Error(), "locked memory") {
+		t.Errorf("A pool that fits under the limit was reported: %v", err)
+	}
+	if strings.Contains(err.Error(), "MIG") {
+		t.Errorf("MIG should only be a warning: %v", err)
+	}
+	_, unlimited, err := lockedMemoryLimit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = preflight(Config{NumStreams: 2, StreamSize: math.MaxInt32})
+	if !unlimited && !strings.Contains(err.Error(), "locked memory") {
+		t.Errorf("Expected a pool over the limit to be reported, got %v", err)
+	}
+	_, err = Init(Config{NumStreams: 1, StreamSize: 1 << 20})
+	if err == nil || !strings.Contains(err.Error(), "SkipPreflight") {
+		t.Errorf("Expected Init to fail its preflight, got %v", err)
+	}
+}
+
+// A driver older than the runtime should be reported with both versions,
+// and an unknown runtime shouldn't be
+func TestCudaVersionProblem(t *testing.T) {
+	problem := cudaVersionProblem(11000, 11020)
+	if !strings.Contains(problem, "CUDA 11.0") ||
+		!strings.Contains(problem, "CUDA 11.2") {
+		t.Errorf("Expected both versions in the problem, got %q", problem)
+	}
+	if p := cudaVersionProblem(11020, 11000); p != "" {
+		t.Errorf("A newer driver shouldn't be a problem: %v", p)
+	}
+	if p := cudaVersionProblem(11000, 0); p != "" {
+		t.Errorf("An unknown runtime shouldn't be a problem: %v", p)
+	}
+}
@@ -0,0 +1,24 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux
+
+package gpumaths
+
+// Diagnose only knows how to look at Linux machines, which are the only
+// ones that the GPU build supports
+func Diagnose() []Diagnosis {
+	return []Diagnosis{{
+		Problem: true,
+		Message: "gpumaths only supports the GPU on linux",
+	}}
+}
+
+// preflightDiagnoses fails every preflight, for the same reason as Diagnose
+func preflightDiagnoses(lockedBytes int) (problems, warnings []string) {
+	return []string{Diagnose()[0].Message}, nil
+}
@@ -0,0 +1,89 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// dummy.go keeps the dummy slots that pad out a round from being uploaded
+// and computed. The real slots are packed together before the op runs, and
+// the results are spread back out afterwards, with a placeholder in every
+// dummy slot.
+
+// SkipDummies runs op on only the slots that dummy doesn't mark. op is given
+// buffers holding just those slots, in order, in place of inputs and
+// results, and would normally pass them to one of the chunk functions. A
+// buffer that's in both inputs and results, like ElGamalChunk's ecrKey and
+// cypher, is given to op as the same buffer in both. Afterwards, each
+// result is copied back to its slot, and every dummy slot of results is set
+// to placeholder. Slots in SlotErrors from op are the slots in the buffers
+// that were passed in.
+func SkipDummies(g *cyclic.Group, dummy []bool, placeholder *cyclic.Int,
+	inputs, results []*cyclic.IntBuffer,
+	op func(inputs, results []*cyclic.IntBuffer) error) error {
+	getters := make([]intGetter, 0, len(inputs)+len(results)+1)
+	getters = append(getters, intSlice(make([]*cyclic.Int, len(dummy))))
+	for _, b := range inputs {
+		getters = append(getters, b)
+	}
+	for _, b := range results {
+		getters = append(getters, b)
+	}
+	err := checkLengths("SkipDummies", getters...)
+	if err != nil {
+		return err
+	}
+
+	var kept []uint32
+	for i, isDummy := range dummy {
+		if !isDummy {
+			kept = append(kept, uint32(i))
+		}
+	}
+	compacted := make(map[*cyclic.IntBuffer]*cyclic.IntBuffer,
+		len(inputs)+len(results))
+	compact := func(b *cyclic.IntBuffer) *cyclic.IntBuffer {
+		if c, ok := compacted[b]; ok {
+			return c
+		}
+		c := compactSlots(g, b, kept)
+		compacted[b] = c
+		return c
+	}
+	compactInputs := make([]*cyclic.IntBuffer, len(inputs))
+	for i, b := range inputs {
+		compactInputs[i] = compact(b)
+	}
+	compactResults := make([]*cyclic.IntBuffer, len(results))
+	for i, b := range results {
+		compactResults[i] = compact(b)
+	}
+
+	err = op(compactInputs, compactResults)
+	if _, ok := err.(SlotErrors); err != nil && !ok {
+		return err
+	}
+	for r, b := range results {
+		for j, slot := range kept {
+			g.Set(b.Get(slot), compactResults[r].Get(uint32(j)))
+		}
+		for i, isDummy := range dummy {
+			if isDummy {
+				g.Set(b.Get(uint32(i)), placeholder)
+			}
+		}
+	}
+	if slotErrs, ok := err.(SlotErrors); ok {
+		for i := range slotErrs {
+			slotErrs[i].Slot = kept[slotErrs[i].Slot]
+		}
+		return slotErrs
+	}
+	return nil
+}
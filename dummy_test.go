@@ -0,0 +1,75 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// op should only see the real slots, and the dummy slots should come back
+// as the placeholder
+func TestSkipDummies(t *testing.T) {
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 2, 3, 4, 5)
+	y := newDedupBuffer(g, 6, 7, 8, 9)
+	// Also an input, like ElGamal's cypher
+	acc := newDedupBuffer(g, 10, 11, 12, 13)
+	dummy := []bool{false, true, false, true}
+
+	err := SkipDummies(g, dummy, g.NewInt(1),
+		[]*cyclic.IntBuffer{x, y, acc}, []*cyclic.IntBuffer{acc},
+		func(inputs, results []*cyclic.IntBuffer) error {
+			if inputs[0].Len() != 2 {
+				t.Errorf("op got %v slots, expected 2", inputs[0].Len())
+			}
+			if inputs[2] != results[0] {
+				t.Error("A buffer that's an input and a result should be " +
+					"passed as the same buffer")
+			}
+			for i := uint32(0); i < uint32(inputs[0].Len()); i++ {
+				g.Mul(inputs[0].Get(i), inputs[1].Get(i), results[0].Get(i))
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int64{12, 1, 32, 1}
+	for i, e := range expected {
+		if acc.Get(uint32(i)).Cmp(g.NewInt(e)) != 0 {
+			t.Errorf("Slot %v was %v, expected %v", i,
+				acc.Get(uint32(i)).Text(10), e)
+		}
+	}
+}
+
+// Slot errors from the op should name the slots that were passed in
+func TestSkipDummies_SlotErrors(t *testing.T) {
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 2, 3, 4)
+	z := newDedupBuffer(g, 1, 1, 1)
+	err := SkipDummies(g, []bool{true, false, false}, g.NewInt(1),
+		[]*cyclic.IntBuffer{x}, []*cyclic.IntBuffer{z},
+		func(inputs, results []*cyclic.IntBuffer) error {
+			return SlotErrors{{Slot: 1, Err: errors.New("bad")}}
+		})
+	slotErrs, ok := err.(SlotErrors)
+	if !ok || len(slotErrs) != 1 || slotErrs[0].Slot != 2 {
+		t.Errorf("Expected an error for slot 2, got %v", err)
+	}
+
+	err = SkipDummies(g, []bool{true}, g.NewInt(1),
+		[]*cyclic.IntBuffer{x}, []*cyclic.IntBuffer{z},
+		func(inputs, results []*cyclic.IntBuffer) error { return nil })
+	if _, ok := err.(ErrSizeMismatch); !ok {
+		t.Errorf("Expected ErrSizeMismatch for a short mask, got %v", err)
+	}
+}
@@ -26,3 +26,13 @@ func (ElGamalChunkPrototype) GetInputSize() uint32 {
 func (ElGamalChunkPrototype) GetName() string {
 	return "ElGamalChunk"
 }
+
+// RequiresGPU implements Cryptop
+func (ElGamalChunkPrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (ElGamalChunkPrototype) PrefersGPU() bool {
+	return true
+}
@@ -17,7 +17,10 @@ import "C"
 
 import (
 	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"time"
 )
 
 // elgamal_gpu.go contains the CUDA ops for the ElGamal operation. ElGamal(...)
@@ -33,34 +36,58 @@ const (
 var ElGamalChunk ElGamalChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int,
 	ecrKey, cypher *cyclic.IntBuffer) error {
+	err := checkLengths("ElGamalChunk", ecrKey, key, privateKey, cypher)
+	if err != nil {
+		return err
+	}
+	env, err := envFor("ElGamalChunk", g)
+	if err != nil {
+		return err
+	}
 	// Populate ElGamal inputs
 	numSlots := uint32(ecrKey.Len())
 
-	env := chooseEnv(g)
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	maxSlotsElGamal := uint32(env.maxSlots(len(stream.cpuData), kernelElgamal))
-	if numSlots > maxSlotsElGamal {
-		jww.WARN.Printf("Running multiple kernels for ElgamalChunk. Performance may be degraded")
-	}
-	for i := uint32(0); i < numSlots; i += maxSlotsElGamal {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsElGamal <= numSlots {
-			sliceEnd += maxSlotsElGamal
-		} else {
-			sliceEnd = numSlots
+	busy := p.busy()
+	maxSlotsElGamal := uint32(env.maxSlots(p.memSize, kernelElgamal))
+	batchSize := p.governor.batchSize("ElGamalChunk", maxSlotsElGamal)
+	err = p.runHybrid("ElGamalChunk", numSlots, maxSlotsElGamal, busy, func(start, end uint32) error {
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		if end-start > maxSlotsElGamal {
+			jww.WARN.Printf("Running multiple kernels for ElgamalChunk. Performance may be degraded")
+		}
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				return elGamal(g, key.GetSubBuffer(i, sliceEnd), privateKey.GetSubBuffer(i, sliceEnd),
+					publicCypherKey, ecrKey.GetSubBuffer(i, sliceEnd), cypher.GetSubBuffer(i, sliceEnd), env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("ElGamalChunk", sliceEnd-i, time.Since(batchStart))
 		}
-		err := <-elGamal(g, key.GetSubBuffer(i, sliceEnd), privateKey.GetSubBuffer(i, sliceEnd),
-			publicCypherKey, ecrKey.GetSubBuffer(i, sliceEnd), cypher.GetSubBuffer(i, sliceEnd), env, stream)
-		if err != nil {
-			return err
+		return nil
+	}, func(start, end uint32) {
+		for i := start; i < end; i++ {
+			cryptops.ElGamal(g, key.Get(i), privateKey.Get(i), publicCypherKey,
+				ecrKey.Get(i), cypher.Get(i))
 		}
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return checkResults(g, ecrKey, cypher)
 }
 
 // ElGamal runs the op on the GPU
@@ -72,72 +99,8 @@ var ElGamalChunk ElGamalChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 // TODO validate BN length in code (i.e. pick kernel variants based on bn length)
 func elGamal(g *cyclic.Group, key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int,
 	ecrKey, cypher *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
-	// Return the result later, when the GPU job finishes
-	resultChan := make(chan error, 1)
-	go func() {
-		// Arrange memory into stream buffers
-		numSlots := uint32(key.Len())
-
-		// TODO clean this up by implementing the
-		// arrangement/dearrangement with reader/writer interfaces
-		//  or smth
-		constants := stream.getCpuConstantsWords(env, kernelElgamal)
-		offset := 0
-		bnLengthWords := env.getWordLen()
-		putBits(constants[offset:offset+bnLengthWords], g.GetG().Bits(),
-			bnLengthWords)
-		offset += bnLengthWords
-		putBits(constants[offset:offset+bnLengthWords],
-			g.GetP().Bits(), bnLengthWords)
-		offset += bnLengthWords
-		putBits(constants[offset:offset+bnLengthWords],
-			publicCypherKey.Bits(), bnLengthWords)
-
-		inputs := stream.getCpuInputsWords(env, kernelElgamal, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			putBits(inputs[offset:offset+bnLengthWords],
-				privateKey.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords],
-				key.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords],
-				ecrKey.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords],
-				cypher.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
-
-		// Upload, run, wait for download
-		err := env.enqueue(stream, kernelElgamal, int(numSlots))
-		if err != nil {
-			resultChan <- err
-			return
-		}
-		// Results will be stored in this buffer
-		results := stream.getCpuOutputsWords(env, kernelElgamal, int(numSlots))
-
-		// Wait on things to finish with Cuda
-		err = get(stream)
-		if err != nil {
-			resultChan <- err
-			return
-		}
-
-		// Everything is OK, so let's go ahead and import the results
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			end := offset + bnLengthWords
-			g.OverwriteBits(ecrKey.Get(i), results[offset:end])
-			offset += bnLengthWords
-			end = offset + bnLengthWords
-			g.OverwriteBits(cypher.Get(i), results[offset:end])
-			offset += bnLengthWords
-		}
-
-		resultChan <- nil
-	}()
-	return resultChan
+	return runKernel(g, kernelElgamal,
+		[]large.Bits{g.GetG().Bits(), g.GetP().Bits(), publicCypherKey.Bits()},
+		[]intGetter{privateKey, key, ecrKey, cypher},
+		[]intGetter{ecrKey, cypher}, env, stream)
 }
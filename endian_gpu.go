@@ -0,0 +1,20 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+//+build armbe arm64be mips mips64 mips64p32 ppc ppc64 s390 s390x sparc sparc64
+
+package gpumaths
+
+// endian_gpu.go stops the GPU build on big-endian hosts. Operands are copied
+// into the stream's buffers as big.Words, and CGBN reads those buffers as
+// little-endian 32-bit limbs, which is only the same thing on a
+// little-endian host. On a big-endian host every number would be silently
+// scrambled, so this refers to a name that doesn't exist, and the build
+// fails with the name as its error.
+
+var _ = gpumathsRequiresALittleEndianHost
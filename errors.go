@@ -0,0 +1,213 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"math/big"
+	"strings"
+)
+
+// errors.go classifies the errors that come back from the CUDA library, so
+// that failures which are likely to go away when a batch is run again (ECC
+// errors, Xid events, launch timeouts) can be retried instead of failing the
+// whole round. It also has the errors that ops return when they're passed
+// buffers that don't line up, or when only some slots of a chunk came back
+// bad.
+
+// transientErrStrs holds lower case substrings of CUDA error names and
+// descriptions that indicate a transient failure. Launches that ask for too
+// many resources fail the same way every time, and an unspecified launch
+// failure breaks the whole context, so every stream would fail it again.
+// Neither is worth retrying.
+var transientErrStrs = []string{
+	"ecc",
+	"xid",
+	"launch timed out",
+	"launch_timeout",
+	"launchtimeout",
+}
+
+// IsTransient returns true if the error is one that may not happen again if
+// the batch is rerun on another stream. All other errors are fatal.
+func IsTransient(err error) bool {
+	if err == nil || isRejected(err) {
+		return false
+	}
+	errStr := strings.ToLower(err.Error())
+	for _, s := range transientErrStrs {
+		if strings.Contains(errStr, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrSizeMismatch is returned by an op before it does anything if the
+// buffers passed to it don't all have the same number of slots
+type ErrSizeMismatch struct {
+	Op string
+	// Which of the op's buffers had the wrong length, counting from zero
+	Buffer   int
+	Expected int
+	Got      int
+}
+
+func (e ErrSizeMismatch) Error() string {
+	return fmt.Sprintf("%v: buffer %v has %v slots, but expected %v",
+		e.Op, e.Buffer, e.Got, e.Expected)
+}
+
+// ErrUnsupportedGroup is returned by an op before it does anything if the
+// GPU kernels can't do arithmetic in its group
+type ErrUnsupportedGroup struct {
+	Op        string
+	PrimeBits int
+	Reason    string
+}
+
+func (e ErrUnsupportedGroup) Error() string {
+	return fmt.Sprintf("%v: can't use a group with a %v bit prime: %v",
+		e.Op, e.PrimeBits, e.Reason)
+}
+
+// checkLengths returns ErrSizeMismatch if any of the buffers don't have the
+// same length as the first one
+func checkLengths(op string, buffers ...intGetter) error {
+	for i := 1; i < len(buffers); i++ {
+		if buffers[i].Len() != buffers[0].Len() {
+			return ErrSizeMismatch{
+				Op:       op,
+				Buffer:   i,
+				Expected: buffers[0].Len(),
+				Got:      buffers[i].Len(),
+			}
+		}
+	}
+	return nil
+}
+
+// ErrTruncatedResults is returned when the library says a batch finished,
+// but some of the batch's slots were never written with results
+type ErrTruncatedResults struct {
+	Kernel string
+	Slots  int
+	// How many slots have no results, and the first of them
+	Missing int
+	First   int
+}
+
+func (e ErrTruncatedResults) Error() string {
+	return fmt.Sprintf("%v: %v of %v slots have no results, starting at "+
+		"slot %v", e.Kernel, e.Missing, e.Slots, e.First)
+}
+
+// ErrRejected is returned by an op when an OnResults hook rejected the
+// results of one of its batches. The batch ran, so it isn't retried and
+// doesn't count against the stream it ran on or the health of the GPU.
+type ErrRejected struct {
+	Kernel string
+	// What the hook returned
+	Err error
+}
+
+func (e ErrRejected) Error() string {
+	return fmt.Sprintf("%v: results were rejected: %v", e.Kernel, e.Err)
+}
+
+// isRejected returns whether err is, or wraps, ErrRejected
+func isRejected(err error) bool {
+	_, ok := errors.Cause(err).(ErrRejected)
+	return ok
+}
+
+// ErrCancelled is returned by an op whose batches were tagged with a tag
+// that was passed to StreamPool.CancelTag. Some of the op's slots may have
+// results and the rest don't.
+var ErrCancelled = errors.New("batch's tag was cancelled")
+
+// SlotError describes a bad result in one slot of a chunk
+type SlotError struct {
+	Slot uint32
+	Err  error
+}
+
+func (e SlotError) Error() string {
+	return fmt.Sprintf("slot %v: %v", e.Slot, e.Err)
+}
+
+// SlotErrors is returned by an op when some of the slots in its chunk came
+// back with bad results. The results in the other slots are good.
+type SlotErrors []SlotError
+
+func (e SlotErrors) Error() string {
+	errStrs := make([]string, len(e))
+	for i := range e {
+		errStrs[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%v slots had bad results: %v", len(e),
+		strings.Join(errStrs, "; "))
+}
+
+// checkResults makes sure that every result that an op produced is in the
+// group, and returns SlotErrors listing the slots where one isn't
+func checkResults(g *cyclic.Group, results ...intGetter) error {
+	var slotErrs SlotErrors
+	for i := uint32(0); len(results) > 0 && i < uint32(results[0].Len()); i++ {
+		for j := range results {
+			if !g.Inside(results[j].Get(i).GetLargeInt()) {
+				slotErrs = append(slotErrs, SlotError{
+					Slot: i,
+					Err:  errors.Errorf("result %v isn't in the group", j),
+				})
+			}
+		}
+	}
+	if len(slotErrs) > 0 {
+		return slotErrs
+	}
+	return nil
+}
+
+// markUnwritten fills a stream's outputs with set bits before a batch runs.
+// A slot whose outputs are still all set bits afterwards was never written,
+// as every result is less than p, so it can't fill its whole bignum.
+func markUnwritten(outputs large.Bits) {
+	for i := range outputs {
+		outputs[i] = ^big.Word(0)
+	}
+}
+
+// checkWritten returns ErrTruncatedResults if any of the numSlots slots of
+// slotWords words each in outputs still holds what markUnwritten left there
+func checkWritten(kernel string, outputs large.Bits, numSlots,
+	slotWords int) error {
+	e := ErrTruncatedResults{Kernel: kernel, Slots: numSlots}
+	for slot := 0; slot < numSlots; slot++ {
+		written := false
+		for _, w := range outputs[slot*slotWords : (slot+1)*slotWords] {
+			if ^w != 0 {
+				written = true
+				break
+			}
+		}
+		if !written {
+			if e.Missing == 0 {
+				e.First = slot
+			}
+			e.Missing++
+		}
+	}
+	if e.Missing > 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// Errors from ECC, Xid, and launch timeouts should be retried, and
+// everything else should not
+func TestIsTransient(t *testing.T) {
+	transient := []string{
+		"uncorrectable ECC error encountered",
+		"CUDA_ERROR_ECC_UNCORRECTABLE",
+		"NVRM: Xid (PCI:0000:01:00): 48, pid=1234",
+		"the launch timed out and was terminated",
+	}
+	for _, s := range transient {
+		if !IsTransient(errors.New(s)) {
+			t.Errorf("%q should have been transient", s)
+		}
+	}
+
+	fatal := []string{
+		"out of memory",
+		"invalid argument",
+		"no CUDA-capable device is detected",
+		"cudaErrorLaunchFailure: unspecified launch failure",
+		"too many resources requested for launch",
+		"CUDA_ERROR_LAUNCH_OUT_OF_RESOURCES",
+	}
+	for _, s := range fatal {
+		if IsTransient(errors.New(s)) {
+			t.Errorf("%q shouldn't have been transient", s)
+		}
+	}
+
+	if IsTransient(nil) {
+		t.Error("nil error shouldn't have been transient")
+	}
+	// A hook's reason for rejecting results can say anything
+	rejected := ErrRejected{Kernel: "mul2", Err: errors.New("ECC mismatch")}
+	if IsTransient(rejected) {
+		t.Error("Rejected results shouldn't have been transient")
+	}
+}
+
+// Results outside the group should be reported by slot
+func TestCheckResults(t *testing.T) {
+	g := makeTestGroup2048()
+	good := g.NewIntBuffer(4, g.NewInt(5))
+	bad := g.NewIntBuffer(4, g.NewInt(5))
+	// This is how results get written after running a kernel
+	g.OverwriteBits(bad.Get(1), g.GetP().Bits())
+	g.OverwriteBits(bad.Get(3), large.Bits{0})
+
+	if err := checkResults(g, good); err != nil {
+		t.Errorf("Good results shouldn't have errors: %v", err)
+	}
+	err := checkResults(g, good, bad)
+	slotErrs, ok := err.(SlotErrors)
+	if !ok {
+		t.Fatalf("Expected SlotErrors, got %v", err)
+	}
+	if len(slotErrs) != 2 || slotErrs[0].Slot != 1 || slotErrs[1].Slot != 3 {
+		t.Errorf("Expected errors in slots 1 and 3, got %v", slotErrs)
+	}
+	if checkResults(g) != nil {
+		t.Error("No results shouldn't have errors")
+	}
+}
+
+// Buffers of different lengths should be caught, and say which one was off
+func TestCheckLengths(t *testing.T) {
+	g := makeTestGroup2048()
+	a := g.NewIntBuffer(4, g.NewInt(1))
+	b := g.NewIntBuffer(4, g.NewInt(1))
+	c := g.NewIntBuffer(3, g.NewInt(1))
+
+	if err := checkLengths("ExpChunk", a, b); err != nil {
+		t.Errorf("Equal lengths shouldn't have errors: %v", err)
+	}
+	err := checkLengths("ExpChunk", a, b, c)
+	mismatch, ok := err.(ErrSizeMismatch)
+	if !ok {
+		t.Fatalf("Expected ErrSizeMismatch, got %v", err)
+	}
+	expected := ErrSizeMismatch{Op: "ExpChunk", Buffer: 2, Expected: 4, Got: 3}
+	if mismatch != expected {
+		t.Errorf("Expected %+v, got %+v", expected, mismatch)
+	}
+}
+
+// Slots that still hold the mark should be reported, and slots with any
+// result in them shouldn't
+func TestCheckWritten(t *testing.T) {
+	const slotWords = 4
+	outputs := make(large.Bits, 3*slotWords)
+	markUnwritten(outputs)
+	err := checkWritten("MUL2", outputs, 3, slotWords)
+	if e, ok := err.(ErrTruncatedResults); !ok || e.Missing != 3 ||
+		e.First != 0 {
+		t.Errorf("Expected all 3 slots to be missing, got %v", err)
+	}
+
+	// A result of p-1 in a group that fills the bignum still has a clear bit
+	outputs[0] = 0
+	outputs[slotWords+slotWords-1] = outputs[slotWords+slotWords-1] >> 1
+	err = checkWritten("MUL2", outputs, 3, slotWords)
+	if e, ok := err.(ErrTruncatedResults); !ok || e.Missing != 1 ||
+		e.First != 2 {
+		t.Errorf("Expected just slot 2 to be missing, got %v", err)
+	}
+
+	err = checkWritten("MUL2", outputs, 2, slotWords)
+	if err != nil {
+		t.Errorf("Expected no missing slots, got %v", err)
+	}
+}
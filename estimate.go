@@ -0,0 +1,92 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"time"
+)
+
+// estimate.go learns how long each op's batches take on the GPU, so that
+// callers can tell ahead of time whether running a phase there will finish
+// before the round's deadline.
+
+// How much of the weight of past batches is kept each time a new one is
+// recorded. Older batches fade out so the model follows changes in clocks
+// and load.
+const estimateDecay = 0.9
+
+// batchModel fits the time a batch takes as a fixed cost per batch plus a
+// cost per slot, by least squares weighted towards recent batches
+type batchModel struct {
+	// Weighted sums of 1, n, n², t and nt over the recorded batches, with
+	// n the number of slots and t the seconds taken
+	w, n, nn, t, nt float64
+	// The biggest batch seen, which is how big the batches of a long chunk
+	// will be
+	maxBatch uint32
+}
+
+// estimator holds a batchModel for each op
+type estimator struct {
+	sync.Mutex
+	models map[string]*batchModel
+}
+
+func newEstimator() *estimator {
+	return &estimator{models: make(map[string]*batchModel)}
+}
+
+// record adds a batch of numSlots slots of op that took elapsed
+func (e *estimator) record(op string, numSlots uint32, elapsed time.Duration) {
+	if numSlots == 0 || elapsed <= 0 {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	m, ok := e.models[op]
+	if !ok {
+		m = &batchModel{}
+		e.models[op] = m
+	}
+	n, t := float64(numSlots), elapsed.Seconds()
+	m.w = estimateDecay*m.w + 1
+	m.n = estimateDecay*m.n + n
+	m.nn = estimateDecay*m.nn + n*n
+	m.t = estimateDecay*m.t + t
+	m.nt = estimateDecay*m.nt + n*t
+	if numSlots > m.maxBatch {
+		m.maxBatch = numSlots
+	}
+}
+
+// estimate returns how long numSlots slots of op should take, run in batches
+// as big as the biggest one recorded. It returns 0 if no batches of op have
+// been recorded.
+func (e *estimator) estimate(op string, numSlots uint32) time.Duration {
+	e.Lock()
+	defer e.Unlock()
+	m, ok := e.models[op]
+	if !ok || numSlots == 0 {
+		return 0
+	}
+	var perBatch, perSlot float64
+	det := m.w*m.nn - m.n*m.n
+	// Until batches of different sizes have been seen, there's no telling
+	// the fixed cost from the cost per slot, so it's all put on the slots
+	if det > 1e-9*m.w*m.nn {
+		perSlot = (m.w*m.nt - m.n*m.t) / det
+		perBatch = (m.t - perSlot*m.n) / m.w
+	}
+	if perSlot <= 0 || perBatch < 0 {
+		perSlot, perBatch = m.t/m.n, 0
+	}
+	numBatches := (numSlots + m.maxBatch - 1) / m.maxBatch
+	seconds := float64(numBatches)*perBatch + float64(numSlots)*perSlot
+	return time.Duration(seconds * float64(time.Second))
+}
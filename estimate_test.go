@@ -0,0 +1,52 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"testing"
+	"time"
+)
+
+// Batches that take 2ms plus 10µs a slot should be estimated as such, and
+// chunks bigger than a batch should pay the fixed cost once per batch
+func TestEstimator(t *testing.T) {
+	e := newEstimator()
+	if e.estimate("ExpChunk", 100) != 0 {
+		t.Error("Ops with no batches shouldn't have estimates")
+	}
+	for _, n := range []uint32{100, 400, 1000, 250, 1000} {
+		e.record("ExpChunk", n, 2*time.Millisecond+time.Duration(n)*10*time.Microsecond)
+	}
+	expected := map[uint32]time.Duration{
+		500:  7 * time.Millisecond,
+		1000: 12 * time.Millisecond,
+		// Three batches of at most 1000
+		2500: 31 * time.Millisecond,
+	}
+	for n, want := range expected {
+		got := e.estimate("ExpChunk", n)
+		if got < want-time.Microsecond || got > want+time.Microsecond {
+			t.Errorf("%v slots should take %v, not %v", n, want, got)
+		}
+	}
+	if e.estimate("Mul2Chunk", 100) != 0 {
+		t.Error("Estimates shouldn't carry over between ops")
+	}
+}
+
+// With only one batch size seen, the time should scale with the slots
+func TestEstimator_OneSize(t *testing.T) {
+	e := newEstimator()
+	e.record("RevealChunk", 200, 4*time.Millisecond)
+	e.record("RevealChunk", 200, 4*time.Millisecond)
+	got := e.estimate("RevealChunk", 100)
+	if got < 2*time.Millisecond-time.Microsecond ||
+		got > 2*time.Millisecond+time.Microsecond {
+		t.Errorf("100 slots should take 2ms, not %v", got)
+	}
+}
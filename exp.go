@@ -7,7 +7,11 @@
 
 package gpumaths
 
-import "gitlab.com/elixxir/crypto/cyclic"
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
 
 // exp.go contains the input, results, and other types for running the
 // exp operation against the GPU. The actual GPU call is in exp_gpu.go
@@ -22,7 +26,78 @@ func (ExpChunkPrototype) GetName() string {
 	return "ExpChunk"
 }
 
+// RequiresGPU implements Cryptop
+func (ExpChunkPrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (ExpChunkPrototype) PrefersGPU() bool {
+	return true
+}
+
 // GetInputSize is the size of each chunk for this op
 func (ExpChunkPrototype) GetInputSize() uint32 {
 	return 64
 }
+
+// ExponentSharer hands out exponents as two additive shares mod p-1, for
+// exponents that must never be on the host whole, such as private keys held
+// in an HSM. An implementation would keep a handle to each key, and have the
+// HSM blind the key with a fresh random share and return only the two
+// halves.
+type ExponentSharer interface {
+	// Share fills shares1 and shares2, which are end-start slots long, so
+	// that shares1[i]+shares2[i] = y[start+i] mod p-1, where y are the
+	// exponents the sharer stands for
+	Share(start, end uint32, shares1, shares2 *cyclic.IntBuffer) error
+}
+
+// ExpSharedChunkPrototype is ExpChunk with exponents that come as shares
+// from an ExponentSharer
+type ExpSharedChunkPrototype func(p *StreamPool, g *cyclic.Group,
+	x *cyclic.IntBuffer, y ExponentSharer, z *cyclic.IntBuffer) error
+
+// GetName returns name of op (ExpSharedChunk)
+func (ExpSharedChunkPrototype) GetName() string {
+	return "ExpSharedChunk"
+}
+
+// RequiresGPU implements Cryptop
+func (ExpSharedChunkPrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (ExpSharedChunkPrototype) PrefersGPU() bool {
+	return true
+}
+
+// GetInputSize is the size of each chunk for this op
+func (ExpSharedChunkPrototype) GetInputSize() uint32 {
+	return 64
+}
+
+// splitExponents fills shares1 and shares2 so that for each slot, the two
+// shares are uniformly random but add up to the exponent mod p-1. Raising an
+// element of the group to each share and multiplying the results gives the
+// same answer as raising it to the exponent, but neither share on its own
+// says anything about the exponent.
+func splitExponents(g *cyclic.Group, y, shares1, shares2 *cyclic.IntBuffer) error {
+	pSub1 := g.GetPSub1().GetLargeInt()
+	// Extra random bytes make the bias from reducing mod p-1 negligible
+	randomBytes := make([]byte, len(g.GetPBytes())+8)
+	for i := uint32(0); i < uint32(y.Len()); i++ {
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return err
+		}
+		share1 := large.NewIntFromBytes(randomBytes)
+		share1.Mod(share1, pSub1)
+		share2 := large.NewInt(0).Sub(y.Get(i).GetLargeInt(), share1)
+		share2.Mod(share2, pSub1)
+		g.OverwriteBits(shares1.Get(i), share1.Bits())
+		g.OverwriteBits(shares2.Get(i), share2.Bits())
+	}
+	return nil
+}
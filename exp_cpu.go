@@ -19,3 +19,9 @@ var ExpChunk ExpChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
 	return z, errors.New(NoGpuErrStr)
 }
+
+// ExpSharedChunk is stubbed unless GPU is present.
+var ExpSharedChunk ExpSharedChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	x *cyclic.IntBuffer, y ExponentSharer, z *cyclic.IntBuffer) error {
+	return errors.New(NoGpuErrStr)
+}
@@ -15,8 +15,12 @@ package gpumaths
 */
 import "C"
 import (
+	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"time"
 )
 
 // exp_gpu.go contains the CUDA ops for the exp operation. exp(...)
@@ -33,87 +37,197 @@ const (
 // on the kernel to finish
 var ExpChunk ExpChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
+	if p != nil && p.dedupSlots {
+		return expDeduped(p, g, x, y, z)
+	}
+	return expChunk(p, g, x, y, z)
+}
+
+// expDeduped runs expChunk on one slot for each distinct pair of operands,
+// and copies the results out to the slots that repeat them
+func expDeduped(p *StreamPool, g *cyclic.Group,
+	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
+	err := checkLengths("ExpChunk", z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	unique, copyOf := uniqueSlots(x, y)
+	if len(unique) == z.Len() {
+		return expChunk(p, g, x, y, z)
+	}
+	compact := g.NewIntBuffer(uint32(len(unique)), g.NewInt(1))
+	_, err = expChunk(p, g, compactSlots(g, x, unique),
+		compactSlots(g, y, unique), compact)
+	if _, ok := err.(SlotErrors); err != nil && !ok {
+		return nil, err
+	}
+	fanOut(g, compact, z, copyOf)
+	if err != nil {
+		return z, fanOutErr(err, copyOf)
+	}
+	return z, nil
+}
+
+// expChunk does the work of ExpChunk
+func expChunk(p *StreamPool, g *cyclic.Group,
+	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
+	err := checkLengths("ExpChunk", z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	env, err := envFor("ExpChunk", g)
+	if err != nil {
+		return nil, err
+	}
 	// Populate exp inputs
 	numSlots := uint32(z.Len())
 
 	// Run kernel on the inputs, simply using smaller chunks if passed
 	// chunk size exceeds buffer space in stream
+	busy := p.busy()
+	maxSlotsExp := uint32(env.maxSlots(p.memSize, kernelPowmOdd))
+	batchSize := p.governor.batchSize("ExpChunk", maxSlotsExp)
+	err = p.runHybrid("ExpChunk", numSlots, maxSlotsExp, busy, func(start, end uint32) error {
+		// The stream is taken once the CPU has started on its share, so
+		// that the CPU isn't idle while this waits for one. It can be
+		// swapped out if a batch is retried, so the deferred return must
+		// look at it when the function returns.
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		if end-start > maxSlotsExp {
+			jww.WARN.Printf("Running multiple kernels for ExpChunk. Performance may be degraded")
+		}
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			run := exp
+			if p.blindExponents {
+				run = expBlinded
+			}
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				return run(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), z.GetSubBuffer(i, sliceEnd), env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("ExpChunk", sliceEnd-i, time.Since(batchStart))
+		}
+		return nil
+	}, func(start, end uint32) {
+		for i := start; i < end; i++ {
+			cryptops.Exp(g, x.Get(i), y.Get(i), z.Get(i))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = checkResults(g, z)
+	if err != nil {
+		// The results in the slots that aren't listed are still good
+		return z, err
+	}
+
+	// If there were no errors, we return z
+	return z, nil
+}
+
+// ExpSharedChunk sets z[i] = x[i]**y[i] mod p, where the exponents y are
+// only ever seen as the two shares the sharer gives for each slot. Shares
+// are requested one batch at a time, just before the batch is uploaded.
+// Nothing runs on the CPU, because the CPU never has the exponents either.
+var ExpSharedChunk ExpSharedChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	x *cyclic.IntBuffer, y ExponentSharer, z *cyclic.IntBuffer) error {
+	err := checkLengths("ExpSharedChunk", z, x)
+	if err != nil {
+		return err
+	}
+	env, err := envFor("ExpSharedChunk", g)
+	if err != nil {
+		return err
+	}
+	numSlots := uint32(z.Len())
+
 	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	env := chooseEnv(g)
+	defer func() { p.ReturnStream(stream) }()
 	maxSlotsExp := uint32(env.maxSlots(len(stream.cpuData), kernelPowmOdd))
-	if numSlots > maxSlotsExp {
-		jww.WARN.Printf("Running multiple kernels for ExpChunk. Performance may be degraded")
-	}
-	for i := uint32(0); i < numSlots; i += maxSlotsExp {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsExp <= numSlots {
-			sliceEnd += maxSlotsExp
-		} else {
+	batchSize := p.governor.batchSize("ExpSharedChunk", maxSlotsExp)
+	for i := uint32(0); i < numSlots; i += batchSize {
+		sliceEnd := i + batchSize
+		if sliceEnd > numSlots {
 			sliceEnd = numSlots
 		}
-		err := <-exp(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), z.GetSubBuffer(i, sliceEnd), env, stream)
+		shares1 := g.NewIntBuffer(sliceEnd-i, g.NewInt(1))
+		shares2 := g.NewIntBuffer(sliceEnd-i, g.NewInt(1))
+		err = y.Share(i, sliceEnd, shares1, shares2)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't get exponent shares for "+
+				"slots %v to %v", i, sliceEnd)
+		}
+		batchStart := time.Now()
+		stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+			return expShares(g, x.GetSubBuffer(i, sliceEnd), shares1,
+				shares2, z.GetSubBuffer(i, sliceEnd), env, s)
+		})
 		if err != nil {
-			return nil, err
+			return err
 		}
+		p.recordTiming("ExpSharedChunk", sliceEnd-i, time.Since(batchStart))
 	}
+	return checkResults(g, z)
+}
 
-	// If there were no errors, we return z
-	return z, nil
+// expBlinded computes the same thing as exp, but never puts the exponents
+// in GPU memory. Each exponent is split into two random shares, the GPU
+// raises x to each share in separate batches, and the results are
+// multiplied on the CPU. Someone who can read the device's memory during
+// both batches can still add the shares back up, so this only makes that
+// harder, not impossible.
+func expBlinded(g *cyclic.Group, x, y, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+	numSlots := uint32(y.Len())
+	shares1 := g.NewIntBuffer(numSlots, g.NewInt(1))
+	shares2 := g.NewIntBuffer(numSlots, g.NewInt(1))
+	err := splitExponents(g, y, shares1, shares2)
+	if err != nil {
+		resultChan := make(chan error, 1)
+		resultChan <- err
+		return resultChan
+	}
+	return expShares(g, x, shares1, shares2, result, env, stream)
 }
 
-func exp(g *cyclic.Group, x, y, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
-	// Return the result later, when the GPU job finishes
+// expShares raises x to each of the shares in separate batches, and
+// multiplies the results on the CPU
+func expShares(g *cyclic.Group, x, shares1, shares2, result *cyclic.IntBuffer,
+	env gpumathsEnv, stream Stream) chan error {
 	resultChan := make(chan error, 1)
 	go func() {
-		// Arrange memory into stream buffers
-		numSlots := uint32(x.Len())
-
-		// TODO clean this up by implementing the
-		// arrangement/dearrangement with reader/writer interfaces
-		// or smth
-		constants := stream.getCpuConstantsWords(env, kernelPowmOdd)
-		offset := 0
-		bnLengthWords := env.getWordLen()
-		putBits(constants, g.GetP().Bits(), bnLengthWords)
-
-		inputs := stream.getCpuInputsWords(env, kernelPowmOdd, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords], y.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
-
-		// Upload, run, wait for download
-		err := env.enqueue(stream, kernelPowmOdd, int(numSlots))
+		err := <-exp(g, x, shares1, result, env, stream)
 		if err != nil {
 			resultChan <- err
 			return
 		}
-
-		// Results will be stored in this buffer
-		// This intermediary copy is necessary because the byte order needs to be reversed
-		results := stream.getCpuOutputsWords(env, kernelPowmOdd, int(numSlots))
-
-		// Wait on things to finish with Cuda
-		err = get(stream)
+		partial := g.NewIntBuffer(uint32(x.Len()), g.NewInt(1))
+		err = <-exp(g, x, shares2, partial, env, stream)
 		if err != nil {
 			resultChan <- err
 			return
 		}
-
-		// Everything is OK, so let's go ahead and import the results
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			end := offset + bnLengthWords
-			g.OverwriteBits(result.Get(i), results[offset:end])
-			offset += bnLengthWords
+		for i := uint32(0); i < uint32(x.Len()); i++ {
+			g.Mul(result.Get(i), partial.Get(i), result.Get(i))
 		}
-
 		resultChan <- nil
 	}()
 	return resultChan
 }
+
+func exp(g *cyclic.Group, x, y, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+	return runKernel(g, kernelPowmOdd, []large.Bits{g.GetP().Bits()},
+		[]intGetter{x, y}, []intGetter{result}, env, stream)
+}
@@ -12,6 +12,7 @@ package gpumaths
 import (
 	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
 	"testing"
 )
 
@@ -69,7 +70,147 @@ func TestExp(t *testing.T) {
 	}
 }
 
+// Blinded exponentiation should get the same results as the CPU
+func TestExpBlinded(t *testing.T) {
+	batchSize := uint32(32)
+	grp := initExp()
+
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+
+	zCPU := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	zGPU := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+
+	expCPU(batchSize, grp, x, y, zCPU)
+
+	streamPool, err := NewStreamPool(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPool.SetExponentBlinding(true)
+	expGPU(t, streamPool, grp, x, y, zGPU)
+
+	for i := uint32(0); i < batchSize; i++ {
+		if zGPU.Get(i).Cmp(zCPU.Get(i)) != 0 {
+			t.Errorf("blinded exp mismatch on index %d", i)
+		}
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Slots that repeat another slot's operands should still get their results
+func TestExpChunk_Dedup(t *testing.T) {
+	batchSize := uint32(32)
+	grp := initExp()
+
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	// Pad the second half with copies of the first slot, like dummy slots
+	for i := batchSize / 2; i < batchSize; i++ {
+		grp.Set(x.Get(i), x.Get(0))
+		grp.Set(y.Get(i), y.Get(0))
+	}
+
+	zCPU := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	zGPU := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+
+	expCPU(batchSize, grp, x, y, zCPU)
+
+	streamPool, err := NewStreamPool(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPool.SetSlotDedup(true)
+	expGPU(t, streamPool, grp, x, y, zGPU)
+
+	for i := uint32(0); i < batchSize; i++ {
+		if zGPU.Get(i).Cmp(zCPU.Get(i)) != 0 {
+			t.Errorf("deduplicated exp mismatch on index %d", i)
+		}
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Stands in for an HSM by splitting exponents it knows
+type testSharer struct {
+	grp *cyclic.Group
+	y   *cyclic.IntBuffer
+}
+
+func (s testSharer) Share(start, end uint32, shares1, shares2 *cyclic.IntBuffer) error {
+	return splitExponents(s.grp, s.y.GetSubBuffer(start, end), shares1, shares2)
+}
+
+// Exponents given as shares should get the same results as the CPU, across
+// more than one batch
+func TestExpSharedChunk(t *testing.T) {
+	batchSize := uint32(100)
+	grp := initExp()
+
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+
+	zCPU := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	zGPU := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+
+	expCPU(batchSize, grp, x, y, zCPU)
+
+	streamPool, err := NewStreamPool(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ExpSharedChunk(streamPool, grp, x, testSharer{grp, y}, zGPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint32(0); i < batchSize; i++ {
+		if zGPU.Get(i).Cmp(zCPU.Get(i)) != 0 {
+			t.Errorf("shared exp mismatch on index %d", i)
+		}
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 // BenchmarkExpCPU provides a baseline with a single-threaded CPU benchmark
+// Groups too big for any kernel should be rejected before a stream is taken
+func TestExp_GroupTooBig(t *testing.T) {
+	p := large.NewInt(1).LeftShift(large.NewInt(1), 5000)
+	p.Add(p, large.NewInt(1))
+	grp := cyclic.NewGroup(p, large.NewInt(2))
+	x := grp.NewIntBuffer(2, grp.NewInt(2))
+
+	// The pool isn't needed, because nothing should reach it
+	_, err := ExpChunk(nil, grp, x, x.DeepCopy(), x.DeepCopy())
+	unsupported, ok := err.(ErrUnsupportedGroup)
+	if !ok {
+		t.Fatalf("Expected ErrUnsupportedGroup, got %v", err)
+	}
+	if unsupported.Op != "ExpChunk" || unsupported.PrimeBits != 5001 {
+		t.Errorf("Got %+v", unsupported)
+	}
+}
+
+// An even modulus should be rejected rather than run through the kernels
+func TestExp_EvenModulus(t *testing.T) {
+	grp := cyclic.NewGroup(large.NewInt(1<<20), large.NewInt(3))
+	x := grp.NewIntBuffer(2, grp.NewInt(3))
+
+	_, err := ExpChunk(nil, grp, x, x.DeepCopy(), x.DeepCopy())
+	if _, ok := err.(ErrUnsupportedGroup); !ok {
+		t.Errorf("Expected ErrUnsupportedGroup, got %v", err)
+	}
+}
+
 func runExpCPU(b *testing.B, batchSize uint32) {
 	grp := initExp()
 
@@ -0,0 +1,52 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// The shares of each exponent should add up to it mod p-1, so that
+// exponentiating by each share and multiplying gives the same result
+func TestSplitExponents(t *testing.T) {
+	g := makeTestGroup2048()
+	const numSlots = 8
+	y := g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		g.Random(y.Get(i))
+	}
+	shares1 := g.NewIntBuffer(numSlots, g.NewInt(1))
+	shares2 := g.NewIntBuffer(numSlots, g.NewInt(1))
+	err := splitExponents(g, y, shares1, shares2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pSub1 := g.GetPSub1().GetLargeInt()
+	for i := uint32(0); i < numSlots; i++ {
+		sum := large.NewInt(0).Add(shares1.Get(i).GetLargeInt(),
+			shares2.Get(i).GetLargeInt())
+		sum.Mod(sum, pSub1)
+		if sum.Cmp(y.Get(i).GetLargeInt()) != 0 {
+			t.Errorf("Shares in slot %v don't add up to the exponent", i)
+		}
+		if shares1.Get(i).Cmp(y.Get(i)) == 0 {
+			t.Errorf("Share in slot %v is the exponent itself", i)
+		}
+
+		x := g.NewInt(3)
+		expected := g.Exp(x, y.Get(i), g.NewInt(1))
+		result := g.Exp(x, shares1.Get(i), g.NewInt(1))
+		g.Mul(result, g.Exp(x, shares2.Get(i), g.NewInt(1)), result)
+		if result.Cmp(expected) != 0 {
+			t.Errorf("Exponentiating by the shares in slot %v gave the "+
+				"wrong result", i)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// faults.go lets tests make batches go wrong on purpose, so that retries,
+// disabling streams, slot errors and deadlines can be tested in CI without a
+// GPU that's actually failing. The faults themselves are set up in
+// faults_test.go, so a build without tests never injects anything, and only
+// batches run by the GPU build's kernels can be affected.
+
+// The errors that batches with injected faults fail with
+var (
+	errInjectedUpload = errors.New("injected fault: couldn't upload the batch")
+	errInjectedKernel = errors.New("injected fault: launch timed out")
+)
+
+// batchFaults are the faults that one batch gets
+type batchFaults struct {
+	upload  bool
+	kernel  bool
+	corrupt bool
+	delay   time.Duration
+}
+
+// faultHook returns the faults that the next batch should get. Tests set
+// it; outside of them it's nil.
+var faultHook func() batchFaults
+
+// takeFaults returns the faults that the next batch should get, which is
+// none unless a test has set faultHook
+func takeFaults() batchFaults {
+	if faultHook == nil {
+		return batchFaults{}
+	}
+	return faultHook()
+}
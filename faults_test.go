@@ -0,0 +1,111 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Faults says how many of the next batches should go wrong in each way.
+// A batch can get more than one kind of fault, and each count goes down by
+// one for every batch that gets that fault.
+type Faults struct {
+	// Batches that fail before they're uploaded, with an error that isn't
+	// transient
+	Upload int
+	// Batches whose kernel fails with a transient launch error, so that
+	// they're retried
+	Kernel int
+	// Batches whose first result in the first slot is zeroed, which puts
+	// it outside the group so the op returns a SlotError for it
+	CorruptSlot int
+	// Batches whose results take SlowBy longer to download
+	SlowDownload int
+	SlowBy       time.Duration
+}
+
+var injected struct {
+	sync.Mutex
+	faults Faults
+}
+
+// The hook is set once for the whole test binary, so batches that are
+// still running when a test changes the faults don't race with it
+func init() {
+	faultHook = takeInjected
+}
+
+// InjectFaults replaces the faults waiting to be injected into batches.
+// InjectFaults(Faults{}) turns injection off.
+func InjectFaults(faults Faults) {
+	injected.Lock()
+	defer injected.Unlock()
+	injected.faults = faults
+}
+
+// takeInjected returns the faults that the next batch should get, and
+// counts them off
+func takeInjected() batchFaults {
+	injected.Lock()
+	defer injected.Unlock()
+	var b batchFaults
+	f := &injected.faults
+	if f.Upload > 0 {
+		f.Upload--
+		b.upload = true
+	}
+	if f.Kernel > 0 {
+		f.Kernel--
+		b.kernel = true
+	}
+	if f.CorruptSlot > 0 {
+		f.CorruptSlot--
+		b.corrupt = true
+	}
+	if f.SlowDownload > 0 {
+		f.SlowDownload--
+		b.delay = f.SlowBy
+	}
+	return b
+}
+
+// Each kind of fault should go to exactly as many batches as asked for
+func TestInjectFaults(t *testing.T) {
+	InjectFaults(Faults{Upload: 1, Kernel: 2, SlowDownload: 1,
+		SlowBy: time.Second})
+	defer InjectFaults(Faults{})
+
+	expected := []batchFaults{
+		{upload: true, kernel: true, delay: time.Second},
+		{kernel: true},
+		{},
+	}
+	for i, e := range expected {
+		b := takeFaults()
+		if b != e {
+			t.Errorf("Batch %v got faults %+v, expected %+v", i, b, e)
+		}
+	}
+
+	InjectFaults(Faults{CorruptSlot: 1})
+	InjectFaults(Faults{})
+	if b := takeFaults(); b != (batchFaults{}) {
+		t.Errorf("Faults should be off, but a batch got %+v", b)
+	}
+}
+
+func TestIsTransient_InjectedFaults(t *testing.T) {
+	if IsTransient(errInjectedUpload) {
+		t.Error("Injected upload failures shouldn't be retried")
+	}
+	if !IsTransient(errInjectedKernel) {
+		t.Error("Injected kernel failures should be retried")
+	}
+}
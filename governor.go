@@ -0,0 +1,105 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"time"
+)
+
+// governor.go adjusts how many slots each kernel launch runs, so that
+// batches take about as long as a target latency on whatever GPU the node
+// has. Batches that take too long make the next ones smaller in proportion,
+// and batches that finish in time let the next ones grow a little, up to
+// what fits in the stream. If batches that were already cut down still take
+// too long, too many are sharing the GPU, and the auto resizer runs fewer at
+// once.
+
+// How much a batch that finished within the target lets the next one grow
+const governorGrowth = 0.125
+
+// governor holds the largest batch that each op should run
+type governor struct {
+	sync.Mutex
+	// Zero means batches are only limited by stream size
+	target time.Duration
+	limits map[string]uint32
+	// Set when a batch no bigger than its op's limit took too long
+	overran bool
+}
+
+func newGovernor() *governor {
+	return &governor{limits: make(map[string]uint32)}
+}
+
+// setTarget sets the latency to aim for, forgetting what's been learned
+// about the old one
+func (gv *governor) setTarget(target time.Duration) {
+	gv.Lock()
+	defer gv.Unlock()
+	gv.target = target
+	gv.limits = make(map[string]uint32)
+}
+
+// getTarget returns the target latency, or zero if there isn't one
+func (gv *governor) getTarget() time.Duration {
+	gv.Lock()
+	defer gv.Unlock()
+	return gv.target
+}
+
+// batchSize returns how many slots of op to run in each kernel, given that
+// at most maxSlots fit in the stream
+func (gv *governor) batchSize(op string, maxSlots uint32) uint32 {
+	gv.Lock()
+	defer gv.Unlock()
+	limit, ok := gv.limits[op]
+	if gv.target == 0 || !ok || limit > maxSlots {
+		return maxSlots
+	}
+	return limit
+}
+
+// record adjusts op's batch size after a batch of numSlots slots took
+// elapsed to run
+func (gv *governor) record(op string, numSlots uint32, elapsed time.Duration) {
+	gv.Lock()
+	defer gv.Unlock()
+	if gv.target == 0 || numSlots == 0 {
+		return
+	}
+	limit, ok := gv.limits[op]
+	if elapsed > gv.target {
+		if ok && numSlots <= limit {
+			gv.overran = true
+		}
+		limit = uint32(float64(numSlots) * float64(gv.target) / float64(elapsed))
+		if limit < 1 {
+			limit = 1
+		}
+		gv.limits[op] = limit
+	} else if ok && numSlots == limit {
+		// Only full batches say anything about whether a bigger one would
+		// still finish in time
+		growth := uint32(float64(limit) * governorGrowth)
+		if growth < 1 {
+			growth = 1
+		}
+		gv.limits[op] = limit + growth
+	}
+}
+
+// takeOverrun returns whether a batch has taken too long despite being cut
+// down since the last call
+func (gv *governor) takeOverrun() bool {
+	gv.Lock()
+	defer gv.Unlock()
+	overran := gv.overran
+	gv.overran = false
+	return overran
+}
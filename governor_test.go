@@ -0,0 +1,71 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"testing"
+	"time"
+)
+
+// Without a target, batches should be as big as the stream allows
+func TestGovernor_NoTarget(t *testing.T) {
+	gv := newGovernor()
+	gv.record("ExpChunk", 1000, time.Hour)
+	if gv.batchSize("ExpChunk", 1000) != 1000 {
+		t.Error("Batch size shouldn't be limited without a target")
+	}
+}
+
+// Slow batches should shrink the next ones in proportion, and full batches
+// that finish in time should let them grow again
+func TestGovernor_Record(t *testing.T) {
+	gv := newGovernor()
+	gv.setTarget(200 * time.Millisecond)
+	gv.record("ExpChunk", 1000, 400*time.Millisecond)
+	if gv.batchSize("ExpChunk", 1000) != 500 {
+		t.Errorf("Expected batch size to halve to 500, got %v",
+			gv.batchSize("ExpChunk", 1000))
+	}
+	// A partial batch that finishes in time shouldn't change anything
+	gv.record("ExpChunk", 100, 20*time.Millisecond)
+	if gv.batchSize("ExpChunk", 1000) != 500 {
+		t.Errorf("Partial batch changed batch size to %v",
+			gv.batchSize("ExpChunk", 1000))
+	}
+	gv.record("ExpChunk", 500, 150*time.Millisecond)
+	if gv.batchSize("ExpChunk", 1000) != 562 {
+		t.Errorf("Expected batch size to grow to 562, got %v",
+			gv.batchSize("ExpChunk", 1000))
+	}
+	// The stream's capacity still applies
+	if gv.batchSize("ExpChunk", 300) != 300 {
+		t.Error("Batch size shouldn't be bigger than what fits in the stream")
+	}
+	// Other ops shouldn't be affected
+	if gv.batchSize("Mul2Chunk", 1000) != 1000 {
+		t.Error("Unmeasured op shouldn't be limited")
+	}
+}
+
+// Only a batch that overran at the size the governor asked for should say
+// that too many batches are running at once
+func TestGovernor_Overrun(t *testing.T) {
+	gv := newGovernor()
+	gv.setTarget(200 * time.Millisecond)
+	gv.record("ExpChunk", 1000, 400*time.Millisecond)
+	if gv.takeOverrun() {
+		t.Error("First slow batch hadn't been cut down yet")
+	}
+	gv.record("ExpChunk", 500, 300*time.Millisecond)
+	if !gv.takeOverrun() {
+		t.Error("Slow batch at the governor's size should be an overrun")
+	}
+	if gv.takeOverrun() {
+		t.Error("Overrun should be cleared once it's been taken")
+	}
+}
@@ -11,7 +11,11 @@ package gpumaths
 
 // gpu.go contains helper functions and constants used by
 // the gpu implementation. See the exp, elgamal, reveal, or strip _gpu.go
-// files for implementations of specific operations.
+// files for implementations of specific operations. The wrappers that call
+// the library's functions for each bit length are generated from its header
+// into env_gen_gpu.go.
+
+//go:generate go run ./cmd/bindgen -header /opt/xxnetwork/include/powm_odd_export.h -out env_gen_gpu.go
 
 // When the gpumaths library itself is under development, it should
 // use the version of gpumaths that's built in-repository
@@ -55,6 +59,28 @@ type gpumathsEnv interface {
 	getInputSizeWords(C.enum_kernel) int
 	maxSlots(memSize int, op C.enum_kernel) int
 	streamSizeContaining(numItems int, kernel int) int
+	// Query the library for the sizes in bytes of a kernel's per-slot
+	// inputs and outputs and its constants, without caching them
+	querySizes(C.enum_kernel) (input, output, constants int)
+}
+
+// kernelLayout describes how many big numbers the Go side of each kernel
+// puts in the constants and in each slot's inputs, and how many it reads out
+// of each slot's outputs. The library must agree on this, or the data gets
+// packed at the wrong offsets.
+type kernelLayout struct {
+	name      string
+	constants int
+	inputs    int
+	outputs   int
+}
+
+var kernelLayouts = map[C.enum_kernel]kernelLayout{
+	kernelPowmOdd: {"powm odd", 1, 2, 1},
+	kernelElgamal: {"elgamal", 3, 4, 2},
+	kernelReveal:  {"reveal", 2, 1, 1},
+	kernelMul2:    {"mul2", 1, 2, 1},
+	kernelMul3:    {"mul3", 1, 3, 1},
 }
 
 // TODO These types implement gpumaths? interface
@@ -81,20 +107,94 @@ type sizeData [C.NUM_KERNELS]struct {
 }
 
 // Should the envs belong to the stream pool? probably not
-func chooseEnv(g *cyclic.Group) gpumathsEnv {
+// envFor returns the environment whose kernels op should run for group g,
+// or ErrUnsupportedGroup if none of them can. The kernels use Montgomery
+// arithmetic, which only works for an odd modulus, so an even one would give
+// wrong results rather than an error from the library. Primes shorter than a kernel's
+// bit length are padded with zero words when they're packed, so a group can
+// use any kernel at least as long as its prime. That includes longer ones,
+// if the library was built without op's kernel at the shortest length.
+func envFor(op string, g *cyclic.Group) (gpumathsEnv, error) {
 	primeLen := g.GetP().BitLen()
-	len2048 := gpumathsEnv2048.getBitLen()
-	len3200 := gpumathsEnv3200.getBitLen()
-	len4096 := gpumathsEnv4096.getBitLen()
-	if primeLen <= len2048 {
-		return &gpumathsEnv2048
-	} else if primeLen <= len3200 {
-		return &gpumathsEnv3200
-	} else if primeLen <= len4096 {
-		return &gpumathsEnv4096
-	} else {
-		panic(fmt.Sprintf("Prime %s was too big for any available gpumaths environment", g.GetP().Text(16)))
+	maxLen := gpumathsEnv4096.getBitLen()
+	if primeLen > maxLen {
+		return nil, ErrUnsupportedGroup{
+			Op:        op,
+			PrimeBits: primeLen,
+			Reason: fmt.Sprintf("the longest kernels are %v bits",
+				maxLen),
+		}
+	}
+	if g.GetP().Bits()[0]&1 == 0 {
+		return nil, ErrUnsupportedGroup{
+			Op:        op,
+			PrimeBits: primeLen,
+			Reason:    "the kernels need an odd modulus",
+		}
+	}
+	kernel := opKernels[op]
+	env := chooseEnv(g, kernel)
+	if env == nil {
+		return nil, ErrUnsupportedGroup{
+			Op:        op,
+			PrimeBits: primeLen,
+			Reason: fmt.Sprintf("the library was built without a %v "+
+				"kernel that long", kernelLayouts[kernel].name),
+		}
+	}
+	return env, nil
+}
+
+// chooseEnv returns the shortest environment that's long enough for g's
+// prime and that the library has kernel for, or nil if there isn't one
+func chooseEnv(g *cyclic.Group, kernel C.enum_kernel) gpumathsEnv {
+	primeLen := g.GetP().BitLen()
+	for _, env := range allEnvs {
+		if primeLen <= env.getBitLen() && hasKernel(env, int(kernel)) {
+			return env
+		}
+	}
+	return nil
+}
+
+// Every environment, from shortest to longest
+var allEnvs = []gpumathsEnv{&gpumathsEnv2048, &gpumathsEnv3200,
+	&gpumathsEnv4096}
+
+// opKernels holds the kernel that each op, as named in envFor, runs
+var opKernels = map[string]C.enum_kernel{
+	"ElGamalChunk":   kernelElgamal,
+	"ExpChunk":       kernelPowmOdd,
+	"ExpSharedChunk": kernelPowmOdd,
+	"Mul2Chunk":      kernelMul2,
+	"Mul2Slice":      kernelMul2,
+	"Mul3Chunk":      kernelMul3,
+	"Mul3Slice":      kernelMul3,
+	"RevealChunk":    kernelReveal,
+}
+
+// hasKernel returns whether the library was built with kernel at env's bit
+// length. A library built for a constrained device can leave out kernels or
+// whole bit lengths that the deployment doesn't need, as long as it still
+// exports every function and reports zero sizes for what it left out.
+func hasKernel(env gpumathsEnv, kernel int) bool {
+	return env.getInputSize(C.enum_kernel(kernel)) != 0
+}
+
+// AvailableKernels returns the names of the kernels the library was built
+// with, by bit length
+func AvailableKernels() map[int][]string {
+	available := make(map[int][]string)
+	for _, env := range allEnvs {
+		for kernel := C.enum_kernel(0); kernel < C.NUM_KERNELS; kernel++ {
+			layout, ok := kernelLayouts[kernel]
+			if ok && hasKernel(env, int(kernel)) {
+				available[env.getBitLen()] = append(
+					available[env.getBitLen()], layout.name)
+			}
+		}
 	}
+	return available
 }
 
 func (gpumaths2048) getBitLen() int {
@@ -128,6 +228,54 @@ func (g gpumaths4096) getWordLen() int {
 	return g.getByteLen() / int(unsafe.Sizeof(big.Word(0)))
 }
 
+// checkLibrary makes sure that the library that's been linked has at least
+// one kernel, and that it lays out each kernel's memory the way that the Go
+// side expects
+func checkLibrary() error {
+	found := false
+	for _, env := range allEnvs {
+		for kernel, layout := range kernelLayouts {
+			input, output, constants := env.querySizes(kernel)
+			if input == 0 || output == 0 || constants == 0 {
+				// Left out of the build
+				continue
+			}
+			found = true
+			byteLen := env.getByteLen()
+			if input != layout.inputs*byteLen ||
+				output != layout.outputs*byteLen ||
+				constants < layout.constants*byteLen {
+				return errors.Errorf("gpumaths library's memory layout for the "+
+					"%v kernel for %v bit numbers doesn't match these "+
+					"bindings: expected %v byte inputs, %v byte outputs, and at "+
+					"least %v bytes of constants, but got %v, %v, and %v",
+					layout.name, env.getBitLen(), layout.inputs*byteLen,
+					layout.outputs*byteLen, layout.constants*byteLen, input,
+					output, constants)
+			}
+		}
+	}
+	if !found {
+		return errors.New("gpumaths library was built without any kernels")
+	}
+	return nil
+}
+
+// minStreamSize returns the smallest stream capacity that can still run
+// one slot of every kernel the library has
+func minStreamSize() int {
+	minSize := 0
+	for _, env := range allEnvs {
+		for kernel := range kernelLayouts {
+			size := env.streamSizeContaining(1, int(kernel))
+			if size > minSize {
+				minSize = size
+			}
+		}
+	}
+	return minSize
+}
+
 // Create byte slice viewing memory at a certain memory address with a
 // certain length
 // Here be dragons
@@ -173,7 +321,9 @@ func createStreams(numStreams int, capacity int) ([]Stream, error) {
 		if createStreamResult != nil && createStreamResult.error != nil {
 			createError := goError(createStreamResult.error)
 			// Attempt to clean up any streams that were successfully created
-			destroyErr := destroyStreams(append(streams, Stream{s: createStreamResult.result}))
+			failed := Stream{s: createStreamResult.result}
+			debugStreamCreated(failed)
+			destroyErr := destroyStreams(append(streams, failed))
 			C.free(unsafe.Pointer(createStreamResult))
 			if destroyErr != nil && createError != nil {
 				return nil, errors.Wrap(destroyErr, createError.Error())
@@ -183,7 +333,9 @@ func createStreams(numStreams int, capacity int) ([]Stream, error) {
 		} else if createStreamResult != nil && C.isStreamValid(createStreamResult.result) == 0 {
 			// No error, but something in the stream wasn't set
 			// Attempt to clean up any streams that were successfully created
-			destroyErr := destroyStreams(append(streams, Stream{s: createStreamResult.result}))
+			failed := Stream{s: createStreamResult.result}
+			debugStreamCreated(failed)
+			destroyErr := destroyStreams(append(streams, failed))
 			C.free(unsafe.Pointer(createStreamResult))
 			if destroyErr != nil {
 				return nil, errors.Wrap(destroyErr, "not all fields of stream were initialized")
@@ -205,6 +357,7 @@ func createStreams(numStreams int, capacity int) ([]Stream, error) {
 				cpuData:      toSlice(createStreamResult.cpuBuf, capacity),
 				cpuDataWords: toSliceOfWords(createStreamResult.cpuBuf, int(uintptr(capacity)/unsafe.Sizeof(sizeofOperand[0]))),
 			})
+			debugStreamCreated(streams[len(streams)-1])
 		}
 		// Double free possible here?
 		C.free(unsafe.Pointer(createStreamResult))
@@ -215,6 +368,7 @@ func createStreams(numStreams int, capacity int) ([]Stream, error) {
 
 func destroyStreams(streams []Stream) error {
 	for i := 0; i < len(streams); i++ {
+		debugStreamDestroyed(streams[i])
 		err := C.destroyStream(streams[i].s)
 		if err != nil {
 			return goError(err)
@@ -227,39 +381,6 @@ func destroyStreams(streams []Stream) error {
 // Results are put in a byte array for translation back to cyclic ints elsewhere
 // Currently, we upload and execute all in the same method
 
-// Upload some items to the next stream
-// Returns the stream that the data were uploaded to
-// TODO Store the kernel enum for the upload in the stream
-//  That way you don't have to pass that info again for run
-//  There should be no scenario where the stream gets run for a different kernel than the upload
-// Could return byte slices of output as well? perhaps?
-func (gpumaths2048) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
-	//return errors.New("temporarily disabled due to driver API migration")
-	uploadError := C.enqueue2048(C.uint(numSlots), stream.s, whichToRun)
-	if uploadError != nil {
-		return goError(uploadError)
-	} else {
-		return nil
-	}
-}
-func (gpumaths3200) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
-	//return errors.New("temporarily disabled due to driver API migration")
-	uploadError := C.enqueue3200(C.uint(numSlots), stream.s, whichToRun)
-	if uploadError != nil {
-		return goError(uploadError)
-	} else {
-		return nil
-	}
-}
-func (gpumaths4096) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
-	uploadError := C.enqueue4096(C.uint(numSlots), stream.s, whichToRun)
-	if uploadError != nil {
-		return goError(uploadError)
-	} else {
-		return nil
-	}
-}
-
 // Populate the sizes of constants, inputs, outputs in words based on the byte sizes
 func (s *sizeData) populateWordSizes(kernel C.enum_kernel) {
 	sizeOfOperand := make(large.Bits, 1)
@@ -269,58 +390,6 @@ func (s *sizeData) populateWordSizes(kernel C.enum_kernel) {
 	s[kernel].outputSizeWords = s[kernel].outputSize / sizeOfWord
 }
 
-func (g *gpumaths2048) populateSizeData(kernel C.enum_kernel) {
-	g.sizeData[kernel].inputSize = int(C.getInputSize2048(kernel))
-	// If the result is zero, the kernel is unknown
-	// These panics should never happen unless there's programmer error
-	if g.sizeData[kernel].inputSize == 0 {
-		panic(fmt.Sprintf("Couldn't find input size for kernel %v", kernel))
-	}
-	g.sizeData[kernel].outputSize = int(C.getOutputSize2048(kernel))
-	if g.sizeData[kernel].outputSize == 0 {
-		panic(fmt.Sprintf("Couldn't find output size for kernel %v", kernel))
-	}
-	g.sizeData[kernel].constantsSize = int(C.getConstantsSize2048(kernel))
-	if g.sizeData[kernel].constantsSize == 0 {
-		panic(fmt.Sprintf("Couldn't find constants size for kernel %v", kernel))
-	}
-	g.sizeData.populateWordSizes(kernel)
-}
-func (g *gpumaths3200) populateSizeData(kernel C.enum_kernel) {
-	g.sizeData[kernel].inputSize = int(C.getInputSize3200(kernel))
-	// If the result is zero, the kernel is unknown
-	// These panics should never happen unless there's programmer error
-	if g.sizeData[kernel].inputSize == 0 {
-		panic(fmt.Sprintf("Couldn't find input size for kernel %v", kernel))
-	}
-	g.sizeData[kernel].outputSize = int(C.getOutputSize3200(kernel))
-	if g.sizeData[kernel].outputSize == 0 {
-		panic(fmt.Sprintf("Couldn't find output size for kernel %v", kernel))
-	}
-	g.sizeData[kernel].constantsSize = int(C.getConstantsSize3200(kernel))
-	if g.sizeData[kernel].constantsSize == 0 {
-		panic(fmt.Sprintf("Couldn't find constants size for kernel %v", kernel))
-	}
-	g.sizeData.populateWordSizes(kernel)
-}
-func (g *gpumaths4096) populateSizeData(kernel C.enum_kernel) {
-	g.sizeData[kernel].inputSize = int(C.getInputSize4096(kernel))
-	// If the result is zero, the kernel is unknown
-	// These panics should never happen unless there's programmer error
-	if g.sizeData[kernel].inputSize == 0 {
-		panic(fmt.Sprintf("Couldn't find input size for kernel %v", kernel))
-	}
-	g.sizeData[kernel].outputSize = int(C.getOutputSize4096(kernel))
-	if g.sizeData[kernel].outputSize == 0 {
-		panic(fmt.Sprintf("Couldn't find output size for kernel %v", kernel))
-	}
-	g.sizeData[kernel].constantsSize = int(C.getConstantsSize4096(kernel))
-	if g.sizeData[kernel].constantsSize == 0 {
-		panic(fmt.Sprintf("Couldn't find constants size for kernel %v", kernel))
-	}
-	g.sizeData.populateWordSizes(kernel)
-}
-
 // Four numbers per input
 // Returns size in bytes
 func (g *gpumaths2048) getInputSize(kernel C.enum_kernel) int {
@@ -448,7 +517,8 @@ func (g *gpumaths2048) maxSlots(memSize int, op C.enum_kernel) int {
 	constantsSize := g.getConstantsSize(op)
 	slotSize := g.getInputSize(op) + g.getOutputSize(op)
 	memForSlots := memSize - constantsSize
-	if memForSlots < 0 {
+	// A slot size of zero means the library doesn't know the kernel
+	if memForSlots < 0 || slotSize == 0 {
 		return 0
 	} else {
 		return memForSlots / slotSize
@@ -459,7 +529,8 @@ func (g *gpumaths3200) maxSlots(memSize int, op C.enum_kernel) int {
 	constantsSize := g.getConstantsSize(op)
 	slotSize := g.getInputSize(op) + g.getOutputSize(op)
 	memForSlots := memSize - constantsSize
-	if memForSlots < 0 {
+	// A slot size of zero means the library doesn't know the kernel
+	if memForSlots < 0 || slotSize == 0 {
 		return 0
 	} else {
 		return memForSlots / slotSize
@@ -470,7 +541,8 @@ func (g *gpumaths4096) maxSlots(memSize int, op C.enum_kernel) int {
 	constantsSize := g.getConstantsSize(op)
 	slotSize := g.getInputSize(op) + g.getOutputSize(op)
 	memForSlots := memSize - constantsSize
-	if memForSlots < 0 {
+	// A slot size of zero means the library doesn't know the kernel
+	if memForSlots < 0 || slotSize == 0 {
 		return 0
 	} else {
 		return memForSlots / slotSize
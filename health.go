@@ -0,0 +1,144 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// health.go answers the liveness and readiness checks that a node passes on
+// to systemd or Kubernetes, so that a node whose GPU path is broken gets
+// restarted or moved. This module doesn't depend on gRPC, so the checks are
+// a function that a node's own health service can call, and HTTP handlers
+// for nodes that serve them directly.
+
+// How many of the most recent batches the error rate is worked out over
+const healthWindow = 256
+
+// Error rate above which the GPU path counts as broken, once at least a
+// quarter of the window has run
+const maxErrorRate = 0.5
+
+// Health is the state of the GPU path, from CheckHealth
+type Health struct {
+	// False if the GPU path is broken and the node should be restarted
+	Live bool `json:"live"`
+	// True once Init has created a pool, which passed preflight and
+	// warm-up if they were on, and the pool can run batches
+	Ready bool `json:"ready"`
+	// Fraction of the most recent batches that failed
+	ErrorRate float64 `json:"errorRate"`
+	// Why the path isn't live or ready
+	Problems []string `json:"problems,omitempty"`
+}
+
+// outcomes is a ring of whether each of the most recent batches failed
+var outcomes struct {
+	sync.Mutex
+	failed   [healthWindow]bool
+	next     int
+	count    int
+	failures int
+}
+
+// recordOutcome adds a finished batch to the error rate
+func recordOutcome(err error) {
+	outcomes.Lock()
+	defer outcomes.Unlock()
+	if outcomes.count == healthWindow {
+		if outcomes.failed[outcomes.next] {
+			outcomes.failures--
+		}
+	} else {
+		outcomes.count++
+	}
+	outcomes.failed[outcomes.next] = err != nil
+	if err != nil {
+		outcomes.failures++
+	}
+	outcomes.next = (outcomes.next + 1) % healthWindow
+}
+
+// errorRate returns the fraction of the recorded batches that failed, and
+// how many batches that's out of
+func errorRate() (rate float64, count int) {
+	outcomes.Lock()
+	defer outcomes.Unlock()
+	if outcomes.count == 0 {
+		return 0, 0
+	}
+	return float64(outcomes.failures) / float64(outcomes.count),
+		outcomes.count
+}
+
+// CheckHealth returns the health of the pool that Init created
+func CheckHealth() Health {
+	initialized.Lock()
+	pool := initialized.pool
+	initErr := initialized.err
+	initialized.Unlock()
+
+	h := Health{Live: true, Ready: true}
+	problem := func(live bool, p string) {
+		h.Ready = false
+		h.Live = h.Live && live
+		h.Problems = append(h.Problems, p)
+	}
+	if initErr != nil {
+		problem(false, "Init failed: "+initErr.Error())
+	} else if pool == nil {
+		problem(true, "gpumaths isn't initialized")
+	}
+	if pool != nil {
+		streams := pool.State().Streams
+		// The pool keeps its last stream in rotation however often it
+		// fails, so that one counts as down once it's failing too
+		down := 0
+		for _, s := range streams {
+			if s.Disabled || s.Failing {
+				down++
+			}
+		}
+		if len(streams) > 0 && down == len(streams) {
+			problem(false, "every stream has been disabled or is failing")
+		}
+	}
+	rate, count := errorRate()
+	h.ErrorRate = rate
+	if rate > maxErrorRate && count >= healthWindow/4 {
+		problem(false, "too many recent batches failed")
+	}
+	return h
+}
+
+// ServeLive answers a liveness check with 200 if the GPU path works, and
+// 503 if it's broken. The body is the Health as JSON.
+func ServeLive(w http.ResponseWriter, r *http.Request) {
+	h := CheckHealth()
+	serveHealth(w, h, h.Live)
+}
+
+// ServeReady answers a readiness check with 200 if the GPU path is ready
+// for batches, and 503 if it's not. The body is the Health as JSON.
+func ServeReady(w http.ResponseWriter, r *http.Request) {
+	h := CheckHealth()
+	serveHealth(w, h, h.Ready)
+}
+
+func serveHealth(w http.ResponseWriter, h Health, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	// Nothing can be done about a client that's gone away
+	_ = json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,93 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// resetHealth forgets every recorded batch and any failed Init
+func resetHealth() {
+	outcomes.Lock()
+	outcomes.failed = [healthWindow]bool{}
+	outcomes.next, outcomes.count, outcomes.failures = 0, 0, 0
+	outcomes.Unlock()
+	initialized.Lock()
+	initialized.err = nil
+	initialized.Unlock()
+}
+
+// Before Init, the path should be live but not ready, and a failed Init or
+// too many failed batches should make it not live
+func TestCheckHealth(t *testing.T) {
+	resetHealth()
+	defer resetHealth()
+
+	h := CheckHealth()
+	if !h.Live || h.Ready {
+		t.Errorf("Before Init, expected live and not ready, got %+v", h)
+	}
+
+	initialized.Lock()
+	initialized.err = errors.New("warm-up failed")
+	initialized.Unlock()
+	if h = CheckHealth(); h.Live {
+		t.Errorf("After a failed Init, expected not live, got %+v", h)
+	}
+	resetHealth()
+
+	for i := 0; i < healthWindow/4-1; i++ {
+		recordOutcome(errors.New("launch failed"))
+	}
+	if h = CheckHealth(); !h.Live || h.ErrorRate != 1 {
+		t.Errorf("Too few batches to judge should leave the path live, "+
+			"got %+v", h)
+	}
+	recordOutcome(errors.New("launch failed"))
+	if h = CheckHealth(); h.Live {
+		t.Errorf("Expected not live with every batch failing, got %+v", h)
+	}
+	// Older failures drop out of the window
+	for i := 0; i < healthWindow; i++ {
+		recordOutcome(nil)
+	}
+	if rate, count := errorRate(); rate != 0 || count != healthWindow {
+		t.Errorf("Expected no failures in a full window, got %v of %v",
+			rate, count)
+	}
+}
+
+// The handlers should answer 503 with the health as JSON when the check
+// fails
+func TestServeReady(t *testing.T) {
+	resetHealth()
+	defer resetHealth()
+	w := httptest.NewRecorder()
+	ServeReady(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before Init, got %v", w.Code)
+	}
+	var h Health
+	err := json.Unmarshal(w.Body.Bytes(), &h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Ready || len(h.Problems) == 0 {
+		t.Errorf("Expected a problem saying why it's not ready, got %+v", h)
+	}
+
+	w = httptest.NewRecorder()
+	ServeLive(w, httptest.NewRequest("GET", "/live", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 before Init, got %v", w.Code)
+	}
+}
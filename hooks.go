@@ -0,0 +1,150 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"time"
+)
+
+// hooks.go lets other packages watch batches go through the GPU, to attach
+// their own metrics, tracing or debugging. Hooks are called synchronously
+// from the goroutine running the batch, so they should return quickly.
+
+// Job describes a batch that's run on the GPU
+type Job struct {
+	// Unique to the batch, for looking it up with Status
+	ID uint64
+	// Name of the kernel, e.g. "powm odd" or "mul2"
+	Kernel   string
+	NumSlots int
+	// Bit length of the kernel variant that runs the batch
+	BitLen int
+	// Tags of the pool the batch was run from, comma separated. See
+	// StreamPool.SetTags.
+	Tags string
+	// Bytes copied to the device and back for the batch
+	UploadBytes   int
+	DownloadBytes int
+}
+
+// registered is a hook along with the ID it was registered with, so that it
+// can be found again to remove it
+type registered struct {
+	id   uint64
+	hook interface{}
+}
+
+var hooks struct {
+	sync.RWMutex
+	lastID        uint64
+	onEnqueue     []registered
+	onKernelStart []registered
+	onComplete    []registered
+	onResults     []registered
+}
+
+// register adds hook to the end of list, and returns a function that removes
+// it again
+func register(list *[]registered, hook interface{}) (remove func()) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.lastID++
+	id := hooks.lastID
+	*list = append(*list, registered{id: id, hook: hook})
+	return func() {
+		hooks.Lock()
+		defer hooks.Unlock()
+		for i, r := range *list {
+			if r.id == id {
+				*list = append((*list)[:i:i], (*list)[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// registeredHooks returns a copy of list, so that the hooks in it can be
+// called without holding the lock, and can register or remove hooks
+// themselves
+func registeredHooks(list *[]registered) []registered {
+	hooks.RLock()
+	defer hooks.RUnlock()
+	return append([]registered(nil), *list...)
+}
+
+// OnEnqueue registers a hook that's called when a batch is handed to a
+// stream, before its operands are packed. Calling remove unregisters it.
+func OnEnqueue(hook func(Job)) (remove func()) {
+	return register(&hooks.onEnqueue, hook)
+}
+
+// OnKernelStart registers a hook that's called when a batch's operands have
+// been packed and it's about to be uploaded and run. Calling remove
+// unregisters it.
+func OnKernelStart(hook func(Job)) (remove func()) {
+	return register(&hooks.onKernelStart, hook)
+}
+
+// OnComplete registers a hook that's called when a batch's results have
+// been downloaded and unpacked, or it has failed. elapsed is the time since
+// the kernel started. Calling remove unregisters it.
+func OnComplete(hook func(job Job, elapsed time.Duration,
+	err error)) (remove func()) {
+	return register(&hooks.onComplete, hook)
+}
+
+// OnResults registers a hook that's called with each batch's results, after
+// they're downloaded and before they're copied into the result buffers. If
+// it returns an error, the batch fails with ErrRejected holding that error.
+// Calling remove unregisters it.
+func OnResults(hook func(job Job, results ResultLimbs) error) (remove func()) {
+	return register(&hooks.onResults, hook)
+}
+
+func fireEnqueue(job Job) {
+	setStatus(JobStatus{Job: job, State: JobQueued})
+	for _, r := range registeredHooks(&hooks.onEnqueue) {
+		r.hook.(func(Job))(job)
+	}
+}
+
+func fireKernelStart(job Job) {
+	setStatus(JobStatus{Job: job, State: JobRunning})
+	for _, r := range registeredHooks(&hooks.onKernelStart) {
+		r.hook.(func(Job))(job)
+	}
+}
+
+func fireComplete(job Job, elapsed time.Duration, err error) {
+	if err != nil {
+		setStatus(JobStatus{Job: job, State: JobFailed, Err: err})
+	} else {
+		setStatus(JobStatus{Job: job, State: JobDone})
+		recordTransfer(job, elapsed)
+	}
+	// Rejected results came from a batch that worked, as far as the GPU goes
+	if isRejected(err) {
+		recordOutcome(nil)
+	} else {
+		recordOutcome(err)
+	}
+	for _, r := range registeredHooks(&hooks.onComplete) {
+		r.hook.(func(Job, time.Duration, error))(job, elapsed, err)
+	}
+}
+
+func fireResults(job Job, results ResultLimbs) error {
+	for _, r := range registeredHooks(&hooks.onResults) {
+		err := r.hook.(func(Job, ResultLimbs) error)(job, results)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
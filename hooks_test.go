@@ -0,0 +1,171 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Every registered hook should be called with the batch's details
+func TestHooks(t *testing.T) {
+	job := Job{ID: nextJobID(), Kernel: "mul2", NumSlots: 8, BitLen: 2048}
+	// Batches from other tests can fire the hooks too, so only this job is
+	// looked at
+	var lock sync.Mutex
+	var enqueued, started []Job
+	var completeErr error
+	onEnqueue := func(j Job) {
+		if j.ID == job.ID {
+			lock.Lock()
+			enqueued = append(enqueued, j)
+			lock.Unlock()
+		}
+	}
+	defer OnEnqueue(onEnqueue)()
+	defer OnEnqueue(onEnqueue)()
+	defer OnKernelStart(func(j Job) {
+		if j.ID == job.ID {
+			lock.Lock()
+			started = append(started, j)
+			lock.Unlock()
+		}
+	})()
+	defer OnComplete(func(j Job, elapsed time.Duration, err error) {
+		if j.ID != job.ID {
+			return
+		}
+		if j != job || elapsed != time.Second {
+			t.Errorf("Completion hook got the wrong batch: %+v, %v", j, elapsed)
+		}
+		lock.Lock()
+		completeErr = err
+		lock.Unlock()
+	})()
+
+	fireEnqueue(job)
+	fireKernelStart(job)
+	fail := errors.New("invalid argument")
+	fireComplete(job, time.Second, fail)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(enqueued) != 2 || enqueued[0] != job {
+		t.Errorf("Both enqueue hooks should have been called, got %v", enqueued)
+	}
+	if len(started) != 1 || started[0] != job {
+		t.Errorf("Kernel start hook should have been called, got %v", started)
+	}
+	if completeErr != fail {
+		t.Errorf("Completion hook should have gotten the error, got %v",
+			completeErr)
+	}
+}
+
+// A removed hook shouldn't be called again, and removing it shouldn't
+// affect the others
+func TestHooks_Remove(t *testing.T) {
+	job := Job{ID: nextJobID(), Kernel: "mul2"}
+	var first, second uint32
+	removeFirst := OnKernelStart(func(j Job) {
+		if j.ID == job.ID {
+			atomic.AddUint32(&first, 1)
+		}
+	})
+	defer OnKernelStart(func(j Job) {
+		if j.ID == job.ID {
+			atomic.AddUint32(&second, 1)
+		}
+	})()
+	removeFirst()
+	// Removing twice is harmless
+	removeFirst()
+	fireKernelStart(job)
+	if atomic.LoadUint32(&first) != 0 {
+		t.Error("Removed hook was called")
+	}
+	if atomic.LoadUint32(&second) != 1 {
+		t.Error("Remaining hook wasn't called")
+	}
+}
+
+// A results hook's error should fail the batch, and a removed one shouldn't
+// be called
+func TestOnResults(t *testing.T) {
+	job := Job{ID: nextJobID(), Kernel: "mul2"}
+	reject := errors.New("result out of range")
+	remove := OnResults(func(j Job, results ResultLimbs) error {
+		if j.ID == job.ID {
+			return reject
+		}
+		return nil
+	})
+	if err := fireResults(job, ResultLimbs{}); err != reject {
+		t.Errorf("Expected the hook's error, got %v", err)
+	}
+	remove()
+	if err := fireResults(job, ResultLimbs{}); err != nil {
+		t.Errorf("Removed hook was called: %v", err)
+	}
+}
+
+// A batch whose results were rejected worked as far as the GPU goes, so it
+// shouldn't count towards the error rate
+func TestFireComplete_Rejected(t *testing.T) {
+	resetHealth()
+	defer resetHealth()
+	job := Job{ID: nextJobID(), Kernel: "mul2"}
+	fireComplete(job, 0, ErrRejected{Kernel: "mul2",
+		Err: errors.New("result out of range")})
+	if rate, count := errorRate(); rate != 0 || count != 1 {
+		t.Errorf("Expected 1 batch and no failures, got %v of %v", rate,
+			count)
+	}
+	if status, ok := Status(job.ID); !ok || status.State != JobFailed {
+		t.Errorf("Rejected batch should still be failed, got %+v", status)
+	}
+}
+
+// A hook should be able to remove itself and register another without
+// deadlocking
+func TestHooks_RegisterFromHook(t *testing.T) {
+	job := Job{ID: nextJobID(), Kernel: "mul2"}
+	var removeSelf func()
+	var removeOther func()
+	var called uint32
+	removeSelf = OnKernelStart(func(j Job) {
+		if j.ID != job.ID {
+			return
+		}
+		removeSelf()
+		removeOther = OnComplete(func(j Job, elapsed time.Duration,
+			err error) {
+			if j.ID == job.ID {
+				atomic.AddUint32(&called, 1)
+			}
+		})
+	})
+	done := make(chan struct{})
+	go func() {
+		fireKernelStart(job)
+		fireComplete(job, 0, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Hook that registered another hook deadlocked")
+	}
+	removeOther()
+	if atomic.LoadUint32(&called) != 1 {
+		t.Error("Hook registered from a hook wasn't called")
+	}
+}
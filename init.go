@@ -0,0 +1,203 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// init.go gives the package an explicit start and end. Init sets up the GPU
+// the way a node needs it in one call, and Shutdown releases it again.
+// Callers that want more control can still create and destroy their own
+// pools with NewStreamPool.
+
+// Config says how Init should set up the GPU
+type Config struct {
+	// Number of streams in the pool, and the bytes of memory each one has
+	// on the device and the host
+	NumStreams int `json:"numStreams"`
+	StreamSize int `json:"streamSize"`
+	// Run every kernel once on every stream before returning, which also
+	// checks that the library and device work
+	WarmUp bool `json:"warmUp"`
+	// Passed to StreamPool.SetTargetLatency. Zero lets batches fill their
+	// streams.
+	TargetLatency time.Duration `json:"targetLatency"`
+	// Passed to StreamPool.SetExponentBlinding
+	BlindExponents bool `json:"blindExponents"`
+	// Passed to StreamPool.SetSlotDedup
+	DedupSlots bool `json:"dedupSlots"`
+	// Passed to StreamPool.SetIdleScrub. Zero leaves idle streams alone.
+	IdleScrub time.Duration `json:"idleScrub"`
+	// Passed to StreamPool.SetAutoResize. Zero interval keeps NumStreams
+	// streams.
+	MinStreams     int           `json:"minStreams,omitempty"`
+	MaxStreams     int           `json:"maxStreams,omitempty"`
+	ResizeInterval time.Duration `json:"resizeInterval,omitempty"`
+	// Log the kernel, size and time of every batch at DEBUG level
+	Profile bool `json:"profile"`
+	// Create the pool even if Init's checks of the machine and the CUDA
+	// driver find problems
+	SkipPreflight bool `json:"skipPreflight"`
+	// Passed to SetStrict. Only tests should need this.
+	Strict bool `json:"strict,omitempty"`
+	// If set, the pool's tuning is loaded from this file by Init and saved
+	// to it by Shutdown. Warm-up is skipped if the file could be loaded.
+	TuningFile string `json:"tuningFile,omitempty"`
+}
+
+var initialized struct {
+	sync.Mutex
+	pool       *StreamPool
+	tuningFile string
+	// Why the last Init failed, if it did
+	err error
+}
+
+// Set while profiling is on
+var profiling uint32
+var registerProfiling sync.Once
+
+// Init creates the package's stream pool as cfg says, and returns it. Init
+// can't be called again until Shutdown has been called. If anything fails,
+// nothing is left set up.
+func Init(cfg Config) (*StreamPool, error) {
+	initialized.Lock()
+	defer initialized.Unlock()
+	if initialized.pool != nil {
+		return nil, errors.New("gpumaths is already initialized")
+	}
+	pool, err := initLocked(cfg)
+	initialized.err = err
+	return pool, err
+}
+
+// initLocked does the work of Init while initialized is locked
+func initLocked(cfg Config) (*StreamPool, error) {
+	SetStrict(cfg.Strict)
+	if cfg.NumStreams < 1 || cfg.StreamSize < 1 {
+		return nil, errors.Errorf("can't create %v streams of %v bytes",
+			cfg.NumStreams, cfg.StreamSize)
+	}
+	if !cfg.SkipPreflight {
+		err := preflight(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pool, err := NewStreamPool(cfg.NumStreams, cfg.StreamSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create stream pool")
+	}
+	// The library may have been built with only what this deployment needs
+	jww.INFO.Printf("GPU kernels available by bit length: %v",
+		AvailableKernels())
+	pool.SetTargetLatency(cfg.TargetLatency)
+	pool.SetExponentBlinding(cfg.BlindExponents)
+	pool.SetSlotDedup(cfg.DedupSlots)
+	pool.SetIdleScrub(cfg.IdleScrub)
+	restored := false
+	if cfg.TuningFile != "" {
+		tuning, err := LoadTuning(cfg.TuningFile)
+		if err == nil {
+			pool.SetTuning(tuning)
+			restored = true
+		} else if !os.IsNotExist(err) {
+			jww.WARN.Printf("Couldn't restore the pool's tuning, so it "+
+				"will be learned again: %v", err)
+		}
+	}
+	if cfg.WarmUp && !restored {
+		err = pool.WarmUp()
+		if err != nil {
+			destroyErr := pool.Destroy()
+			if destroyErr != nil {
+				jww.ERROR.Printf("Couldn't destroy stream pool after "+
+					"failed warm-up: %v", destroyErr)
+			}
+			return nil, err
+		}
+	}
+	err = pool.SetAutoResize(cfg.MinStreams, cfg.MaxStreams,
+		cfg.ResizeInterval)
+	if err != nil {
+		destroyErr := pool.Destroy()
+		if destroyErr != nil {
+			jww.ERROR.Printf("Couldn't destroy stream pool after "+
+				"failing to set up resizing: %v", destroyErr)
+		}
+		return nil, err
+	}
+	if cfg.Profile {
+		registerProfiling.Do(func() {
+			OnComplete(func(job Job, elapsed time.Duration, err error) {
+				if atomic.LoadUint32(&profiling) == 1 {
+					jww.DEBUG.Printf("%v bit %v kernel ran %v slots in %v "+
+						"(tags: %v, error: %v)", job.BitLen, job.Kernel,
+						job.NumSlots, elapsed, job.Tags, err)
+				}
+			})
+		})
+		atomic.StoreUint32(&profiling, 1)
+	}
+	initialized.pool = pool
+	initialized.tuningFile = cfg.TuningFile
+	return pool, nil
+}
+
+// preflight returns an error listing every problem that would stop a pool
+// made as cfg says from working, so that a machine that can't use the GPU
+// fails at Init with an explanation rather than with a launch failure at the
+// first batch. Problems that only cost performance are logged as warnings.
+func preflight(cfg Config) error {
+	problems, warnings := preflightDiagnoses(cfg.NumStreams * cfg.StreamSize)
+	for _, w := range warnings {
+		jww.WARN.Printf("gpumaths preflight: %v", w)
+	}
+	driver, runtime, err := cudaVersions()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if p := cudaVersionProblem(driver, runtime); p != "" {
+		problems = append(problems, p)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Errorf("gpumaths preflight found %v problems. Set "+
+		"Config.SkipPreflight to initialize anyway:\n%v", len(problems),
+		strings.Join(problems, "\n"))
+}
+
+// Shutdown destroys the pool that Init created, saves its tuning if
+// Config.TuningFile was set, and turns profiling off.
+// Work still running on the pool must have finished first.
+func Shutdown() error {
+	initialized.Lock()
+	defer initialized.Unlock()
+	if initialized.pool == nil {
+		return errors.New("gpumaths isn't initialized")
+	}
+	atomic.StoreUint32(&profiling, 0)
+	var saveErr error
+	if initialized.tuningFile != "" {
+		saveErr = SaveTuning(initialized.tuningFile,
+			initialized.pool.Tuning())
+	}
+	err := initialized.pool.Destroy()
+	initialized.pool = nil
+	if err == nil {
+		err = saveErr
+	}
+	return err
+}
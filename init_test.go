@@ -0,0 +1,24 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "testing"
+
+// Shutdown without Init should fail, and a failed Init should leave nothing
+// to shut down
+func TestShutdown_NotInitialized(t *testing.T) {
+	if Shutdown() == nil {
+		t.Error("Shutdown should fail before Init")
+	}
+	if _, err := Init(Config{NumStreams: 1}); err == nil {
+		t.Fatal("Init should fail with empty streams")
+	}
+	if Shutdown() == nil {
+		t.Error("Shutdown should fail after a failed Init")
+	}
+}
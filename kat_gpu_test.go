@@ -0,0 +1,83 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/gpumathsgo/testvectors"
+	"testing"
+)
+
+// Every RFC 3526 known answer should come out the same from the kernels,
+// with and without blinding
+func TestKnownAnswers(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := streamPool.Destroy(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for _, blind := range []bool{false, true} {
+		streamPool.SetExponentBlinding(blind)
+		for _, ka := range testvectors.KnownAnswers {
+			v := ka.Load()
+			z := v.Group.NewIntBuffer(1, v.Group.NewInt(1))
+			_, err = ExpChunk(streamPool, v.Group, v.X, v.Y, z)
+			if err != nil {
+				t.Fatalf("%v bit %v: %v", ka.Bits, ka.Name, err)
+			}
+			if z.Get(0).Cmp(v.Expected.Get(0)) != 0 {
+				t.Errorf("%v bit %v (blinded: %v): got %v", ka.Bits,
+					ka.Name, blind, z.Get(0).Text(16))
+			}
+		}
+	}
+}
+
+// Every case in the regression corpus should come out right from the
+// kernels, with and without blinding
+func TestCorpus_GPU(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := streamPool.Destroy(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for _, blind := range []bool{false, true} {
+		streamPool.SetExponentBlinding(blind)
+		for _, c := range loadCorpus(t) {
+			v, err := c.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			z := v.Group.NewIntBuffer(1, v.Group.NewInt(1))
+			switch c.Op {
+			case "Exp":
+				_, err = ExpChunk(streamPool, v.Group, v.X, v.Y, z)
+			case "Mul2":
+				err = Mul2Chunk(streamPool, v.Group, v.X, v.Y, z)
+			case "Mul3":
+				err = Mul3Chunk(streamPool, v.Group, v.X, v.Y, v.Z, z)
+			}
+			if err != nil {
+				t.Fatalf("%v: %v", c.Name, err)
+			}
+			if z.Get(0).Cmp(v.Expected.Get(0)) != 0 {
+				t.Errorf("%v (blinded: %v): got %v", c.Name, blind,
+					z.Get(0).Text(16))
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*#cgo LDFLAGS: -Llib -lpowmosm75 -Wl,-rpath -Wl,./lib:/opt/xxnetwork/lib
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"time"
+)
+
+// kernel_gpu.go moves an op's operands into a stream, runs the op's kernel,
+// and moves the results back out. Every kernel lays out its memory the same
+// way, so adding a new op only takes a kernelLayouts entry describing it and
+// a function that passes its operands to runKernel in the order the kernel
+// expects them.
+
+// runKernel runs kernel on the stream for every slot of the inputs.
+// constants are written once at the start of the stream. For each slot, one
+// bignum from each of inputs is written in order, and after the kernel runs
+// one bignum for each of outputs is read back in order. The number of each
+// must match the kernel's layout in kernelLayouts, and the inputs and
+// outputs must all have the same length.
+func runKernel(g *cyclic.Group, kernel C.enum_kernel, constants []large.Bits,
+	inputs, outputs []intGetter, env gpumathsEnv, stream Stream) chan error {
+	// Return the result later, when the GPU job finishes
+	resultChan := make(chan error, 1)
+	layout, ok := kernelLayouts[kernel]
+	if !ok || len(constants) != layout.constants ||
+		len(inputs) != layout.inputs || len(outputs) != layout.outputs {
+		resultChan <- errors.Errorf("kernel %v takes %v constants, %v "+
+			"inputs and %v outputs, but was run with %v, %v and %v",
+			layout.name, layout.constants, layout.inputs, layout.outputs,
+			len(constants), len(inputs), len(outputs))
+		return resultChan
+	}
+	if env.getInputSize(kernel) == 0 || env.getOutputSize(kernel) == 0 ||
+		env.getConstantsSize(kernel) == 0 {
+		resultChan <- errors.Errorf("the %v bit library has no sizes for "+
+			"kernel %v", env.getBitLen(), layout.name)
+		return resultChan
+	}
+	job := Job{
+		ID:       nextJobID(),
+		Kernel:   layout.name,
+		NumSlots: inputs[0].Len(),
+		BitLen:   env.getBitLen(),
+		Tags:     stream.tags,
+	}
+	job.UploadBytes = env.getConstantsSize(kernel) +
+		env.getInputSize(kernel)*job.NumSlots
+	job.DownloadBytes = env.getOutputSize(kernel) * job.NumSlots
+	fireEnqueue(job)
+	go func() {
+		var err error
+		var started time.Time
+		// Every batch has to end up done or failed, however it ends, or its
+		// status would never be evicted
+		defer func() {
+			// A panic here would take down the whole process, as nothing
+			// above this goroutine can recover it
+			if r := recover(); r != nil {
+				err = broken(errors.Errorf("%v batch panicked: %v",
+					job.Kernel, r))
+			}
+			var elapsed time.Duration
+			if !started.IsZero() {
+				elapsed = time.Since(started)
+			}
+			fireComplete(job, elapsed, err)
+			if err != nil && job.Tags != "" {
+				err = errors.Wrapf(err, "%v batch tagged %v", job.Kernel,
+					job.Tags)
+			}
+			resultChan <- err
+		}()
+		err = stageKernel(g, kernel, constants, inputs, outputs, env,
+			stream, job, &started)
+		// Once the batch is done, the operands have been copied to the
+		// device, so they don't need to stay in host memory
+		stream.scrub(env, kernel, job.NumSlots)
+	}()
+	return resultChan
+}
+
+// stageKernel does the work of runKernel, and waits for it to finish. started
+// is set when the kernel is about to start.
+func stageKernel(g *cyclic.Group, kernel C.enum_kernel, constants []large.Bits,
+	inputs, outputs []intGetter, env gpumathsEnv, stream Stream,
+	job Job, started *time.Time) (err error) {
+	numSlots := job.NumSlots
+	bnLengthWords := env.getWordLen()
+
+	// A batch that doesn't fit would have the library read and write past
+	// the end of the stream
+	size := env.streamSizeContaining(numSlots, int(kernel))
+	if size > len(stream.cpuData) {
+		return errors.Errorf("%v slots of %v need %v bytes, but the stream "+
+			"only has %v", numSlots, job.Kernel, size, len(stream.cpuData))
+	}
+
+	// Arrange memory into stream buffers
+	constantsWords := stream.getCpuConstantsWords(env, kernel)
+	offset := 0
+	for _, c := range constants {
+		putBits(constantsWords[offset:offset+bnLengthWords], c, bnLengthWords)
+		offset += bnLengthWords
+	}
+	inputsWords := stream.getCpuInputsWords(env, kernel, numSlots)
+	// Large batches are packed on every core, so the GPU isn't left waiting
+	// on one
+	splitSlots(numSlots, func(start, end int) {
+		offset := start * bnLengthWords * len(inputs)
+		for i := uint32(start); i < uint32(end); i++ {
+			for _, in := range inputs {
+				putBits(inputsWords[offset:offset+bnLengthWords],
+					in.Get(i).Bits(), bnLengthWords)
+				offset += bnLengthWords
+			}
+		}
+	})
+
+	fireKernelStart(job)
+	*started = time.Now()
+	faults := takeFaults()
+	if faults.upload {
+		return errInjectedUpload
+	}
+
+	// Results will be stored in this buffer
+	// This intermediary copy is necessary because the byte order needs to be reversed
+	outputsWords := stream.getCpuOutputsWords(env, kernel, numSlots)
+	// The library doesn't say how many slots it wrote, so anything it
+	// skipped has to be recognizable afterwards
+	markUnwritten(outputsWords)
+
+	// Upload, run, wait for download
+	err = env.enqueue(stream, kernel, numSlots)
+	if err != nil {
+		return err
+	}
+
+	// Wait on things to finish with Cuda
+	err = get(stream)
+	if faults.delay > 0 {
+		time.Sleep(faults.delay)
+	}
+	if err == nil && faults.kernel {
+		err = errInjectedKernel
+	}
+	if err != nil {
+		return err
+	}
+	slotWords := bnLengthWords * len(outputs)
+	err = checkWritten(job.Kernel, outputsWords, numSlots, slotWords)
+	if err != nil {
+		return err
+	}
+	err = fireResults(job, ResultLimbs{words: outputsWords,
+		numSlots: numSlots, outputs: len(outputs), limbs: bnLengthWords})
+	if err != nil {
+		return ErrRejected{Kernel: job.Kernel, Err: err}
+	}
+
+	// Everything is OK, so let's go ahead and import the results. Large
+	// batches are imported on every core, as doing it on one can take as
+	// long as the kernel.
+	splitSlots(numSlots, func(start, end int) {
+		offset := start * slotWords
+		for i := uint32(start); i < uint32(end); i++ {
+			for _, out := range outputs {
+				g.OverwriteBits(out.Get(i),
+					outputsWords[offset:offset+bnLengthWords])
+				offset += bnLengthWords
+			}
+		}
+	})
+	if faults.corrupt && numSlots > 0 {
+		g.OverwriteBits(outputs[0].Get(0), large.Bits{})
+	}
+	return nil
+}
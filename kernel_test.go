@@ -0,0 +1,104 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+// Running a kernel with operands that don't match its layout should fail
+// before anything is written to the stream
+func TestRunKernel_WrongLayout(t *testing.T) {
+	g := makeTestGroup2048()
+	x := g.NewIntBuffer(2, g.NewInt(2))
+	// mul2 takes two inputs, not one
+	err := <-runKernel(g, kernelMul2, []large.Bits{g.GetP().Bits()},
+		[]intGetter{x}, []intGetter{x}, &gpumathsEnv2048, Stream{})
+	if err == nil {
+		t.Error("Kernel run with the wrong number of inputs should have failed")
+	}
+}
+
+// A batch that's refused before its kernel starts should still end up
+// failed, so its status isn't left queued forever
+func TestRunKernel_TooSmallFails(t *testing.T) {
+	g := makeTestGroup2048()
+	x := g.NewIntBuffer(2, g.NewInt(2))
+	stream := Stream{cpuData: make([]byte, 64),
+		cpuDataWords: make(large.Bits, 8)}
+	var id uint64
+	defer OnEnqueue(func(j Job) {
+		if j.Kernel == kernelLayouts[kernelMul2].name && j.NumSlots == 2 {
+			atomic.StoreUint64(&id, j.ID)
+		}
+	})()
+	err := <-runKernel(g, kernelMul2, []large.Bits{g.GetP().Bits()},
+		[]intGetter{x, x}, []intGetter{x}, &gpumathsEnv2048, stream)
+	if err == nil {
+		t.Fatal("Batch that doesn't fit in the stream should have failed")
+	}
+	status, ok := Status(atomic.LoadUint64(&id))
+	if !ok || status.State != JobFailed {
+		t.Errorf("Batch should have been marked failed, got %+v", status)
+	}
+}
+
+// CGBN reads operands as little-endian 32-bit limbs, so the bytes that
+// putBits leaves in a stream's buffer must be in that order
+func TestPutBits_ByteOrder(t *testing.T) {
+	words := make(large.Bits, 2)
+	putBits(words, large.NewInt(0x0102030405).Bits(), len(words))
+	bytes := toSlice(unsafe.Pointer(&words[0]),
+		len(words)*int(unsafe.Sizeof(words[0])))
+	expected := []byte{0x05, 0x04, 0x03, 0x02, 0x01}
+	for i := range bytes {
+		want := byte(0)
+		if i < len(expected) {
+			want = expected[i]
+		}
+		if bytes[i] != want {
+			t.Fatalf("Byte %v of the buffer was %#x, expected %#x", i,
+				bytes[i], want)
+		}
+	}
+}
+
+// Every op should say which kernel it runs, so envFor can find a library
+// that has it
+func TestOpKernels(t *testing.T) {
+	for _, op := range Cryptops() {
+		if _, ok := opKernels[op.GetName()]; !ok {
+			t.Errorf("%v has no kernel in opKernels", op.GetName())
+		}
+	}
+}
+
+// A library with every kernel should report all of them, and small groups
+// should run on the shortest kernels
+func TestAvailableKernels(t *testing.T) {
+	available := AvailableKernels()
+	for _, env := range allEnvs {
+		if len(available[env.getBitLen()]) != len(kernelLayouts) {
+			t.Errorf("Expected %v kernels at %v bits, got %v",
+				len(kernelLayouts), env.getBitLen(),
+				available[env.getBitLen()])
+		}
+	}
+	env, err := envFor("Mul3Chunk", makeTestGroup2048())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.getBitLen() != 2048 {
+		t.Errorf("Expected the 2048 bit kernels, got %v", env.getBitLen())
+	}
+}
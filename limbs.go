@@ -0,0 +1,81 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// limbs.go lets verification code look at a batch's results as the kernel
+// wrote them, before they're copied into the result buffers. Checks that
+// only need the top limbs of each result, like whether it's below p, can
+// stop there instead of building a big integer for every slot.
+
+// ResultLimbs is a batch's results as little-endian words, from the stream's
+// memory. It's only valid during the OnResults hook it's passed to, and
+// mustn't be changed.
+type ResultLimbs struct {
+	words    large.Bits
+	numSlots int
+	outputs  int
+	// Words in each result
+	limbs int
+}
+
+// NumSlots returns the number of slots in the batch
+func (r ResultLimbs) NumSlots() int {
+	return r.numSlots
+}
+
+// NumOutputs returns the number of results in each slot
+func (r ResultLimbs) NumOutputs() int {
+	return r.outputs
+}
+
+// Limbs returns the words of one result, least significant first. The
+// result is padded with zero words up to the kernel's bit length.
+func (r ResultLimbs) Limbs(slot, output int) large.Bits {
+	start := (slot*r.outputs + output) * r.limbs
+	return r.words[start : start+r.limbs : start+r.limbs]
+}
+
+// Each calls f on every result in slot order, and stops early if f returns
+// false
+func (r ResultLimbs) Each(f func(slot, output int, limbs large.Bits) bool) {
+	for slot := 0; slot < r.numSlots; slot++ {
+		for output := 0; output < r.outputs; output++ {
+			if !f(slot, output, r.Limbs(slot, output)) {
+				return
+			}
+		}
+	}
+}
+
+// LimbsBelow returns whether the number in limbs is less than p. Both are
+// little-endian words, and either can have extra zero words on top. It
+// compares from the most significant word down, and stops at the first one
+// that differs.
+func LimbsBelow(limbs, p large.Bits) bool {
+	n := len(limbs)
+	if len(p) > n {
+		n = len(p)
+	}
+	for i := n - 1; i >= 0; i-- {
+		var l, q uint
+		if i < len(limbs) {
+			l = uint(limbs[i])
+		}
+		if i < len(p) {
+			q = uint(p[i])
+		}
+		if l != q {
+			return l < q
+		}
+	}
+	return false
+}
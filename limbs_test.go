@@ -0,0 +1,73 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"math/big"
+	"testing"
+)
+
+// Results should be found at the right offsets, in slot order, and Each
+// should stop when asked
+func TestResultLimbs(t *testing.T) {
+	// 3 slots of 2 results of 2 words each, numbered in order
+	words := make(large.Bits, 12)
+	for i := range words {
+		words[i] = big.Word(i)
+	}
+	r := ResultLimbs{words: words, numSlots: 3, outputs: 2, limbs: 2}
+	if r.NumSlots() != 3 || r.NumOutputs() != 2 {
+		t.Errorf("Expected 3 slots of 2 outputs, got %v of %v", r.NumSlots(),
+			r.NumOutputs())
+	}
+	limbs := r.Limbs(2, 1)
+	if len(limbs) != 2 || limbs[0] != words[10] || limbs[1] != words[11] {
+		t.Errorf("Slot 2 output 1 should be words 10 and 11, got %v", limbs)
+	}
+
+	visited := 0
+	r.Each(func(slot, output int, limbs large.Bits) bool {
+		if limbs[0] != words[(slot*2+output)*2] {
+			t.Errorf("Wrong limbs for slot %v output %v", slot, output)
+		}
+		visited++
+		return slot < 1
+	})
+	if visited != 3 {
+		t.Errorf("Each should have stopped after 3 results, visited %v",
+			visited)
+	}
+}
+
+// LimbsBelow should agree with comparing the numbers, with or without
+// padding
+func TestLimbsBelow(t *testing.T) {
+	p := large.NewIntFromString("fffffffffffffffffffffffffffffffb", 16)
+	for _, c := range []struct {
+		x     string
+		below bool
+	}{
+		{"0", true},
+		{"fffffffffffffffffffffffffffffffa", true},
+		{"fffffffffffffffffffffffffffffffb", false},
+		{"fffffffffffffffffffffffffffffffc", false},
+		{"1fffffffffffffffffffffffffffffffb", false},
+		{"effffffffffffffffffffffffffffffff", false},
+		{"efffffffffffffffffffffffffffffff", true},
+	} {
+		x := large.NewIntFromString(c.x, 16)
+		padded := make(large.Bits, 8)
+		copy(padded, x.Bits())
+		for _, limbs := range []large.Bits{x.Bits(), padded} {
+			if LimbsBelow(limbs, p.Bits()) != c.below {
+				t.Errorf("LimbsBelow(%v, p) should be %v", c.x, c.below)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// mock.go is a stand-in for the GPU in integration tests on machines that
+// don't have one. It replaces the chunk functions with ones that compute
+// the right answer on the CPU, but take about as long as the GPU would and
+// sometimes fail, so that the server's scheduling, retries and timeouts can
+// be tested without a GPU.
+
+// Mock says how the mock chunk functions behave
+type Mock struct {
+	// Every call takes Latency, plus PerSlot for each slot, plus a random
+	// amount up to Jitter
+	Latency time.Duration
+	PerSlot time.Duration
+	Jitter  time.Duration
+	// Fraction of calls, from 0 to 1, that fail with a transient error
+	// without computing anything
+	ErrorRate float64
+	// Seeds the jitter and errors, so a test run can be repeated
+	Seed int64
+
+	rngLock sync.Mutex
+	rng     *rand.Rand
+}
+
+// ErrMockFailure is returned by calls the mock chose to fail. IsTransient
+// is true for it, like the launch timeouts it stands for.
+var ErrMockFailure = errors.New("mock backend: launch timed out")
+
+// Install replaces the chunk functions with the mock's, and returns a
+// function that puts the previous ones back. Calls made while the mock is
+// installed ignore the pool they're passed, which can be nil.
+func (m *Mock) Install() (restore func()) {
+	m.rngLock.Lock()
+	m.rng = rand.New(rand.NewSource(m.Seed))
+	m.rngLock.Unlock()
+
+	exp, expShared, elGamal, reveal := ExpChunk, ExpSharedChunk,
+		ElGamalChunk, RevealChunk
+	mul2, mul2Slice, mul3, mul3Slice := Mul2Chunk, Mul2Slice, Mul3Chunk,
+		Mul3Slice
+	ExpChunk = m.expChunk
+	ExpSharedChunk = m.expSharedChunk
+	ElGamalChunk = m.elGamalChunk
+	RevealChunk = m.revealChunk
+	Mul2Chunk = m.mul2Chunk
+	Mul2Slice = m.mul2Slice
+	Mul3Chunk = m.mul3Chunk
+	Mul3Slice = m.mul3Slice
+	return func() {
+		ExpChunk, ExpSharedChunk, ElGamalChunk, RevealChunk = exp,
+			expShared, elGamal, reveal
+		Mul2Chunk, Mul2Slice, Mul3Chunk, Mul3Slice = mul2, mul2Slice, mul3,
+			mul3Slice
+	}
+}
+
+// batch waits as long as a call with numSlots slots should take, and
+// returns ErrMockFailure if the call should fail
+func (m *Mock) batch(numSlots int) error {
+	m.rngLock.Lock()
+	var jitter time.Duration
+	if m.Jitter > 0 {
+		jitter = time.Duration(m.rng.Int63n(int64(m.Jitter)))
+	}
+	fail := m.rng.Float64() < m.ErrorRate
+	m.rngLock.Unlock()
+
+	time.Sleep(m.Latency + m.PerSlot*time.Duration(numSlots) + jitter)
+	if fail {
+		return ErrMockFailure
+	}
+	return nil
+}
+
+func (m *Mock) expChunk(p *StreamPool, g *cyclic.Group,
+	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
+	err := checkLengths("ExpChunk", z, x, y)
+	if err == nil {
+		err = m.batch(z.Len())
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < uint32(z.Len()); i++ {
+		cryptops.Exp(g, x.Get(i), y.Get(i), z.Get(i))
+	}
+	return z, nil
+}
+
+func (m *Mock) expSharedChunk(p *StreamPool, g *cyclic.Group,
+	x *cyclic.IntBuffer, y ExponentSharer, z *cyclic.IntBuffer) error {
+	err := checkLengths("ExpSharedChunk", z, x)
+	if err == nil {
+		err = m.batch(z.Len())
+	}
+	if err != nil {
+		return err
+	}
+	numSlots := uint32(z.Len())
+	shares1 := g.NewIntBuffer(numSlots, g.NewInt(1))
+	shares2 := g.NewIntBuffer(numSlots, g.NewInt(1))
+	err = y.Share(0, numSlots, shares1, shares2)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get exponent shares")
+	}
+	partial := g.NewInt(1)
+	for i := uint32(0); i < numSlots; i++ {
+		g.Exp(x.Get(i), shares1.Get(i), z.Get(i))
+		g.Exp(x.Get(i), shares2.Get(i), partial)
+		g.Mul(z.Get(i), partial, z.Get(i))
+	}
+	return nil
+}
+
+func (m *Mock) elGamalChunk(p *StreamPool, g *cyclic.Group,
+	key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int,
+	ecrKey, cypher *cyclic.IntBuffer) error {
+	err := checkLengths("ElGamalChunk", key, privateKey, ecrKey, cypher)
+	if err == nil {
+		err = m.batch(key.Len())
+	}
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(key.Len()); i++ {
+		cryptops.ElGamal(g, key.Get(i), privateKey.Get(i), publicCypherKey,
+			ecrKey.Get(i), cypher.Get(i))
+	}
+	return nil
+}
+
+func (m *Mock) revealChunk(p *StreamPool, g *cyclic.Group,
+	publicCypherKey *cyclic.Int, cypher, result *cyclic.IntBuffer) error {
+	err := checkLengths("RevealChunk", cypher, result)
+	if err == nil {
+		err = m.batch(cypher.Len())
+	}
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(cypher.Len()); i++ {
+		cryptops.RootCoprime(g, cypher.Get(i), publicCypherKey, result.Get(i))
+	}
+	return nil
+}
+
+func (m *Mock) mul2Chunk(p *StreamPool, g *cyclic.Group,
+	x, y, results *cyclic.IntBuffer) error {
+	err := checkLengths("Mul2Chunk", x, y, results)
+	if err == nil {
+		err = m.batch(x.Len())
+	}
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		g.Mul(x.Get(i), y.Get(i), results.Get(i))
+	}
+	return nil
+}
+
+func (m *Mock) mul2Slice(p *StreamPool, g *cyclic.Group,
+	x *cyclic.IntBuffer, y, result []*cyclic.Int) error {
+	err := checkLengths("Mul2Slice", x, intSlice(y), intSlice(result))
+	if err == nil {
+		err = m.batch(x.Len())
+	}
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		g.Mul(x.Get(i), y[i], result[i])
+	}
+	return nil
+}
+
+func (m *Mock) mul3Chunk(p *StreamPool, g *cyclic.Group,
+	x, y, z, results *cyclic.IntBuffer) error {
+	err := checkLengths("Mul3Chunk", x, y, z, results)
+	if err == nil {
+		err = m.batch(x.Len())
+	}
+	if err != nil {
+		return err
+	}
+	// results can be the same buffer as z
+	xy := g.NewInt(1)
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		g.Mul(x.Get(i), y.Get(i), xy)
+		g.Mul(xy, z.Get(i), results.Get(i))
+	}
+	return nil
+}
+
+func (m *Mock) mul3Slice(p *StreamPool, g *cyclic.Group,
+	x, y, out []*cyclic.Int) error {
+	err := checkLengths("Mul3Slice", intSlice(x), intSlice(y), intSlice(out))
+	if err == nil {
+		err = m.batch(len(x))
+	}
+	if err != nil {
+		return err
+	}
+	for i := range x {
+		cryptops.Mul3(g, x[i], y[i], out[i])
+	}
+	return nil
+}
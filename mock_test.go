@@ -0,0 +1,87 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+	"time"
+)
+
+// Mocked ops should take at least as long as they're set to, and give the
+// right answers
+func TestMock_Latency(t *testing.T) {
+	m := &Mock{Latency: 20 * time.Millisecond, PerSlot: time.Millisecond}
+	defer m.Install()()
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 2, 3, 4)
+	y := newDedupBuffer(g, 5, 6, 7)
+	z := g.NewIntBuffer(3, g.NewInt(1))
+
+	start := time.Now()
+	_, err := ExpChunk(nil, g, x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 23*time.Millisecond {
+		t.Errorf("Batch took %v, expected at least 23ms", elapsed)
+	}
+	for i := uint32(0); i < 3; i++ {
+		expected := g.Exp(x.Get(i), y.Get(i), g.NewInt(1))
+		if z.Get(i).Cmp(expected) != 0 {
+			t.Errorf("Slot %v was %v, expected %v", i, z.Get(i).Text(10),
+				expected.Text(10))
+		}
+	}
+
+	err = Mul3Chunk(nil, g, x, y, x, g.NewIntBuffer(2, g.NewInt(1)))
+	if _, ok := err.(ErrSizeMismatch); !ok {
+		t.Errorf("Expected ErrSizeMismatch for a short result, got %v", err)
+	}
+}
+
+// The mock's mul3 should give x*y*z when the results go into z, like the
+// real one does
+func TestMock_Mul3InPlace(t *testing.T) {
+	defer (&Mock{}).Install()()
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 2, 3)
+	y := newDedupBuffer(g, 5, 7)
+	z := newDedupBuffer(g, 11, 13)
+	err := Mul3Chunk(nil, g, x, y, z, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []int64{2 * 5 * 11 % 107, 3 * 7 * 13 % 107} {
+		if z.Get(uint32(i)).Cmp(g.NewInt(expected)) != 0 {
+			t.Errorf("Slot %v was %v, expected %v", i,
+				z.Get(uint32(i)).Text(10), expected)
+		}
+	}
+}
+
+// Failed calls should be transient, and restoring a mock should put back
+// whatever was installed before it
+func TestMock_Errors(t *testing.T) {
+	failing := &Mock{ErrorRate: 1}
+	defer failing.Install()()
+	restore := (&Mock{}).Install()
+	g := cyclic.NewGroup(large.NewInt(107), large.NewInt(2))
+	x := newDedupBuffer(g, 2, 3)
+
+	err := Mul2Chunk(nil, g, x, x, x)
+	if err != nil {
+		t.Errorf("Mock with no errors failed: %v", err)
+	}
+	restore()
+	err = Mul2Chunk(nil, g, x, x, x)
+	if err != ErrMockFailure || !IsTransient(err) {
+		t.Errorf("Expected a transient mock failure, got %v", err)
+	}
+}
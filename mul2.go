@@ -22,6 +22,24 @@ type Mul2ChunkPrototype func(p *StreamPool, g *cyclic.Group,
 type Mul2SlicePrototype func(p *StreamPool, g *cyclic.Group,
 	x *cyclic.IntBuffer, y, result []*cyclic.Int) error
 
+// This interface provides compatibility with the underlying mul2 method
+// Int buffers and slices can both be used to implement this interface
+type intGetter interface {
+	Get(index uint32) *cyclic.Int
+	Len() int
+}
+
+type intSlice []*cyclic.Int
+
+// Implement intGetter with cyclic int slice
+func (s intSlice) Get(index uint32) *cyclic.Int {
+	return s[index]
+}
+
+func (s intSlice) Len() int {
+	return len(s)
+}
+
 // GetInputSize is how big chunk sizes should be to run the mul2 operation
 func (Mul2ChunkPrototype) GetInputSize() uint32 {
 	return 256
@@ -32,6 +50,16 @@ func (Mul2ChunkPrototype) GetName() string {
 	return "Mul2Chunk"
 }
 
+// RequiresGPU implements Cryptop
+func (Mul2ChunkPrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (Mul2ChunkPrototype) PrefersGPU() bool {
+	return true
+}
+
 // GetInputSize is how big chunk sizes should be to run the mul2 operation
 func (Mul2SlicePrototype) GetInputSize() uint32 {
 	return 256
@@ -41,3 +69,13 @@ func (Mul2SlicePrototype) GetInputSize() uint32 {
 func (Mul2SlicePrototype) GetName() string {
 	return "Mul2Slice"
 }
+
+// RequiresGPU implements Cryptop
+func (Mul2SlicePrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (Mul2SlicePrototype) PrefersGPU() bool {
+	return true
+}
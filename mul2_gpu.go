@@ -17,7 +17,7 @@ import "C"
 import (
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/crypto/cyclic"
-	"math/rand"
+	"gitlab.com/xx_network/crypto/large"
 	"time"
 )
 
@@ -27,83 +27,109 @@ import (
 
 const kernelMul2 = C.KERNEL_MUL2
 
-// This interface provides compatibility with the underlying mul2 method
-// Int buffers and slices can both be used to implement this interface
-type intGetter interface {
-	Get(index uint32) *cyclic.Int
-	Len() int
-}
-
-type intSlice []*cyclic.Int
-
-// Implement intGetter with cyclic int slice
-func (s intSlice) Get(index uint32) *cyclic.Int {
-	return s[index]
-}
-
-func (s intSlice) Len() int {
-	return len(s)
-}
-
 // Mul2Chunk performs the mul2 operation on the cypher and precomputation
 // payloads
 // Precondition: All int buffers must have the same length
 var Mul2Chunk Mul2ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	x *cyclic.IntBuffer, y *cyclic.IntBuffer, results *cyclic.IntBuffer) error {
+	err := checkLengths("Mul2Chunk", x, y, results)
+	if err != nil {
+		return err
+	}
+	env, err := envFor("Mul2Chunk", g)
+	if err != nil {
+		return err
+	}
 	// Populate mul2 inputs
 	numSlots := uint32(x.Len())
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	env := chooseEnv(g)
-	maxSlotsMul2 := uint32(env.maxSlots(len(stream.cpuData), kernelMul2))
-	if numSlots > maxSlotsMul2 {
-		//panic((numSlots+maxSlotsMul2-1)/maxSlotsMul2)
-		//panic(maxSlotsMul2)
-		jww.WARN.Printf("Running %v kernels for Mul2Chunk. Performance may be degraded", (numSlots+maxSlotsMul2-1)/maxSlotsMul2)
-	}
-	for i := uint32(0); i < numSlots; i += maxSlotsMul2 {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsMul2 <= numSlots {
-			sliceEnd += maxSlotsMul2
-		} else {
-			sliceEnd = numSlots
+	busy := p.busy()
+	maxSlotsMul2 := uint32(env.maxSlots(p.memSize, kernelMul2))
+	batchSize := p.governor.batchSize("Mul2Chunk", maxSlotsMul2)
+	err = p.runHybrid("Mul2Chunk", numSlots, maxSlotsMul2, busy, func(start, end uint32) error {
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		if end-start > maxSlotsMul2 {
+			jww.WARN.Printf("Running %v kernels for Mul2Chunk. Performance may be degraded", (end-start+maxSlotsMul2-1)/maxSlotsMul2)
+		}
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				return mul2(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), results.GetSubBuffer(i, sliceEnd), env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("Mul2Chunk", sliceEnd-i, time.Since(batchStart))
 		}
-		err := <-mul2(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), results.GetSubBuffer(i, sliceEnd), env, stream)
-		if err != nil {
-			return err
+		return nil
+	}, func(start, end uint32) {
+		for i := start; i < end; i++ {
+			g.Mul(x.Get(i), y.Get(i), results.Get(i))
 		}
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return checkResults(g, results)
 }
 
 var Mul2Slice Mul2SlicePrototype = func(p *StreamPool, g *cyclic.Group, x *cyclic.IntBuffer, y, result []*cyclic.Int) error {
+	err := checkLengths("Mul2Slice", x, intSlice(y), intSlice(result))
+	if err != nil {
+		return err
+	}
+	env, err := envFor("Mul2Slice", g)
+	if err != nil {
+		return err
+	}
 	// Populate mul2 inputs
 	numSlots := uint32(x.Len())
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	env := chooseEnv(g)
-	maxSlotsMul2 := uint32(env.maxSlots(len(stream.cpuData), kernelMul2))
-	for i := uint32(0); i < numSlots; i += maxSlotsMul2 {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsMul2 <= numSlots {
-			sliceEnd += maxSlotsMul2
-		} else {
-			sliceEnd = numSlots
+	busy := p.busy()
+	maxSlotsMul2 := uint32(env.maxSlots(p.memSize, kernelMul2))
+	batchSize := p.governor.batchSize("Mul2Slice", maxSlotsMul2)
+	err = p.runHybrid("Mul2Slice", numSlots, maxSlotsMul2, busy, func(start, end uint32) error {
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				return mul2(g, x.GetSubBuffer(i, sliceEnd), intSlice(y[i:sliceEnd]), intSlice(result[i:sliceEnd]), env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("Mul2Slice", sliceEnd-i, time.Since(batchStart))
 		}
-		err := <-mul2(g, x.GetSubBuffer(i, sliceEnd), intSlice(y[i:sliceEnd]), intSlice(result[i:sliceEnd]), env, stream)
-		if err != nil {
-			return err
+		return nil
+	}, func(start, end uint32) {
+		for i := start; i < end; i++ {
+			g.Mul(x.Get(i), y[i], result[i])
 		}
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return checkResults(g, intSlice(result))
 }
 
 // mul2 runs the mul2 operation on precomputation and cypher payloads inside
@@ -115,82 +141,6 @@ var Mul2Slice Mul2SlicePrototype = func(p *StreamPool, g *cyclic.Group, x *cycli
 // bnLength is a length in bits
 // puts output in results int buffer
 func mul2(g *cyclic.Group, x intGetter, y intGetter, results intGetter, env gpumathsEnv, stream Stream) chan error {
-	debugPrint := false
-	callId := rand.Intn(9999)
-	start := time.Now()
-	//if debugPrint {
-	//	println("starting mul2 call", callId, "with", len(input.Slots), "slots at", start.String())
-	//}
-	start = time.Now()
-	//Return the result later, when the GPU job finishes
-	resultChan := make(chan error, 1)
-	go func() {
-		// Arrange memory into stream buffers
-		numSlots := uint32(x.Len())
-
-		// TODO clean this up by implementing the
-		// arrangement/dearrangement with reader/writer interfaces
-		// or smth
-		constants := stream.getCpuConstantsWords(env, kernelMul2)
-		bnLengthWords := env.getWordLen()
-		putBits(constants, g.GetP().Bits(), bnLengthWords)
-		offset := 0
-		offset += bnLengthWords
-
-		inputs := stream.getCpuInputsWords(env, kernelMul2, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Put the first operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			// Put the second operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], y.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
-		if debugPrint {
-			println("Call", callId, "post input arrangement", time.Since(start))
-			start = time.Now()
-		}
-
-		// Upload, run, wait for download
-		err := env.enqueue(stream, kernelMul2, int(numSlots))
-		if debugPrint {
-			println("Call", callId, "post put", time.Since(start))
-			start = time.Now()
-		}
-		if err != nil {
-			resultChan <- err
-			return
-		}
-
-		outputs := stream.getCpuOutputsWords(env, kernelMul2, int(numSlots))
-
-		// Wait on things to finish with Cuda
-		err = get(stream)
-
-		if debugPrint {
-			println("Call", callId, "post get", time.Since(start))
-			start = time.Now()
-		}
-		if err != nil {
-			resultChan <- err
-			return
-		}
-
-		// Everything is OK, so let's go ahead and import the results
-
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Output the computed result into each slot
-			g.OverwriteBits(results.Get(i), outputs[offset:offset+bnLengthWords])
-			offset += bnLengthWords
-		}
-
-		if debugPrint {
-			println("Call", callId, "post output arrangement", time.Since(start))
-		}
-
-		resultChan <- nil
-	}()
-	return resultChan
+	return runKernel(g, kernelMul2, []large.Bits{g.GetP().Bits()},
+		[]intGetter{x, y}, []intGetter{results}, env, stream)
 }
@@ -19,3 +19,38 @@ func (Mul3ChunkPrototype) GetInputSize() uint32 {
 func (Mul3ChunkPrototype) GetName() string {
 	return "Mul3Chunk"
 }
+
+// RequiresGPU implements Cryptop
+func (Mul3ChunkPrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (Mul3ChunkPrototype) PrefersGPU() bool {
+	return true
+}
+
+// Mul3SlicePrototype is the type for Mul3Slice, which works on slices of ints
+// that don't have to be in a buffer
+type Mul3SlicePrototype func(p *StreamPool, g *cyclic.Group,
+	x, y, out []*cyclic.Int) error
+
+// GetInputSize is how big chunk sizes should be to run the mul3 operation
+func (Mul3SlicePrototype) GetInputSize() uint32 {
+	return 256
+}
+
+// GetName return the name of the Mul3Slice operation
+func (Mul3SlicePrototype) GetName() string {
+	return "Mul3Slice"
+}
+
+// RequiresGPU implements Cryptop
+func (Mul3SlicePrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (Mul3SlicePrototype) PrefersGPU() bool {
+	return true
+}
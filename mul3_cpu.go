@@ -19,3 +19,9 @@ var Mul3Chunk Mul3ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, result *cyclic.IntBuffer) error {
 	return errors.New(NoGpuErrStr)
 }
+
+// Mul3Slice is stubbed unless GPU is present.
+var Mul3Slice Mul3SlicePrototype = func(p *StreamPool, g *cyclic.Group,
+	x, y, out []*cyclic.Int) error {
+	return errors.New(NoGpuErrStr)
+}
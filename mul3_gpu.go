@@ -15,8 +15,9 @@ package gpumaths
 import "C"
 import (
 	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
-	"math/rand"
+	"gitlab.com/xx_network/crypto/large"
 	"time"
 )
 
@@ -27,114 +28,116 @@ const kernelMul3 = C.KERNEL_MUL3
 // Precondition: All int buffers must have the same length
 var Mul3Chunk Mul3ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, results *cyclic.IntBuffer) error {
+	err := checkLengths("Mul3Chunk", x, y, z, results)
+	if err != nil {
+		return err
+	}
+	env, err := envFor("Mul3Chunk", g)
+	if err != nil {
+		return err
+	}
 	// Populate mul3 inputs
 	numSlots := uint32(x.Len())
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	env := chooseEnv(g)
-	maxSlotsMul3 := uint32(env.maxSlots(len(stream.cpuData), kernelMul3))
-	if numSlots > maxSlotsMul3 {
-		jww.WARN.Printf("Running multiple kernels for Mul3Chunk. Performance may be degraded")
-	}
-	for i := uint32(0); i < numSlots; i += maxSlotsMul3 {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsMul3 <= numSlots {
-			sliceEnd += maxSlotsMul3
-		} else {
-			sliceEnd = numSlots
+	busy := p.busy()
+	maxSlotsMul3 := uint32(env.maxSlots(p.memSize, kernelMul3))
+	batchSize := p.governor.batchSize("Mul3Chunk", maxSlotsMul3)
+	err = p.runHybrid("Mul3Chunk", numSlots, maxSlotsMul3, busy, func(start, end uint32) error {
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		if end-start > maxSlotsMul3 {
+			jww.WARN.Printf("Running multiple kernels for Mul3Chunk. Performance may be degraded")
+		}
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				return mul3(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), z.GetSubBuffer(i, sliceEnd), results.GetSubBuffer(i, sliceEnd), env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("Mul3Chunk", sliceEnd-i, time.Since(batchStart))
 		}
-		err := <-mul3(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), z.GetSubBuffer(i, sliceEnd), results.GetSubBuffer(i, sliceEnd), env, stream)
-		if err != nil {
-			return err
+		return nil
+	}, func(start, end uint32) {
+		// results can be the same buffer as z, so x*y can't go in it first
+		xy := g.NewInt(1)
+		for i := start; i < end; i++ {
+			g.Mul(x.Get(i), y.Get(i), xy)
+			g.Mul(xy, z.Get(i), results.Get(i))
 		}
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return checkResults(g, results)
 }
 
-func mul3(g *cyclic.Group, x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
-	debugPrint := false
-	callId := rand.Intn(9999)
-	start := time.Now()
-	//if debugPrint {
-	//	println("starting mul2 call", callId, "with", len(input.Slots), "slots at", start.String())
-	//}
-	start = time.Now()
-	//Return the result later, when the GPU job finishes
-	resultChan := make(chan error, 1)
-	go func() {
-		// Arrange memory into stream buffers
-		numSlots := uint32(x.Len())
-
-		// TODO clean this up by implementing the
-		// arrangement/dearrangement with reader/writer interfaces
-		// or smth
-		constants := stream.getCpuConstantsWords(env, kernelMul3)
-		bnLengthWords := env.getWordLen()
-		putBits(constants, g.GetP().Bits(), bnLengthWords)
-		offset := 0
-		offset += bnLengthWords
-
-		inputs := stream.getCpuInputsWords(env, kernelMul3, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Put the first operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			// Put the second operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], y.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			// Put the third operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], z.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
-		if debugPrint {
-			println("Call", callId, "post input arrangement", time.Since(start))
-			start = time.Now()
-		}
-
-		// Upload, run, wait for download
-		err := env.enqueue(stream, kernelMul3, int(numSlots))
-		if debugPrint {
-			println("Call", callId, "post put", time.Since(start))
-			start = time.Now()
-		}
-		if err != nil {
-			resultChan <- err
-			return
-		}
-
-		outputs := stream.getCpuOutputsWords(env, kernelMul3, int(numSlots))
-
-		// Wait on things to finish with Cuda
-		err = get(stream)
-
-		if debugPrint {
-			println("Call", callId, "post get", time.Since(start))
-			start = time.Now()
-		}
-		if err != nil {
-			resultChan <- err
-			return
-		}
-
-		// Everything is OK, so let's go ahead and import the results
+// Mul3Slice sets out[i] = x[i]*y[i]*out[i], like cryptops.Mul3 does for one
+// slot, for ints that are in slices rather than buffers
+// Precondition: All slices must have the same length
+var Mul3Slice Mul3SlicePrototype = func(p *StreamPool, g *cyclic.Group,
+	x, y, out []*cyclic.Int) error {
+	err := checkLengths("Mul3Slice", intSlice(x), intSlice(y), intSlice(out))
+	if err != nil {
+		return err
+	}
+	env, err := envFor("Mul3Slice", g)
+	if err != nil {
+		return err
+	}
+	numSlots := uint32(len(out))
 
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Output the computed result into each slot
-			g.OverwriteBits(result.Get(i), outputs[offset:offset+bnLengthWords])
-			offset += bnLengthWords
+	// Run kernel on the inputs
+	busy := p.busy()
+	maxSlotsMul3 := uint32(env.maxSlots(p.memSize, kernelMul3))
+	batchSize := p.governor.batchSize("Mul3Slice", maxSlotsMul3)
+	err = p.runHybrid("Mul3Slice", numSlots, maxSlotsMul3, busy, func(start, end uint32) error {
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				// out is uploaded before the results are written back to it
+				return runKernel(g, kernelMul3, []large.Bits{g.GetP().Bits()},
+					[]intGetter{intSlice(x[i:sliceEnd]), intSlice(y[i:sliceEnd]), intSlice(out[i:sliceEnd])},
+					[]intGetter{intSlice(out[i:sliceEnd])}, env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("Mul3Slice", sliceEnd-i, time.Since(batchStart))
 		}
-
-		if debugPrint {
-			println("Call", callId, "post output arrangement", time.Since(start))
+		return nil
+	}, func(start, end uint32) {
+		for i := start; i < end; i++ {
+			cryptops.Mul3(g, x[i], y[i], out[i])
 		}
+	})
+	if err != nil {
+		return err
+	}
+	return checkResults(g, intSlice(out))
+}
 
-		resultChan <- nil
-	}()
-	return resultChan
+func mul3(g *cyclic.Group, x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+	return runKernel(g, kernelMul3, []large.Bits{g.GetP().Bits()},
+		[]intGetter{x, y, z}, []intGetter{result}, env, stream)
 }
@@ -110,6 +110,70 @@ func TestMul3Chunk(t *testing.T) {
 	}
 }
 
+func TestMul3Slice(t *testing.T) {
+	batchSize := uint32(1000)
+	grp := initTestGroup()
+
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	zCPU := initRandomIntBuffer(grp, batchSize, 44, 0)
+	xs := make([]*cyclic.Int, batchSize)
+	ys := make([]*cyclic.Int, batchSize)
+	outs := make([]*cyclic.Int, batchSize)
+	for i := uint32(0); i < batchSize; i++ {
+		xs[i] = x.Get(i)
+		ys[i] = y.Get(i)
+		outs[i] = zCPU.Get(i).DeepCopy()
+	}
+
+	// Run CPU. Results are in zCPU
+	mul3CPU(batchSize, grp, x, y, zCPU)
+
+	streamPool, err := NewStreamPool(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Mul3Slice(streamPool, grp, xs, ys, outs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		if zCPU.Get(i).Cmp(outs[i]) != 0 {
+			t.Errorf("mul3 results mismatch on index %d", i)
+		}
+	}
+	err = Mul3Slice(streamPool, grp, xs, ys[1:], outs)
+	if err == nil {
+		t.Error("Mul3Slice should fail with slices of different lengths")
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The CPU's share must give x*y*z even when the results go into z
+func TestMul3Chunk_CPUInPlace(t *testing.T) {
+	grp := makeTestGroup2048()
+	const batchSize = 8
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	z := initRandomIntBuffer(grp, batchSize, 44, 0)
+	// cryptops.Mul3 overwrites y as well as z
+	expected := z.DeepCopy()
+	mul3CPU(batchSize, grp, x, y.DeepCopy(), expected)
+
+	err := Mul3Chunk(newCPUOnlyPool("Mul3Chunk"), grp, x, y, z, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		if expected.Get(i).Cmp(z.Get(i)) != 0 {
+			t.Errorf("mul3 results mismatch on index %d", i)
+		}
+	}
+}
+
 func mul3GPU(t *testing.T, pool *StreamPool, grp *cyclic.Group, xGPU *cyclic.IntBuffer, yGPU *cyclic.IntBuffer, zGPU *cyclic.IntBuffer, resultsGPU *cyclic.IntBuffer) {
 	err := Mul3Chunk(pool, grp, xGPU, yGPU, zGPU, resultsGPU)
 	if err != nil {
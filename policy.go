@@ -0,0 +1,66 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "time"
+
+// policy.go decides which stream a caller gets when more than one is free.
+
+// SelectionPolicy says which free stream TakeStream hands out
+type SelectionPolicy int
+
+const (
+	// RoundRobin hands out the stream that has been free the longest, so
+	// work is spread evenly over time. This is the default.
+	RoundRobin SelectionPolicy = iota
+	// LeastUsed hands out the stream that has run the fewest batches, which
+	// evens out streams that have been favoured by bursts of work
+	LeastUsed
+	// MostRecent hands out the stream that was returned last, whose memory
+	// is most likely to still be in the host's caches and TLB
+	MostRecent
+)
+
+// StreamStatus is what a pool knows about one of its streams
+type StreamStatus struct {
+	// Set while a caller has the stream
+	Busy bool `json:"busy"`
+	// Set if the stream failed too many batches in a row and is no longer
+	// used
+	Disabled bool `json:"disabled"`
+	// Set if the stream's last few batches all failed. The last stream in
+	// rotation is never disabled, so this is how it shows it's broken.
+	Failing bool `json:"failing"`
+	// Number of batches the stream has run since it was created
+	Batches uint64 `json:"batches"`
+}
+
+// freeStream is a free stream's history, as far as choosing one goes
+type freeStream struct {
+	batches  uint64
+	returned time.Time
+}
+
+// pick returns the index of the stream in free that policy chooses. free is
+// in the order the streams were returned, oldest first.
+func (policy SelectionPolicy) pick(free []freeStream) int {
+	best := 0
+	for i := 1; i < len(free); i++ {
+		switch policy {
+		case LeastUsed:
+			if free[i].batches < free[best].batches {
+				best = i
+			}
+		case MostRecent:
+			if !free[i].returned.Before(free[best].returned) {
+				best = i
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,36 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"testing"
+	"time"
+)
+
+// Each policy should pick the stream it describes
+func TestSelectionPolicy_Pick(t *testing.T) {
+	now := time.Now()
+	free := []freeStream{
+		{batches: 5, returned: now.Add(-3 * time.Second)},
+		{batches: 2, returned: now.Add(-2 * time.Second)},
+		{batches: 9, returned: now.Add(-time.Second)},
+	}
+	expected := map[SelectionPolicy]int{
+		RoundRobin: 0,
+		LeastUsed:  1,
+		MostRecent: 2,
+	}
+	for policy, want := range expected {
+		if got := policy.pick(free); got != want {
+			t.Errorf("Policy %v picked %v, not %v", policy, got, want)
+		}
+	}
+	if LeastUsed.pick(free[:1]) != 0 {
+		t.Error("The only free stream should be picked")
+	}
+}
@@ -0,0 +1,102 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ranges.go runs a large chunk as several smaller ranges of slots at once,
+// and hands back each range as soon as its results are ready, so the caller
+// can start on the early slots while later ones are still being computed.
+
+// RangeResult says that the slots in [Start, End) are done. If Err isn't nil,
+// the results in those slots can't be used.
+type RangeResult struct {
+	Start uint32
+	End   uint32
+	Err   error
+}
+
+// RunRanges splits [0, numSlots) into ranges of at most rangeSize slots, and
+// calls run for up to concurrency ranges at a time. run would normally call
+// one of the chunk functions on sub-buffers of the range, and concurrency
+// would be the number of streams in the pool, so each range gets a stream of
+// its own. Each range is sent on the returned channel when it's done, in the
+// order they finish, and the channel is closed after the last one.
+func RunRanges(numSlots, rangeSize uint32, concurrency int,
+	run func(start, end uint32) error) <-chan RangeResult {
+	if rangeSize == 0 {
+		rangeSize = numSlots
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	numRanges := 0
+	if rangeSize > 0 {
+		numRanges = int((numSlots + rangeSize - 1) / rangeSize)
+	}
+	results := make(chan RangeResult, numRanges)
+	limit := make(chan struct{}, concurrency)
+	// Ranges are started in the background, so the caller can take the
+	// first ones while later ones are still waiting for their turn
+	go func() {
+		var wg sync.WaitGroup
+		for start := uint32(0); start < numSlots; start += rangeSize {
+			end := start + rangeSize
+			if end > numSlots {
+				end = numSlots
+			}
+			wg.Add(1)
+			limit <- struct{}{}
+			go func(start, end uint32) {
+				defer wg.Done()
+				err := run(start, end)
+				<-limit
+				results <- RangeResult{Start: start, End: end, Err: err}
+			}(start, end)
+		}
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// Each goroutine that splitSlots starts gets at least this many slots, as
+// converting fewer than that takes less time than starting the goroutine
+const minSlotsPerWorker = 64
+
+// splitSlots calls f on contiguous ranges of [0, numSlots), one range per
+// CPU, and waits for them all to finish. Each slot is in exactly one range,
+// so f can write its slots' results without locking, and they stay in slot
+// order. Small batches are run in one call on the calling goroutine.
+func splitSlots(numSlots int, f func(start, end int)) {
+	workers := runtime.NumCPU()
+	if workers > numSlots/minSlotsPerWorker {
+		workers = numSlots / minSlotsPerWorker
+	}
+	if workers <= 1 {
+		f(0, numSlots)
+		return
+	}
+	rangeLen := (numSlots + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < numSlots; start += rangeLen {
+		end := start + rangeLen
+		if end > numSlots {
+			end = numSlots
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			f(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
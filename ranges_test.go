@@ -0,0 +1,118 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Every slot should be covered by exactly one range, no more than
+// concurrency ranges should run at once, and errors should come back with
+// their range
+func TestRunRanges(t *testing.T) {
+	const numSlots = 100
+	var covered [numSlots]int32
+	var running, maxRunning int32
+	fail := errors.New("invalid argument")
+	results := RunRanges(numSlots, 16, 3, func(start, end uint32) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		for i := start; i < end; i++ {
+			atomic.AddInt32(&covered[i], 1)
+		}
+		atomic.AddInt32(&running, -1)
+		if start == 32 {
+			return fail
+		}
+		return nil
+	})
+
+	numRanges := 0
+	for r := range results {
+		numRanges++
+		if (r.Start == 32) != (r.Err == fail) {
+			t.Errorf("Range [%v, %v) had error %v", r.Start, r.End, r.Err)
+		}
+		if r.Start == 96 && r.End != numSlots {
+			t.Errorf("Last range should end at %v, not %v", numSlots, r.End)
+		}
+	}
+	if numRanges != 7 {
+		t.Errorf("Expected 7 ranges, got %v", numRanges)
+	}
+	for i := range covered {
+		if covered[i] != 1 {
+			t.Errorf("Slot %v was run %v times", i, covered[i])
+		}
+	}
+	if maxRunning > 3 {
+		t.Errorf("%v ranges ran at once, but the limit was 3", maxRunning)
+	}
+}
+
+// The first range should be handed back while later ones are still running,
+// even when there are more ranges left than can run at once
+func TestRunRanges_Early(t *testing.T) {
+	release := make(chan struct{})
+	results := RunRanges(64, 16, 2, func(start, end uint32) error {
+		if start > 0 {
+			<-release
+		}
+		return nil
+	})
+	defer close(release)
+	select {
+	case r := <-results:
+		if r.Start != 0 {
+			t.Errorf("Expected the first range, got [%v, %v)", r.Start, r.End)
+		}
+	case <-time.After(time.Second):
+		t.Error("First range wasn't handed back while later ones ran")
+	}
+}
+
+// An empty chunk should close the channel without running anything
+func TestRunRanges_Empty(t *testing.T) {
+	for range RunRanges(0, 16, 2, func(start, end uint32) error {
+		t.Error("Nothing should run for an empty chunk")
+		return nil
+	}) {
+		t.Error("Empty chunk shouldn't have any ranges")
+	}
+}
+
+// Every slot should be passed to f once, however the batch is split
+func TestSplitSlots(t *testing.T) {
+	for _, numSlots := range []int{0, 1, minSlotsPerWorker - 1,
+		minSlotsPerWorker * 2, 10007} {
+		var lock sync.Mutex
+		seen := make([]int, numSlots)
+		splitSlots(numSlots, func(start, end int) {
+			lock.Lock()
+			defer lock.Unlock()
+			for i := start; i < end; i++ {
+				seen[i]++
+			}
+		})
+		for i, n := range seen {
+			if n != 1 {
+				t.Errorf("%v slots: slot %v was passed %v times", numSlots,
+					i, n)
+			}
+		}
+	}
+}
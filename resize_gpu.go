@@ -0,0 +1,190 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"time"
+)
+
+// resize_gpu.go grows and shrinks a pool to fit how many of its streams are
+// used at once, so that a node that's mostly idle doesn't hold pinned host
+// memory and device memory for streams it has no use for.
+
+// SetAutoResize makes the pool look, every interval, at the most streams that
+// were in use at once since it last looked. If a caller had to wait for a
+// stream, a stream is added, up to max. If two or more streams were never
+// needed, a free one is destroyed, down to min. With a target latency set,
+// batches that take too long even after SetTargetLatency has made them
+// smaller mean too many are running at once, so a free stream is destroyed
+// instead, down to min. Zero interval turns resizing off, leaving the pool
+// the size it is.
+func (sm *StreamPool) SetAutoResize(min, max int, interval time.Duration) error {
+	if sm.borrowed {
+		return errors.New("can't resize streams borrowed from another pool")
+	}
+	if interval > 0 && (min < 1 || max < min) {
+		return errors.Errorf("can't resize a pool to between %v and %v "+
+			"streams", min, max)
+	}
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	if sm.stopResize != nil {
+		close(sm.stopResize)
+		sm.stopResize = nil
+	}
+	if interval <= 0 {
+		return nil
+	}
+	sm.peakInUse = len(sm.streams) - len(sm.streamChan)
+	sm.waited = false
+	sm.stopResize = make(chan struct{})
+	go sm.autoResize(min, max, interval, sm.stopResize)
+	return nil
+}
+
+// autoResize resizes the pool every interval until stop is closed
+func (sm *StreamPool) autoResize(min, max int, interval time.Duration,
+	stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		sm.waitLock.Lock()
+		numStreams := len(sm.streams)
+		step := resizeStep(numStreams, sm.peakInUse, sm.waited,
+			sm.governor.takeOverrun(), min, max)
+		sm.peakInUse = numStreams - len(sm.streamChan)
+		sm.waited = false
+		sm.waitLock.Unlock()
+
+		if step == 0 {
+			continue
+		}
+		sm.resizeLock.Lock()
+		// The pool may have been destroyed while this waited for the lock
+		select {
+		case <-stop:
+			sm.resizeLock.Unlock()
+			return
+		default:
+		}
+		var err error
+		if step > 0 {
+			err = sm.addStream()
+		} else {
+			err = sm.removeStream()
+		}
+		sm.resizeLock.Unlock()
+		if err != nil {
+			jww.WARN.Printf("Couldn't resize pool of %v streams: %v",
+				numStreams, err)
+		}
+	}
+}
+
+// resizeStep returns 1 if a pool of numStreams streams should grow, -1 if it
+// should shrink, and 0 if it should stay the same size. overran means
+// batches took too long even at the governor's batch size.
+func resizeStep(numStreams, peakInUse int, waited, overran bool,
+	min, max int) int {
+	if numStreams < min {
+		return 1
+	}
+	if numStreams > max || overran && numStreams > min {
+		return -1
+	}
+	if overran {
+		return 0
+	}
+	if waited && numStreams < max {
+		return 1
+	}
+	if !waited && peakInUse < numStreams-1 && numStreams > min {
+		return -1
+	}
+	return 0
+}
+
+// noteInUse records how many streams are in use after one has been taken.
+// waitLock must be held.
+func (sm *StreamPool) noteInUse() {
+	inUse := len(sm.streams) - len(sm.streamChan)
+	if inUse > sm.peakInUse {
+		sm.peakInUse = inUse
+	}
+}
+
+// addStream creates a stream and puts it into rotation. resizeLock must be
+// held.
+func (sm *StreamPool) addStream() error {
+	streams, err := createStreams(1, sm.memSize)
+	if err != nil {
+		return err
+	}
+	s := streams[0]
+	sm.waitLock.Lock()
+	// streamChan has to be able to hold every stream at once
+	if len(sm.streams)+1 > cap(sm.streamChan) {
+		bigger := make(chan Stream, len(sm.streams)+1)
+		for len(sm.streamChan) > 0 {
+			bigger <- <-sm.streamChan
+		}
+		sm.streamChan = bigger
+	}
+	sm.healthLock.Lock()
+	s.generation = sm.generation
+	sm.streams = append(sm.streams, s)
+	sm.healthLock.Unlock()
+	sm.waitLock.Unlock()
+	sm.returnStream(s, false)
+	return nil
+}
+
+// removeStream destroys a free stream, if there is one. resizeLock must be
+// held.
+func (sm *StreamPool) removeStream() error {
+	s, ok := sm.dropFree()
+	if !ok {
+		return nil
+	}
+	return destroyStreams([]Stream{s})
+}
+
+// dropFree takes a free stream out of the pool for good, and returns it so
+// it can be destroyed. ok is false if no stream was free.
+func (sm *StreamPool) dropFree() (s Stream, ok bool) {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	if len(sm.streamChan) == 0 {
+		return Stream{}, false
+	}
+	s = <-sm.streamChan
+	delete(sm.idleSince, s.s)
+	delete(sm.returned, s.s)
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	streams := make([]Stream, 0, len(sm.streams)-1)
+	for _, other := range sm.streams {
+		if other.s != s.s {
+			streams = append(streams, other)
+		}
+	}
+	sm.streams = streams
+	delete(sm.failures, s.s)
+	delete(sm.disabled, s.s)
+	delete(sm.batches, s.s)
+	return s, true
+}
@@ -0,0 +1,105 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import "testing"
+
+func TestResizeStep(t *testing.T) {
+	tests := []struct {
+		numStreams, peakInUse int
+		waited, overran       bool
+		step                  int
+	}{
+		// Callers waited, so grow unless already at the most
+		{2, 2, true, false, 1},
+		{4, 4, true, false, 0},
+		// Every stream was needed, or all but one
+		{3, 3, false, false, 0},
+		{3, 2, false, false, 0},
+		// Two streams weren't needed
+		{3, 1, false, false, -1},
+		// But not below the least
+		{2, 0, false, false, 0},
+		// Out of bounds, e.g. after the bounds changed
+		{1, 0, false, false, 1},
+		{5, 5, true, false, -1},
+		// Batches overran, so run fewer at once even though callers waited
+		{3, 3, true, true, -1},
+		{2, 2, true, true, 0},
+		{1, 1, true, true, 1},
+	}
+	for i, test := range tests {
+		step := resizeStep(test.numStreams, test.peakInUse, test.waited,
+			test.overran, 2, 4)
+		if step != test.step {
+			t.Errorf("Test %v: expected step %v, got %v", i, test.step, step)
+		}
+	}
+}
+
+// Taking streams should raise the peak, and waiting for one should be noted
+func TestStreamPool_NoteInUse(t *testing.T) {
+	pool := newDummyPool(3)
+	first := pool.TakeStream()
+	second := pool.TakeStream()
+	pool.ReturnStream(first)
+	pool.ReturnStream(second)
+	if pool.peakInUse != 2 || pool.waited {
+		t.Errorf("Expected a peak of 2 without waiting, got %v and %v",
+			pool.peakInUse, pool.waited)
+	}
+}
+
+// A stream dropped from the pool should be gone from its rotation and its
+// health records
+func TestStreamPool_DropFree(t *testing.T) {
+	pool := newDummyPool(2)
+	held := pool.TakeStream()
+	pool.recordBatch(held, nil)
+	dropped, ok := pool.dropFree()
+	if !ok {
+		t.Fatal("Expected a free stream to drop")
+	}
+	if dropped.s == held.s {
+		t.Error("Dropped the stream that was in use")
+	}
+	if len(pool.streams) != 1 || pool.streams[0].s != held.s {
+		t.Errorf("Expected only the held stream to be left, got %v",
+			len(pool.streams))
+	}
+	if _, ok = pool.dropFree(); ok {
+		t.Error("Shouldn't drop a stream when none are free")
+	}
+	pool.ReturnStream(held)
+	if len(pool.StreamStatuses()) != 1 {
+		t.Error("Dropped stream still has a status")
+	}
+}
+
+// Clients and round sessions should be able to count a pool's streams while
+// it's shrinking. Run with -race.
+func TestStreamPool_CountWhileResizing(t *testing.T) {
+	pool := newDummyPool(4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.dropFree()
+		pool.dropFree()
+	}()
+	if _, err := pool.Client("precomp", 1); err != nil {
+		t.Error(err)
+	}
+	session, err := NewRoundSession(pool, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	session.Close()
+}
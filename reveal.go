@@ -27,3 +27,13 @@ func (RevealChunkPrototype) GetInputSize() uint32 {
 func (RevealChunkPrototype) GetName() string {
 	return "RevealChunk"
 }
+
+// RequiresGPU implements Cryptop
+func (RevealChunkPrototype) RequiresGPU() bool {
+	return true
+}
+
+// PrefersGPU implements Cryptop
+func (RevealChunkPrototype) PrefersGPU() bool {
+	return true
+}
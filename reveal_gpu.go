@@ -16,7 +16,10 @@ package gpumaths
 import "C"
 import (
 	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"time"
 )
 
 // reveal_gpu.go contains the CUDA ops for the reveal operation. reveal(...)
@@ -29,33 +32,56 @@ const kernelReveal = C.KERNEL_REVEAL
 // Precondition: All int buffers must have the same length
 var RevealChunk RevealChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 	publicCypherKey *cyclic.Int, cypher *cyclic.IntBuffer, result *cyclic.IntBuffer) error {
+	err := checkLengths("RevealChunk", cypher, result)
+	if err != nil {
+		return err
+	}
+	env, err := envFor("RevealChunk", g)
+	if err != nil {
+		return err
+	}
 	// Populate reveal inputs
 	numSlots := uint32(cypher.Len())
 
-	env := chooseEnv(g)
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	maxSlotsReveal := uint32(env.maxSlots(len(stream.cpuData), kernelReveal))
-	if numSlots > maxSlotsReveal {
-		jww.WARN.Printf("Running multiple kernels for RevealChunk. Performance may be degraded")
-	}
-	for i := uint32(0); i < numSlots; i += maxSlotsReveal {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsReveal <= numSlots {
-			sliceEnd += maxSlotsReveal
-		} else {
-			sliceEnd = numSlots
+	busy := p.busy()
+	maxSlotsReveal := uint32(env.maxSlots(p.memSize, kernelReveal))
+	batchSize := p.governor.batchSize("RevealChunk", maxSlotsReveal)
+	err = p.runHybrid("RevealChunk", numSlots, maxSlotsReveal, busy, func(start, end uint32) error {
+		stream := p.TakeStream()
+		defer func() { p.ReturnStream(stream) }()
+		if end-start > maxSlotsReveal {
+			jww.WARN.Printf("Running multiple kernels for RevealChunk. Performance may be degraded")
+		}
+		for i := start; i < end; i += batchSize {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+batchSize <= end {
+				sliceEnd += batchSize
+			} else {
+				sliceEnd = end
+			}
+			var err error
+			batchStart := time.Now()
+			stream, err = p.runWithRetry(stream, func(s Stream) chan error {
+				return reveal(g, publicCypherKey, cypher.GetSubBuffer(i, sliceEnd), result.GetSubBuffer(i, sliceEnd), env, s)
+			})
+			if err != nil {
+				return err
+			}
+			p.recordTiming("RevealChunk", sliceEnd-i, time.Since(batchStart))
 		}
-		err := <-reveal(g, publicCypherKey, cypher.GetSubBuffer(i, sliceEnd), result.GetSubBuffer(i, sliceEnd), env, stream)
-		if err != nil {
-			return err
+		return nil
+	}, func(start, end uint32) {
+		for i := start; i < end; i++ {
+			cryptops.RootCoprime(g, cypher.Get(i), publicCypherKey, result.Get(i))
 		}
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return checkResults(g, result)
 }
 
 // reveal runs the reveal operation on cypher payloads inside the GPU
@@ -66,54 +92,7 @@ var RevealChunk RevealChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 // bnLength is a length in bits
 // TODO validate BN length in code (i.e. pick kernel variants based on bn length)
 func reveal(g *cyclic.Group, publicCypherKey *cyclic.Int, cypher *cyclic.IntBuffer, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
-	// Return the result later, when the GPU job finishes
-	errors := make(chan error, 1)
-	go func() {
-		// Arrange memory into stream buffers
-		numSlots := uint32(cypher.Len())
-
-		constants := stream.getCpuConstantsWords(env, kernelReveal)
-		offset := 0
-		// Prime
-		bnLengthWords := env.getWordLen()
-		putBits(constants[offset:offset+bnLengthWords], g.GetP().Bits(), bnLengthWords)
-		offset += bnLengthWords
-		// The compted PublicCypherKey
-		putBits(constants[offset:offset+bnLengthWords], publicCypherKey.Bits(), bnLengthWords)
-
-		inputs := stream.getCpuInputsWords(env, kernelReveal, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Put the CypherPayload for this slot
-			putBits(inputs[offset:offset+bnLengthWords], cypher.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
-
-		// Upload, run, wait for download
-		err := env.enqueue(stream, kernelReveal, int(numSlots))
-		if err != nil {
-			errors <- err
-			return
-		}
-
-		// Results will be stored in this buffer
-		results := stream.getCpuOutputsWords(env, kernelReveal, int(numSlots))
-
-		// Wait on things to finish with Cuda
-		err = get(stream)
-		if err != nil {
-			errors <- err
-			return
-		}
-
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			offsetend := offset + bnLengthWords
-			g.OverwriteBits(result.Get(i), results[offset:offsetend])
-			offset += bnLengthWords
-		}
-
-		errors <- nil
-	}()
-	return errors
+	return runKernel(g, kernelReveal,
+		[]large.Bits{g.GetP().Bits(), publicCypherKey.Bits()},
+		[]intGetter{cypher}, []intGetter{result}, env, stream)
 }
@@ -63,7 +63,7 @@ func runRevealGPU(b *testing.B, batchSize uint32) {
 	// Generate the cypher text buffer
 	cypherPayload := initRandomIntBuffer(grp, batchSize, 11, 0)
 
-	env := chooseEnv(grp)
+	env := chooseEnv(grp, kernelReveal)
 	memSize := env.streamSizeContaining(int(batchSize), kernelReveal)
 	b.Log(batchSize, memSize)
 	streamPool, err := NewStreamPool(2, memSize)
@@ -0,0 +1,34 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"errors"
+	"time"
+)
+
+// RoundSession is stubbed unless GPU is present.
+type RoundSession struct{}
+
+func NewRoundSession(p *StreamPool, numStreams int,
+	timeout time.Duration) (*RoundSession, error) {
+	return nil, errors.New(NoGpuErrStr)
+}
+
+func (rs *RoundSession) Pool() *StreamPool {
+	return nil
+}
+
+func (rs *RoundSession) Close() {}
+
+func (rs *RoundSession) Next(numStreams int,
+	timeout time.Duration) (*RoundSession, error) {
+	return nil, errors.New(NoGpuErrStr)
+}
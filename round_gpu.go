@@ -0,0 +1,202 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// round_gpu.go contains RoundSession, which holds on to some of a pool's
+// streams for the length of a round so that one round's batches don't wait on
+// another's, and cleans the streams up when the round is over.
+
+// RoundSession pins streams from a StreamPool to one round. Batches for the
+// round are run by passing Pool() to the chunk functions in place of the
+// pool that the streams came from.
+type RoundSession struct {
+	parent *StreamPool
+	pool   *StreamPool
+	timer  *time.Timer
+	// Makes sure the streams only get cleaned up and returned once
+	closeOnce sync.Once
+	// For sessions started by Next, closed once every stream has arrived
+	pinned chan struct{}
+}
+
+// NewRoundSession takes numStreams streams from the pool, waiting for them
+// to become free if they're in use. If the session hasn't been closed before
+// the timeout, it gets closed automatically, and batches that finish after
+// the timeout count as deadline misses in the parent pool. A timeout of zero
+// means the session is only closed by calling Close.
+func NewRoundSession(p *StreamPool, numStreams int,
+	timeout time.Duration) (*RoundSession, error) {
+	session, deadline, err := newRoundSession(p, numStreams, timeout)
+	if err != nil {
+		return nil, err
+	}
+	// Rounds that have to finish sooner get their streams first
+	for i := 0; i < numStreams; i++ {
+		session.pin(p.TakeStreamBy(deadline))
+	}
+	session.startTimer(timeout)
+	return session, nil
+}
+
+// Next closes this session in the background, and returns a session for the
+// next round straight away. The next round gets this round's streams one at
+// a time, as this round's batches finish with them, so its first uploads
+// overlap this round's last downloads instead of waiting for all of them.
+// Until a stream has arrived, the next round's batches wait in TakeStream.
+// numStreams and timeout are as for NewRoundSession.
+func (rs *RoundSession) Next(numStreams int,
+	timeout time.Duration) (*RoundSession, error) {
+	p := rs.parent
+	next, deadline, err := newRoundSession(p, numStreams, timeout)
+	if err != nil {
+		return nil, err
+	}
+	next.pinned = make(chan struct{})
+	if rs.timer != nil {
+		rs.timer.Stop()
+	}
+	go rs.close()
+	go func() {
+		defer close(next.pinned)
+		for i := 0; i < numStreams; i++ {
+			next.pin(p.TakeStreamBy(deadline))
+		}
+	}()
+	next.startTimer(timeout)
+	return next, nil
+}
+
+// newRoundSession makes a session with no streams yet, and returns the
+// deadline its streams should be taken by
+func newRoundSession(p *StreamPool, numStreams int,
+	timeout time.Duration) (*RoundSession, time.Time, error) {
+	// The pool may be resizing, so its streams can only be counted under
+	// its lock
+	inRotation := p.streamsInRotation()
+	if numStreams <= 0 || numStreams > inRotation {
+		return nil, time.Time{}, errors.Errorf("can't pin %v streams for "+
+			"a round from a pool with %v streams in use", numStreams,
+			inRotation)
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	p.healthLock.Lock()
+	generation := p.generation
+	p.healthLock.Unlock()
+	session := &RoundSession{
+		parent: p,
+		pool: &StreamPool{
+			streamChan:     make(chan Stream, numStreams),
+			memSize:        p.memSize,
+			throughput:     p.throughput,
+			governor:       p.governor,
+			estimates:      p.estimates,
+			failures:       make(map[unsafe.Pointer]int, numStreams),
+			disabled:       make(map[unsafe.Pointer]bool, numStreams),
+			retired:        make(map[unsafe.Pointer]bool, numStreams),
+			retire:         make(chan struct{}, 1),
+			borrowed:       true,
+			origin:         p,
+			generation:     generation,
+			deadline:       deadline,
+			deadlineMisses: p.deadlineMisses,
+			cancelled:      p.cancelled,
+			blindExponents: p.blindExponents,
+			dedupSlots:     p.dedupSlots,
+			policy:         p.policy,
+			returned:       make(map[unsafe.Pointer]time.Time, numStreams),
+			batches:        make(map[unsafe.Pointer]uint64, numStreams),
+		},
+	}
+	return session, deadline, nil
+}
+
+// pin puts a stream taken from the parent into the session's rotation
+func (rs *RoundSession) pin(stream Stream) {
+	rs.pool.healthLock.Lock()
+	rs.pool.streams = append(rs.pool.streams, stream)
+	rs.pool.healthLock.Unlock()
+	// Batches may already be waiting for it
+	rs.pool.returnStream(stream, false)
+}
+
+// startTimer closes the session after timeout, if it's not zero
+func (rs *RoundSession) startTimer(timeout time.Duration) {
+	if timeout > 0 {
+		rs.timer = time.AfterFunc(timeout, func() {
+			jww.WARN.Printf("Round session timed out after %v. Closing it", timeout)
+			rs.close()
+		})
+	}
+}
+
+// Pool returns the pool of streams pinned to this round
+func (rs *RoundSession) Pool() *StreamPool {
+	return rs.pool
+}
+
+// Close waits for the round's batches to finish, zeroes the CPU side of the
+// round's streams so that no key material is left in them, and gives the
+// streams back to the pool they came from as each one frees up. Streams
+// disabled during the round are given back once they've been returned to
+// the session, so Close waits for callers still using them. It's safe to
+// call more than once. Batches run on Pool() after the session is closed
+// take their streams from the pool the session came from.
+func (rs *RoundSession) Close() {
+	if rs.timer != nil {
+		rs.timer.Stop()
+	}
+	rs.close()
+}
+
+// close does the work of Close. The timeout calls it instead of Close, as
+// the timer may not have been stored in the session yet when it fires.
+func (rs *RoundSession) close() {
+	rs.closeOnce.Do(func() {
+		if rs.pinned != nil {
+			<-rs.pinned
+		}
+		// Waiting for every stream to come back means all of the round's
+		// batches are done. Each one in rotation goes back to the parent as
+		// soon as it's free, so the next round can start on it.
+		rs.pool.waitForAll(func(stream Stream) {
+			stream.zero()
+			rs.parent.ReturnStream(stream)
+		})
+		// Disabled streams never come back into rotation, so they're only
+		// given back once they've been retired. The parent has seen their
+		// failures, so it decides whether they go back into its own rotation.
+		rs.pool.healthLock.Lock()
+		var retired []Stream
+		for _, stream := range rs.pool.streams {
+			if rs.pool.retired[stream.s] {
+				retired = append(retired, stream)
+			}
+		}
+		rs.pool.healthLock.Unlock()
+		for _, stream := range retired {
+			stream.zero()
+			rs.parent.ReturnStream(stream)
+		}
+		rs.pool.waitLock.Lock()
+		rs.pool.closed = true
+		rs.pool.waitLock.Unlock()
+	})
+}
@@ -0,0 +1,203 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Streams should be held by the session until it's closed, and come back
+// zeroed
+func TestRoundSession_Close(t *testing.T) {
+	pool := newDummyPool(3)
+	session, err := NewRoundSession(pool, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.streamChan) != 1 {
+		t.Fatalf("Session should have taken 2 of 3 streams, but %v are left",
+			len(pool.streamChan))
+	}
+
+	stream := session.Pool().TakeStream()
+	for i := range stream.cpuData {
+		stream.cpuData[i] = 0xff
+	}
+	session.Pool().ReturnStream(stream)
+	if session.Pool().Destroy() == nil {
+		t.Error("Session's pool shouldn't be able to destroy its streams")
+	}
+
+	session.Close()
+	session.Close()
+	if len(pool.streamChan) != 3 {
+		t.Fatalf("All streams should be back in the pool, but only %v are",
+			len(pool.streamChan))
+	}
+	for _, s := range pool.streams {
+		for i := range s.cpuData {
+			if s.cpuData[i] != 0 {
+				t.Fatal("Stream memory wasn't zeroed when the session closed")
+			}
+		}
+	}
+}
+
+// A session that isn't closed in time should close itself
+func TestRoundSession_Timeout(t *testing.T) {
+	pool := newDummyPool(1)
+	_, err := NewRoundSession(pool, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case stream := <-pool.streamChan:
+		pool.ReturnStream(stream)
+	case <-time.After(time.Second):
+		t.Error("Session didn't return its stream after timing out")
+	}
+}
+
+// A round that outlives its session should take streams from the parent
+// instead of waiting forever for the session's
+func TestRoundSession_TakeAfterClose(t *testing.T) {
+	pool := newDummyPool(2)
+	session, err := NewRoundSession(pool, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+	got := make(chan Stream, 1)
+	go func() { got <- session.Pool().TakeStream() }()
+	select {
+	case stream := <-got:
+		session.Pool().ReturnStream(stream)
+	case <-time.After(time.Second):
+		t.Fatal("Taking a stream from a closed session didn't return")
+	}
+	if len(pool.streamChan) != 2 {
+		t.Errorf("Stream should have gone back to the parent, but %v of 2 "+
+			"are there", len(pool.streamChan))
+	}
+}
+
+// Streams that fail in a session should count as failing in the parent, so
+// they don't go straight back into rotation when the session closes
+func TestRoundSession_RecordsFailures(t *testing.T) {
+	pool := newDummyPool(2)
+	session, err := NewRoundSession(pool, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := session.Pool().TakeStream()
+	for i := 0; i < maxConsecutiveFailures; i++ {
+		session.Pool().recordBatch(stream, errors.New("invalid argument"))
+	}
+	session.Pool().ReturnStream(stream)
+	session.Close()
+	if pool.DisabledStreams() != 1 {
+		t.Errorf("Parent should have disabled the failing stream, but has "+
+			"%v disabled", pool.DisabledStreams())
+	}
+	if len(pool.streamChan) != 1 {
+		t.Errorf("Failing stream went back into the parent's rotation")
+	}
+}
+
+// A stream disabled during the round should only be given back once the
+// caller using it has returned it
+func TestRoundSession_CloseWaitsForDisabled(t *testing.T) {
+	pool := newDummyPool(3)
+	session, err := NewRoundSession(pool, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := session.Pool().TakeStream()
+	for i := 0; i < maxConsecutiveFailures; i++ {
+		session.Pool().recordBatch(stream, errors.New("invalid argument"))
+	}
+	if session.Pool().DisabledStreams() != 1 {
+		t.Fatal("Session should have disabled the failing stream")
+	}
+	done := make(chan struct{})
+	go func() {
+		session.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Session closed while a disabled stream was in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	session.Pool().ReturnStream(stream)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Returning the disabled stream didn't finish closing")
+	}
+	pool.healthLock.Lock()
+	retired := pool.retired[stream.s]
+	pool.healthLock.Unlock()
+	if !retired {
+		t.Error("Parent didn't get the disabled stream back")
+	}
+	if len(pool.streamChan) != 2 {
+		t.Errorf("Parent should have 2 streams in rotation, but has %v",
+			len(pool.streamChan))
+	}
+}
+
+// Asking for more streams than the pool has should fail instead of waiting
+// forever
+func TestNewRoundSession_TooManyStreams(t *testing.T) {
+	pool := newDummyPool(2)
+	_, err := NewRoundSession(pool, 3, 0)
+	if err == nil {
+		t.Error("Session with more streams than the pool should have failed")
+	}
+}
+
+// The next round should be able to start on a stream as soon as this round
+// is done with it, while this round's other batches are still running
+func TestRoundSession_Next(t *testing.T) {
+	pool := newDummyPool(2)
+	session, err := NewRoundSession(pool, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// This round's last batch is still running on one stream
+	running := session.Pool().TakeStream()
+
+	next, err := session.Next(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken := make(chan Stream)
+	go func() { taken <- next.Pool().TakeStream() }()
+	var first Stream
+	select {
+	case first = <-taken:
+	case <-time.After(time.Second):
+		t.Fatal("Next round couldn't start while this round was finishing")
+	}
+	if first.s == running.s {
+		t.Error("Next round got the stream that was still running")
+	}
+
+	session.Pool().ReturnStream(running)
+	next.Pool().ReturnStream(first)
+	next.Close()
+	if len(pool.streamChan) != 2 {
+		t.Errorf("All streams should be back in the pool, but only %v are",
+			len(pool.streamChan))
+	}
+}
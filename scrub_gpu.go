@@ -0,0 +1,161 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*#cgo LDFLAGS: -Llib -lpowmosm75 -Wl,-rpath -Wl,./lib:/opt/xxnetwork/lib
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/crypto/large"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// scrub_gpu.go clears streams that have sat idle for a while. The host side
+// of a stream is cleared after every batch, but the last batch's operands
+// and results stay in device memory until the stream is used again, which on
+// a lightly loaded node can be a long time.
+
+// SetIdleScrub makes the pool clear the device memory of any stream that has
+// been idle for after since it was last used. Zero turns scrubbing off.
+// Scrubbing takes an idle stream out of rotation while it runs, so a caller
+// may wait for one batch of zeros.
+func (sm *StreamPool) SetIdleScrub(after time.Duration) {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	if sm.stopScrub != nil {
+		close(sm.stopScrub)
+		sm.stopScrub = nil
+	}
+	if after <= 0 {
+		sm.idleSince = nil
+		return
+	}
+	if _, _, numSlots := scrubKernel(sm.memSize); numSlots == 0 {
+		jww.WARN.Printf("Idle streams won't be scrubbed, as no kernel "+
+			"fits in a %v byte stream", sm.memSize)
+		sm.idleSince = nil
+		return
+	}
+	sm.idleSince = make(map[unsafe.Pointer]time.Time, len(sm.streams))
+	// Streams that are idle now may have been used before scrubbing was on
+	now := time.Now()
+	for _, s := range sm.streams {
+		sm.idleSince[s.s] = now
+	}
+	sm.stopScrub = make(chan struct{})
+	go sm.scrubIdle(after, sm.stopScrub)
+}
+
+// scrubIdle checks for streams to scrub every half of after until stop is
+// closed
+func (sm *StreamPool) scrubIdle(after time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(after / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		for _, s := range sm.takeIdle(after) {
+			err := s.scrubDevice()
+			if err != nil {
+				jww.WARN.Printf("Couldn't scrub idle stream: %v", err)
+				// Try again next time
+				sm.ReturnStream(s)
+				continue
+			}
+			sm.returnStream(s, false)
+		}
+	}
+}
+
+// takeIdle takes the streams that have been idle for longer than after and
+// haven't been scrubbed yet out of rotation
+func (sm *StreamPool) takeIdle(after time.Duration) []Stream {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	var idle, busy []Stream
+	for len(sm.streamChan) > 0 {
+		s := <-sm.streamChan
+		since, dirty := sm.idleSince[s.s]
+		if dirty && time.Since(since) > after {
+			delete(sm.idleSince, s.s)
+			idle = append(idle, s)
+		} else {
+			busy = append(busy, s)
+		}
+	}
+	for _, s := range busy {
+		sm.streamChan <- s
+	}
+	return idle
+}
+
+// scrubDevice overwrites the stream's device memory by running a batch of
+// zeros through the kernel that covers the most of it. That uploads zeros
+// over the inputs, and the results are computed from zeros and the constants,
+// which are all the tiny prime used for warming up. Less than one slot's
+// worth at the end of the stream can be left as it was. The batch doesn't go
+// through runKernel, so it isn't seen by hooks and isn't counted as work.
+func (s *Stream) scrubDevice() error {
+	env, kernel, numSlots := scrubKernel(len(s.cpuData))
+	if numSlots == 0 {
+		return errors.Errorf("no kernel fits in a %v byte stream",
+			len(s.cpuData))
+	}
+	s.zero()
+	wordLen := env.getWordLen()
+	constants := s.getCpuConstantsWords(env, kernel)
+	for offset := 0; offset+wordLen <= len(constants); offset += wordLen {
+		putBits(constants[offset:offset+wordLen], large.NewInt(23).Bits(),
+			wordLen)
+	}
+	err := env.enqueue(*s, kernel, numSlots)
+	if err == nil {
+		err = get(*s)
+	}
+	s.zero()
+	return err
+}
+
+// scrubKernel returns the kernel in the library whose largest batch covers
+// the most of a stream of size bytes, and the number of slots in that batch.
+// numSlots is 0 if no kernel fits.
+func scrubKernel(size int) (env gpumathsEnv, kernel C.enum_kernel,
+	numSlots int) {
+	kernels := make([]int, 0, len(kernelLayouts))
+	for k := range kernelLayouts {
+		kernels = append(kernels, int(k))
+	}
+	sort.Ints(kernels)
+	covered := 0
+	for _, e := range allEnvs {
+		for _, k := range kernels {
+			if !hasKernel(e, k) {
+				continue
+			}
+			n := e.maxSlots(size, C.enum_kernel(k))
+			if n == 0 {
+				continue
+			}
+			if c := e.streamSizeContaining(n, k); c > covered {
+				env, kernel, numSlots, covered = e, C.enum_kernel(k), n, c
+			}
+		}
+	}
+	return env, kernel, numSlots
+}
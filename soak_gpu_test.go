@@ -0,0 +1,123 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// How many times the soak test samples memory and handles over its run
+const soakSamples = 200
+
+// processStats reads the process's resident and locked memory, in kB, from
+// /proc/self/status. Pinned staging memory shows up as locked.
+func processStats(t *testing.T) (rss, locked uint64) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "VmRSS:":
+			rss, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "VmLck:":
+			locked, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return rss, locked
+}
+
+// openFiles returns the number of file descriptors the process has open,
+// which includes the driver's handles to the device
+func openFiles(t *testing.T) uint64 {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint64(len(fds))
+}
+
+// Creates a pool, runs batches on it and destroys it, over and over for as
+// long as GPUMATHS_SOAK says (e.g. 4h), and fails if host memory, locked
+// memory, open files or goroutines grow steadily over the run. The library
+// has no call to read device memory use, so leaks there only show up if
+// creating streams starts to fail.
+func TestSoak(t *testing.T) {
+	soakFor := os.Getenv("GPUMATHS_SOAK")
+	if soakFor == "" {
+		t.Skip("Set GPUMATHS_SOAK to a duration to run the soak test")
+	}
+	duration, err := time.ParseDuration(soakFor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grp := initTestGroup()
+	const batchSize = 1024
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	z := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+
+	samples := map[string][]uint64{}
+	sampleEvery := duration / soakSamples
+	lastSample := time.Now()
+	end := time.Now().Add(duration)
+	for cycles := 0; time.Now().Before(end); cycles++ {
+		pool, err := NewStreamPool(2, 1<<20)
+		if err != nil {
+			t.Fatalf("Cycle %v: %v", cycles, err)
+		}
+		_, err = ExpChunk(pool, grp, x, y, z)
+		if err != nil {
+			t.Fatalf("Cycle %v: %v", cycles, err)
+		}
+		err = Mul2Chunk(pool, grp, x, y, z)
+		if err != nil {
+			t.Fatalf("Cycle %v: %v", cycles, err)
+		}
+		err = pool.Destroy()
+		if err != nil {
+			t.Fatalf("Cycle %v: %v", cycles, err)
+		}
+
+		if time.Since(lastSample) >= sampleEvery {
+			lastSample = time.Now()
+			runtime.GC()
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			rss, locked := processStats(t)
+			samples["RSS (kB)"] = append(samples["RSS (kB)"], rss)
+			samples["locked memory (kB)"] = append(samples["locked memory (kB)"], locked)
+			samples["open files"] = append(samples["open files"], openFiles(t))
+			samples["goroutines"] = append(samples["goroutines"],
+				uint64(runtime.NumGoroutine()))
+			samples["Go heap (bytes)"] = append(samples["Go heap (bytes)"], mem.HeapAlloc)
+			t.Logf("Cycle %v: RSS %v kB, locked %v kB", cycles, rss, locked)
+		}
+	}
+	for name, s := range samples {
+		if growsSteadily(s) {
+			t.Errorf("%v grew steadily over the run, from %v to %v", name,
+				s[0], s[len(s)-1])
+		}
+	}
+}
@@ -0,0 +1,56 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "testing"
+
+// growsSteadily returns true if samples keep going up over the whole run.
+// The samples are split into four windows, and each window's mean has to be
+// higher than the one before it, so that noise and one-off allocations (e.g.
+// the first pool's caches) don't count as a leak.
+func growsSteadily(samples []uint64) bool {
+	const numWindows = 4
+	if len(samples) < numWindows {
+		return false
+	}
+	windowLen := len(samples) / numWindows
+	var last float64
+	for w := 0; w < numWindows; w++ {
+		var sum float64
+		for _, s := range samples[w*windowLen : (w+1)*windowLen] {
+			sum += float64(s)
+		}
+		mean := sum / float64(windowLen)
+		if w > 0 && mean <= last {
+			return false
+		}
+		last = mean
+	}
+	return true
+}
+
+func TestGrowsSteadily(t *testing.T) {
+	tests := []struct {
+		samples []uint64
+		grows   bool
+	}{
+		{[]uint64{1, 2, 3, 4, 5, 6, 7, 8}, true},
+		// Jumps up once and stays there
+		{[]uint64{1, 9, 9, 9, 9, 9, 9, 9}, false},
+		{[]uint64{5, 4, 6, 5, 4, 6, 5, 5}, false},
+		// Noisy, but always higher on average
+		{[]uint64{10, 12, 11, 13, 12, 14, 13, 15}, true},
+		{[]uint64{1, 2, 3}, false},
+	}
+	for i, test := range tests {
+		if growsSteadily(test.samples) != test.grows {
+			t.Errorf("Test %v: growsSteadily(%v) should be %v", i,
+				test.samples, test.grows)
+		}
+	}
+}
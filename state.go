@@ -0,0 +1,65 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// state.go describes a pool's settings and health in a form that can be
+// saved as JSON or gob, so a node can report how its GPU is set up and an
+// operator's setup can be reproduced from what it reported.
+
+// PoolState is a snapshot of a pool, from StreamPool.State
+type PoolState struct {
+	// Bytes of memory each stream has, which can be less than was asked for
+	StreamSize     int             `json:"streamSize"`
+	Streams        []StreamStatus  `json:"streams"`
+	Waiting        int             `json:"waiting"`
+	Retries        uint64          `json:"retries"`
+	DeadlineMisses uint64          `json:"deadlineMisses"`
+	Policy         SelectionPolicy `json:"policy"`
+	TargetLatency  time.Duration   `json:"targetLatency"`
+	BlindExponents bool            `json:"blindExponents"`
+	DedupSlots     bool            `json:"dedupSlots"`
+	Tags           string          `json:"tags,omitempty"`
+}
+
+var policyNames = map[SelectionPolicy]string{
+	RoundRobin: "RoundRobin",
+	LeastUsed:  "LeastUsed",
+	MostRecent: "MostRecent",
+}
+
+func (policy SelectionPolicy) String() string {
+	if name, ok := policyNames[policy]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// MarshalText writes the policy's name, so that saved states are readable
+func (policy SelectionPolicy) MarshalText() ([]byte, error) {
+	name, ok := policyNames[policy]
+	if !ok {
+		return nil, errors.Errorf("unknown selection policy %d", int(policy))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText reads a policy's name
+func (policy *SelectionPolicy) UnmarshalText(text []byte) error {
+	for p, name := range policyNames {
+		if name == string(text) {
+			*policy = p
+			return nil
+		}
+	}
+	return errors.Errorf("unknown selection policy %q", text)
+}
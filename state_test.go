@@ -0,0 +1,74 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Config and PoolState should come back the same from JSON and gob, with
+// policies saved by name
+func TestPoolState_Serialize(t *testing.T) {
+	state := PoolState{
+		StreamSize: 1 << 20,
+		Streams: []StreamStatus{
+			{Busy: true, Batches: 12},
+			{Disabled: true, Batches: 3},
+		},
+		Retries:       2,
+		Policy:        MostRecent,
+		TargetLatency: 20 * time.Millisecond,
+		Tags:          "round 7",
+	}
+	cfg := Config{NumStreams: 2, StreamSize: 1 << 20, WarmUp: true,
+		IdleScrub: time.Minute}
+
+	for _, value := range []interface{}{state, cfg} {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := reflect.New(reflect.TypeOf(value))
+		err = json.Unmarshal(encoded, decoded.Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.Elem().Interface(), value) {
+			t.Errorf("JSON changed %+v to %+v", value, decoded.Elem())
+		}
+
+		var buf bytes.Buffer
+		err = gob.NewEncoder(&buf).Encode(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = reflect.New(reflect.TypeOf(value))
+		err = gob.NewDecoder(&buf).Decode(decoded.Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.Elem().Interface(), value) {
+			t.Errorf("gob changed %+v to %+v", value, decoded.Elem())
+		}
+	}
+
+	encoded, _ := json.Marshal(state)
+	if !strings.Contains(string(encoded), `"policy":"MostRecent"`) {
+		t.Errorf("Policy should be saved by name: %s", encoded)
+	}
+	var policy SelectionPolicy
+	if policy.UnmarshalText([]byte("Fastest")) == nil {
+		t.Error("Unknown policy names should be rejected")
+	}
+}
@@ -0,0 +1,128 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// status.go keeps track of where each batch is, so that a round monitor can
+// show what the GPU is doing. The library uploads, runs and downloads a batch
+// in one call, so those steps can't be told apart, and a batch is Running
+// from when its upload starts until its results are back.
+
+// JobState is how far a batch has gotten
+type JobState int
+
+const (
+	// JobQueued batches have a stream and are having their operands packed
+	JobQueued JobState = iota
+	// JobRunning batches are being uploaded, run or downloaded
+	JobRunning
+	// JobDone batches have had their results unpacked
+	JobDone
+	// JobFailed batches returned an error
+	JobFailed
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobQueued:
+		return "Queued"
+	case JobRunning:
+		return "Running"
+	case JobDone:
+		return "Done"
+	case JobFailed:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+// JobStatus is a batch's state at some point
+type JobStatus struct {
+	Job   Job
+	State JobState
+	// Set if State is JobFailed
+	Err error
+}
+
+// How many finished batches Status still knows about
+const maxFinishedJobs = 1024
+
+// Source of Job.ID
+var lastJobID uint64
+
+var statuses struct {
+	sync.Mutex
+	jobs map[uint64]JobStatus
+	// IDs of finished batches, oldest first, for forgetting them
+	finished    []uint64
+	subscribers map[chan JobStatus]bool
+}
+
+func nextJobID() uint64 {
+	return atomic.AddUint64(&lastJobID, 1)
+}
+
+// Status returns the state of the batch with the given Job.ID. Batches are
+// forgotten once maxFinishedJobs others have finished after them, and then
+// ok is false.
+func Status(id uint64) (status JobStatus, ok bool) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	status, ok = statuses.jobs[id]
+	return status, ok
+}
+
+// Subscribe returns a channel that gets every batch's status each time it
+// changes, and a function that stops the updates and closes the channel.
+// Updates are dropped rather than holding up batches if the channel's
+// buffer is full.
+func Subscribe(buffer int) (updates <-chan JobStatus, cancel func()) {
+	ch := make(chan JobStatus, buffer)
+	statuses.Lock()
+	if statuses.subscribers == nil {
+		statuses.subscribers = make(map[chan JobStatus]bool)
+	}
+	statuses.subscribers[ch] = true
+	statuses.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			statuses.Lock()
+			delete(statuses.subscribers, ch)
+			statuses.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// setStatus records a batch's new state and tells the subscribers
+func setStatus(status JobStatus) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	if statuses.jobs == nil {
+		statuses.jobs = make(map[uint64]JobStatus)
+	}
+	statuses.jobs[status.Job.ID] = status
+	if status.State == JobDone || status.State == JobFailed {
+		statuses.finished = append(statuses.finished, status.Job.ID)
+		if len(statuses.finished) > maxFinishedJobs {
+			delete(statuses.jobs, statuses.finished[0])
+			statuses.finished = statuses.finished[1:]
+		}
+	}
+	for ch := range statuses.subscribers {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
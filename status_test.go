@@ -0,0 +1,60 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"testing"
+)
+
+// A batch's status should follow it as it runs, and subscribers should see
+// every change. Hooks registered by other tests stay registered, so this
+// calls setStatus the way the fire functions do rather than the functions
+// themselves.
+func TestStatus(t *testing.T) {
+	updates, cancel := Subscribe(8)
+	job := Job{ID: nextJobID(), Kernel: "mul2", NumSlots: 8, BitLen: 2048}
+
+	setStatus(JobStatus{Job: job, State: JobQueued})
+	if status, ok := Status(job.ID); !ok || status.State != JobQueued {
+		t.Errorf("Expected Queued, got %v", status.State)
+	}
+	setStatus(JobStatus{Job: job, State: JobRunning})
+	fail := errors.New("invalid argument")
+	setStatus(JobStatus{Job: job, State: JobFailed, Err: fail})
+	status, ok := Status(job.ID)
+	if !ok || status.State != JobFailed || status.Err != fail {
+		t.Errorf("Expected Failed with the batch's error, got %+v", status)
+	}
+
+	cancel()
+	var states []JobState
+	for status := range updates {
+		if status.Job == job {
+			states = append(states, status.State)
+		}
+	}
+	if len(states) != 3 || states[0] != JobQueued ||
+		states[1] != JobRunning || states[2] != JobFailed {
+		t.Errorf("Expected Queued, Running, Failed, got %v", states)
+	}
+	// Cancelling again shouldn't close the channel twice
+	cancel()
+}
+
+// Old finished batches should be forgotten
+func TestStatus_Forget(t *testing.T) {
+	first := Job{ID: nextJobID()}
+	setStatus(JobStatus{Job: first, State: JobDone})
+	for i := 0; i < maxFinishedJobs; i++ {
+		setStatus(JobStatus{Job: Job{ID: nextJobID()}, State: JobDone})
+	}
+	if _, ok := Status(first.ID); ok {
+		t.Error("The oldest batch should have been forgotten")
+	}
+}
@@ -9,7 +9,10 @@
 
 package gpumaths
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // Stub out all exported symbols with reduced functionality
 type Stream struct{}
@@ -32,12 +35,78 @@ func (sm *StreamPool) TakeStream() Stream {
 	return Stream{}
 }
 
+func (sm *StreamPool) TakeStreamBy(deadline time.Time) Stream {
+	return Stream{}
+}
+
 func (sm *StreamPool) ReturnStream(s Stream) {}
 
+func (sm *StreamPool) SetTargetLatency(target time.Duration) {}
+
+func (sm *StreamPool) SetExponentBlinding(blind bool) {}
+
+func (sm *StreamPool) SetSlotDedup(dedup bool) {}
+
+func (sm *StreamPool) SetIdleScrub(after time.Duration) {}
+
+func (sm *StreamPool) SetAutoResize(min, max int, interval time.Duration) error {
+	return errors.New(NoGpuErrStr)
+}
+
+func (sm *StreamPool) SetTags(tags ...string) {}
+
+func (sm *StreamPool) CancelTag(tag string) {}
+
+func (sm *StreamPool) SetSelectionPolicy(policy SelectionPolicy) {}
+
+func (sm *StreamPool) StreamStatuses() []StreamStatus {
+	return nil
+}
+
+func (sm *StreamPool) State() PoolState {
+	return PoolState{}
+}
+
+func (sm *StreamPool) Waiting() int {
+	return 0
+}
+
+func (sm *StreamPool) Estimate(op string, numSlots uint32) time.Duration {
+	return 0
+}
+
+func (sm *StreamPool) Tuning() Tuning {
+	return Tuning{}
+}
+
+func (sm *StreamPool) SetTuning(tuning Tuning) {}
+
+func (sm *StreamPool) SetCrossover(op string, numSlots uint32) {}
+
+func (sm *StreamPool) DeadlineMisses() uint64 {
+	return 0
+}
+
 func (sm *StreamPool) Destroy() error {
 	return errors.New("gpumaths stubbed build doesn't support CUDA stream pool")
 }
 
+func (sm *StreamPool) Reload() error {
+	return errors.New("gpumaths stubbed build doesn't support CUDA stream pool")
+}
+
+func (sm *StreamPool) WarmUp() error {
+	return errors.New("gpumaths stubbed build doesn't support CUDA stream pool")
+}
+
+func (sm *StreamPool) Retries() uint64 {
+	return 0
+}
+
+func (sm *StreamPool) DisabledStreams() int {
+	return 0
+}
+
 func MaxSlots(memSize int, op int) int {
 	return 0
 }
@@ -45,3 +114,7 @@ func MaxSlots(memSize int, op int) int {
 func streamSizeContaining(numItems int, kernel int) int {
 	return 0
 }
+
+func AvailableKernels() map[int][]string {
+	return nil
+}
@@ -18,10 +18,25 @@ package gpumaths
 */
 import "C"
 import (
+	"container/heap"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/xx_network/crypto/large"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
+// maxRetries is how many times a batch that failed with a transient error
+// gets rerun before the error is returned to the caller
+const maxRetries = 2
+
+// maxConsecutiveFailures is how many batches in a row can fail on a stream
+// before the pool stops scheduling work to that stream
+const maxConsecutiveFailures = 3
+
 // TODO Functions that currently take a stream as unsafe.Pointer should instead have a stream as the receiver
 type Stream struct {
 	// Pointer to stream and associated data, usable only on the C side
@@ -30,6 +45,12 @@ type Stream struct {
 	cpuData []byte
 	// Same data but in words!
 	cpuDataWords large.Bits
+	// Tags of the pool the stream was taken from, which the batches run on
+	// it carry into hooks and errors
+	tags string
+	// Which of the pool's sets of streams this is one of, so that a stream
+	// returned after Reload replaced it isn't put back into rotation
+	generation uint64
 }
 
 // Return the portion of the stream's CPU memory that's used for outputs
@@ -47,18 +68,133 @@ func (s *Stream) getCpuInputsWords(g gpumathsEnv, kernel C.enum_kernel, numItems
 	return s.cpuDataWords[start:end]
 }
 
+// zero overwrites the stream's CPU memory with zeroes
+func (s *Stream) zero() {
+	for i := range s.cpuData {
+		s.cpuData[i] = 0
+	}
+}
+
+// scrub overwrites the part of the stream's CPU memory that a batch of
+// numItems items of kernel used with zeroes, so that operands like private
+// keys don't stay in pinned host memory after the batch is done
+func (s *Stream) scrub(g gpumathsEnv, kernel C.enum_kernel, numItems int) {
+	end := g.getConstantsSizeWords(kernel) +
+		(g.getInputSizeWords(kernel)+g.getOutputSizeWords(kernel))*numItems
+	// A batch that was refused for not fitting never wrote past the end
+	if end > len(s.cpuDataWords) {
+		end = len(s.cpuDataWords)
+	}
+	used := s.cpuDataWords[:end]
+	for i := range used {
+		used[i] = 0
+	}
+}
+
 // Constants exist at the very start of the buffer
 func (s *Stream) getCpuConstantsWords(g gpumathsEnv, kernel C.enum_kernel) large.Bits {
 	return s.cpuDataWords[:g.getConstantsSizeWords(kernel)]
 }
 
 // Optional improvements:
-//  - create streams with high priority to speed up kernels used for realtime
+//   - create streams with high priority to speed up kernels used for realtime
+//   - keep a kernel's constants on the device between batches that share
+//     them. The library's enqueue functions upload the constants with every
+//     batch, so this needs a native entry point that skips that upload.
 type StreamPool struct {
 	// Used to prevent concurrent access to streams
 	streamChan chan Stream
 	// Used to time-bound stream deletion. These are the same streams that you can get from the channel
 	streams []Stream
+	// Capacity of each stream in bytes, needed to create them again on reload
+	memSize int
+	// Number of times a batch has been rerun after a transient error
+	// Must be accessed atomically
+	retries uint64
+	// Guards failures and disabled
+	healthLock sync.Mutex
+	// Number of batches in a row that have failed on each stream
+	failures map[unsafe.Pointer]int
+	// Streams that have been taken out of rotation for failing too many
+	// batches in a row
+	disabled map[unsafe.Pointer]bool
+	// Disabled streams that have been returned, so nothing is running on
+	// them any more
+	retired map[unsafe.Pointer]bool
+	// Signalled when a disabled stream is returned
+	retire chan struct{}
+	// Incremented each time Reload replaces the streams. Guarded by
+	// healthLock.
+	generation uint64
+	// Set while Reload is taking every stream out of rotation. Returned
+	// streams go into streamChan for it instead of to waiters, and callers
+	// wait. Guarded by waitLock.
+	holding bool
+	// How fast each op has been running on the GPU and the CPU
+	throughput *throughputs
+	// How many slots each op should run per kernel to meet the target
+	// latency
+	governor *governor
+	// How long each op's batches take, for Estimate
+	estimates *estimator
+	// Set if the streams are borrowed from another pool by a RoundSession
+	// or a client, in which case this pool can't destroy or reload them
+	borrowed bool
+	// Guards waiting and waitSeq, and makes sure a returned stream either
+	// goes to a waiter or back into streamChan
+	waitLock sync.Mutex
+	// Callers waiting for a stream, earliest deadline on top
+	waiting waiters
+	waitSeq uint64
+	// Batches run on this pool should finish by this time. Zero if they
+	// don't have a deadline.
+	deadline time.Time
+	// Number of batches that finished after their deadline, shared with
+	// round sessions' pools. Must be accessed atomically.
+	deadlineMisses *uint64
+	// Set if ExpChunk should split exponents into random shares before
+	// they're uploaded
+	blindExponents bool
+	// Set if ExpChunk should only run one slot for each distinct pair of
+	// operands
+	dedupSlots bool
+	// When each stream that hasn't been scrubbed since it was last used
+	// went back into streamChan. Guarded by waitLock, and nil unless idle
+	// scrubbing is on.
+	idleSince map[unsafe.Pointer]time.Time
+	// Closed to stop the idle scrubber
+	stopScrub chan struct{}
+	// Which free stream TakeStream hands out. Guarded by waitLock.
+	policy SelectionPolicy
+	// When each stream last went back into streamChan. Guarded by waitLock.
+	returned map[unsafe.Pointer]time.Time
+	// Number of batches each stream has run. Guarded by healthLock.
+	batches map[unsafe.Pointer]uint64
+	// Given to every stream taken from the pool. Guarded by waitLock.
+	tags string
+	// Tags whose batches shouldn't run, shared with round sessions' pools
+	cancelled *cancelledTags
+	// Set if this pool is a client of another, in which case its streams
+	// are taken from parent
+	parent *StreamPool
+	// Has an entry for each stream the client holds, so it blocks when the
+	// client is at its quota
+	quota chan struct{}
+	// Set if this pool is a round session's, in which case its batches'
+	// failures are also recorded in origin, the pool its streams were
+	// pinned from
+	origin *StreamPool
+	// Set once the round session has given its streams back, after which
+	// streams are taken from origin instead. Guarded by waitLock.
+	closed bool
+	// Held while streams are added or removed, or all of them are held
+	resizeLock sync.Mutex
+	// The most streams in use at once, and whether a caller had to wait for
+	// one, since the auto resizer last looked. Guarded by waitLock.
+	peakInUse int
+	waited    bool
+	// Closed to stop the auto resizer
+	stopResize chan struct{}
 }
 
 // numStreams: Number of streams per device. 2 is usually fine
@@ -68,14 +204,46 @@ func NewStreamPool(numStreams int, memSize int) (*StreamPool, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Make sure the library agrees with the bindings on how memory is laid
+	// out before any of it gets written
+	err = checkLibrary()
+	if err != nil {
+		return nil, err
+	}
 	// Each stream should support all operations if there's enough memory available
 	var result StreamPool
+	// If there isn't enough contiguous memory for streams of the requested
+	// size, smaller streams still work. The ops just need to run more
+	// kernels per chunk.
 	streams, err := createStreams(numStreams, memSize)
+	requestedSize := memSize
+	for err != nil && memSize/2 >= minStreamSize() {
+		jww.WARN.Printf("Couldn't create %v streams of %v bytes: %v. "+
+			"Trying again with %v bytes", numStreams, memSize, err, memSize/2)
+		memSize /= 2
+		streams, err = createStreams(numStreams, memSize)
+	}
 	if err != nil {
-		// TODO Destroy streams first before returning
-		return nil, err
+		return nil, wrapMemlock(err, numStreams*memSize)
+	}
+	if memSize != requestedSize {
+		jww.WARN.Printf("Created %v streams of %v bytes instead of the "+
+			"requested %v bytes. Performance may be degraded", numStreams,
+			memSize, requestedSize)
 	}
 	result.streams = streams
+	result.memSize = memSize
+	result.throughput = newThroughputs()
+	result.governor = newGovernor()
+	result.estimates = newEstimator()
+	result.deadlineMisses = new(uint64)
+	result.cancelled = newCancelledTags()
+	result.failures = make(map[unsafe.Pointer]int, len(streams))
+	result.batches = make(map[unsafe.Pointer]uint64, len(streams))
+	result.returned = make(map[unsafe.Pointer]time.Time, len(streams))
+	result.disabled = make(map[unsafe.Pointer]bool, len(streams))
+	result.retired = make(map[unsafe.Pointer]bool, len(streams))
+	result.retire = make(chan struct{}, 1)
 	result.streamChan = make(chan Stream, len(streams))
 	for i := range result.streams {
 		result.streamChan <- result.streams[i]
@@ -84,21 +252,651 @@ func NewStreamPool(numStreams int, memSize int) (*StreamPool, error) {
 	return &result, err
 }
 
+// wrapMemlock adds an explanation to an error from creating streams if the
+// locked memory limit is too low for the streams' pinned host memory, which
+// is common in containers
+func wrapMemlock(err error, needed int) error {
+	diagnosis := memlockDiagnosis(needed)
+	if diagnosis == "" {
+		return err
+	}
+	return errors.Wrap(err, diagnosis)
+}
+
 // If you need to, it's also possible to create an equivalent method that times out
 // This method gets a stream from the channel
 func (sm *StreamPool) TakeStream() Stream {
-	return <-sm.streamChan
+	return sm.TakeStreamBy(sm.deadline)
 }
 
+// TakeStreamBy gets a stream for work that should be done by the deadline.
+// If callers are waiting for streams, the one with the earliest deadline
+// gets the next stream that's returned. A zero deadline waits behind
+// everything that has one.
+func (sm *StreamPool) TakeStreamBy(deadline time.Time) Stream {
+	return sm.takeStream(deadline, nil)
+}
+
+// takeStream does the work of TakeStreamBy. If exclude isn't nil, the stream
+// it points to isn't handed out, so that a failed batch is retried on
+// another stream.
+func (sm *StreamPool) takeStream(deadline time.Time,
+	exclude unsafe.Pointer) Stream {
+	if sm.parent != nil {
+		return sm.takeFromParent(deadline, exclude)
+	}
+	sm.waitLock.Lock()
+	tags := sm.tags
+	if sm.closed {
+		// A round that outlives its session still gets to finish
+		sm.waitLock.Unlock()
+		s := sm.origin.takeStream(deadline, exclude)
+		s.tags = tags
+		return s
+	}
+	if !sm.holding {
+		if s, ok := sm.takeFree(exclude); ok {
+			sm.noteInUse()
+			sm.waitLock.Unlock()
+			s.tags = tags
+			return s
+		}
+	}
+	sm.waited = true
+	w := &waiter{
+		deadline: deadline,
+		seq:      sm.waitSeq,
+		stream:   make(chan Stream, 1),
+		exclude:  exclude,
+	}
+	sm.waitSeq++
+	heap.Push(&sm.waiting, w)
+	sm.waitLock.Unlock()
+	s := <-w.stream
+	s.tags = tags
+	return s
+}
+
+// SetTags sets tags, such as a round ID and phase name, that every batch run
+// on the pool's streams from now on carries. They're in the Job passed to
+// hooks and in the errors the batches return, so failures can be traced
+// back to the work they were part of. Set them on a round session's pool to
+// tag only that round's work.
+func (sm *StreamPool) SetTags(tags ...string) {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	sm.tags = strings.Join(tags, ", ")
+}
+
+// takeFree takes the free stream that the pool's policy chooses, other than
+// exclude. ok is false if there's no such stream. waitLock must be held.
+func (sm *StreamPool) takeFree(exclude unsafe.Pointer) (s Stream, ok bool) {
+	if len(sm.streamChan) == 0 {
+		return Stream{}, false
+	}
+	if exclude == nil &&
+		(sm.policy == RoundRobin || len(sm.streamChan) == 1) {
+		return <-sm.streamChan, true
+	}
+	free := make([]Stream, 0, len(sm.streamChan))
+	candidates := make([]int, 0, len(sm.streamChan))
+	for len(sm.streamChan) > 0 {
+		s := <-sm.streamChan
+		if s.s != exclude {
+			candidates = append(candidates, len(free))
+		}
+		free = append(free, s)
+	}
+	chosen := -1
+	if len(candidates) > 0 {
+		chosen = candidates[0]
+		if sm.policy != RoundRobin {
+			history := make([]freeStream, len(candidates))
+			sm.healthLock.Lock()
+			for i, c := range candidates {
+				history[i] = freeStream{
+					batches:  sm.batches[free[c].s],
+					returned: sm.returned[free[c].s],
+				}
+			}
+			sm.healthLock.Unlock()
+			chosen = candidates[sm.policy.pick(history)]
+		}
+	}
+	for i, s := range free {
+		if i != chosen {
+			sm.streamChan <- s
+		}
+	}
+	if chosen < 0 {
+		return Stream{}, false
+	}
+	return free[chosen], true
+}
+
+// SetSelectionPolicy sets which free stream TakeStream hands out when more
+// than one is free. Round sessions use the policy the pool had when they
+// were created.
+func (sm *StreamPool) SetSelectionPolicy(policy SelectionPolicy) {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	sm.policy = policy
+}
+
+// StreamStatuses reports on each of the pool's streams, in the order they
+// were created
+func (sm *StreamPool) StreamStatuses() []StreamStatus {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	free := make(map[unsafe.Pointer]bool, len(sm.streamChan))
+	for i := len(sm.streamChan); i > 0; i-- {
+		s := <-sm.streamChan
+		free[s.s] = true
+		sm.streamChan <- s
+	}
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	statuses := make([]StreamStatus, len(sm.streams))
+	for i, s := range sm.streams {
+		statuses[i] = StreamStatus{
+			Busy:     !free[s.s] && !sm.disabled[s.s],
+			Disabled: sm.disabled[s.s],
+			Failing:  sm.failures[s.s] >= maxConsecutiveFailures,
+			Batches:  sm.batches[s.s],
+		}
+	}
+	return statuses
+}
+
+// CancelTag stops work tagged with tag (see SetTags) on this pool and the
+// round sessions created from it. Batches that haven't started won't run,
+// and ops with batches that are running return ErrCancelled once they
+// finish, without running any more. Work that's tagged later with the same
+// tag is cancelled too.
+func (sm *StreamPool) CancelTag(tag string) {
+	sm.cancelled.add(tag)
+}
+
+// State returns a snapshot of the pool's settings and the health of its
+// streams
+func (sm *StreamPool) State() PoolState {
+	state := PoolState{
+		StreamSize:     sm.memSize,
+		Streams:        sm.StreamStatuses(),
+		Retries:        sm.Retries(),
+		DeadlineMisses: sm.DeadlineMisses(),
+		TargetLatency:  sm.governor.getTarget(),
+		BlindExponents: sm.blindExponents,
+		DedupSlots:     sm.dedupSlots,
+	}
+	sm.waitLock.Lock()
+	state.Waiting = sm.waiting.Len()
+	state.Policy = sm.policy
+	state.Tags = sm.tags
+	sm.waitLock.Unlock()
+	return state
+}
+
+// Waiting returns the number of callers waiting for a stream
+func (sm *StreamPool) Waiting() int {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	return sm.waiting.Len()
+}
+
+// Streams that have been disabled for failing too many batches in a row
+// don't go back into the pool
 func (sm *StreamPool) ReturnStream(s Stream) {
-	if s.s != nil {
+	sm.returnStream(s, true)
+}
+
+// returnStream puts s back into rotation. If dirty is set, the stream may
+// have key material left on the device, and it's scrubbed once it's been
+// idle for long enough.
+func (sm *StreamPool) returnStream(s Stream, dirty bool) {
+	if sm.parent != nil {
+		sm.returnToParent(s, dirty)
+		return
+	}
+	if sm.origin != nil && sm.isClosed() {
+		sm.origin.returnStream(s, dirty)
+		return
+	}
+	if s.s == nil {
+		return
+	}
+	if !sm.inRotation(s) {
+		sm.relaxExclusions()
+		return
+	}
+	sm.waitLock.Lock()
+	var w *waiter
+	if !sm.holding {
+		w = sm.nextWaiter(s)
+	}
+	if w != nil {
+		w.stream <- s
+	} else {
+		if dirty && sm.idleSince != nil {
+			sm.idleSince[s.s] = time.Now()
+		}
+		if sm.returned != nil {
+			sm.returned[s.s] = time.Now()
+		}
 		sm.streamChan <- s
 	}
+	sm.waitLock.Unlock()
+}
+
+// nextWaiter takes the first waiter in line that can have s off the queue.
+// It returns nil if no one is waiting for s. waitLock must be held.
+func (sm *StreamPool) nextWaiter(s Stream) *waiter {
+	var skipped []*waiter
+	var next *waiter
+	for sm.waiting.Len() > 0 {
+		w := heap.Pop(&sm.waiting).(*waiter)
+		if w.exclude != s.s {
+			next = w
+			break
+		}
+		skipped = append(skipped, w)
+	}
+	for _, w := range skipped {
+		heap.Push(&sm.waiting, w)
+	}
+	return next
+}
+
+// relaxExclusions lets waiters have the stream their batch failed on once
+// it's the only stream left in rotation, as no other is coming
+func (sm *StreamPool) relaxExclusions() {
+	if sm.streamsInRotation() > 1 {
+		return
+	}
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	for _, w := range sm.waiting {
+		w.exclude = nil
+	}
+	for !sm.holding && len(sm.streamChan) > 0 && sm.waiting.Len() > 0 {
+		heap.Pop(&sm.waiting).(*waiter).stream <- <-sm.streamChan
+	}
+}
+
+// SetTargetLatency makes each op run fewer slots per kernel if its batches
+// take longer than target, and more again when they finish in time, up to
+// what fits in a stream. Zero turns this off, so that every kernel runs as
+// many slots as fit. With SetAutoResize on, the pool also runs fewer batches
+// at once if they still take too long. Round sessions created from the pool
+// share its target.
+func (sm *StreamPool) SetTargetLatency(target time.Duration) {
+	sm.governor.setTarget(target)
+}
+
+// Estimate returns how long op, named as in the chunk functions (e.g.
+// "ExpChunk"), should take to run numSlots slots on one stream. It's learned
+// from the batches the pool has run, so it's 0 until op has run at least
+// once. Servers can compare it with the time left in a round to decide
+// whether to run a phase on the GPU or the CPU.
+func (sm *StreamPool) Estimate(op string, numSlots uint32) time.Duration {
+	return sm.estimates.estimate(op, numSlots)
+}
+
+// Tuning returns what the pool has learned about how fast each op runs,
+// which can be saved with SaveTuning and given to a new pool with SetTuning
+func (sm *StreamPool) Tuning() Tuning {
+	gpuRates, cpuRates := sm.throughput.getRates()
+	return Tuning{
+		TargetLatency: sm.governor.getTarget(),
+		BatchLimits:   sm.governor.getLimits(),
+		GPURates:      gpuRates,
+		CPURates:      cpuRates,
+		Crossovers:    sm.throughput.getCrossovers(),
+		Estimates:     sm.estimates.getModels(),
+	}
+}
+
+// SetTuning replaces what the pool has learned about how fast each op runs.
+// Batch limits are only used if the pool already has the target latency
+// they were learned for, so SetTargetLatency should be called first.
+func (sm *StreamPool) SetTuning(tuning Tuning) {
+	sm.governor.setLimits(tuning.TargetLatency, tuning.BatchLimits)
+	sm.throughput.setRates(tuning.GPURates, tuning.CPURates)
+	sm.throughput.setCrossovers(tuning.Crossovers)
+	sm.estimates.setModels(tuning.Estimates)
+}
+
+// SetCrossover makes chunks of op, named as in the chunk functions, run on
+// the CPU alone if they have fewer than numSlots slots, as starting a kernel
+// costs more than it saves for them. The GPU crossover test in this package
+// measures it for each op on the machine it runs on. Zero sends every chunk
+// to the GPU, which is the default. Round sessions created from the pool
+// share its crossovers.
+func (sm *StreamPool) SetCrossover(op string, numSlots uint32) {
+	sm.throughput.setCrossover(op, numSlots)
+}
+
+// recordTiming feeds the time a batch took to the governor and the estimates
+func (sm *StreamPool) recordTiming(op string, numSlots uint32,
+	elapsed time.Duration) {
+	sm.governor.record(op, numSlots, elapsed)
+	sm.estimates.record(op, numSlots, elapsed)
+}
+
+// SetExponentBlinding turns exponent blinding for ExpChunk on or off. With
+// it on, the exponents never appear in GPU memory as they are. Each one is
+// split into two random shares that are exponentiated separately, which
+// takes twice the GPU time. ElGamalChunk and RevealChunk aren't blinded.
+// This should be set before the pool is used, and round sessions created
+// from the pool use the same setting.
+func (sm *StreamPool) SetExponentBlinding(blind bool) {
+	sm.blindExponents = blind
+}
+
+// SetSlotDedup turns on or off finding the slots of an ExpChunk that have
+// the same base and exponent as another. With it on, each distinct pair is
+// only run once on the GPU and its result is copied to the other slots. That
+// saves a lot of GPU time for chunks padded with dummy slots, at the cost of
+// hashing every slot's operands. Round sessions created from the pool use
+// the same setting.
+func (sm *StreamPool) SetSlotDedup(dedup bool) {
+	sm.dedupSlots = dedup
+}
+
+// DeadlineMisses returns the number of batches that finished after their
+// round's deadline since the pool was created
+func (sm *StreamPool) DeadlineMisses() uint64 {
+	return atomic.LoadUint64(sm.deadlineMisses)
 }
 
 // Destroy all the stream pool's streams
 // This doesn't wait on any work to finish before destroying the streams.
 // If it's a problem in the future I'll have this method empty the channel before destroying the streams.
+// Only this pool's streams and their memory are freed. The device and its
+// context are left alone, so other pools and other CUDA users in the
+// process keep working. Nothing in this package resets the device.
 func (sm *StreamPool) Destroy() error {
+	if sm.borrowed {
+		return errors.New("can't destroy streams borrowed from another pool")
+	}
+	sm.SetIdleScrub(0)
+	sm.SetAutoResize(0, 0, 0)
+	sm.resizeLock.Lock()
+	defer sm.resizeLock.Unlock()
 	return destroyStreams(sm.streams)
 }
+
+// Reload waits for the work on all the pool's streams to finish, then
+// destroys them and creates the same number of new streams with the same
+// capacity, bringing any disabled streams back into rotation.
+// The CUDA library is linked into the process by cgo, so this can't switch to
+// a different version of it. That still needs a restart.
+// If Reload returns an error, the pool has no streams left and must not be
+// used.
+func (sm *StreamPool) Reload() error {
+	if sm.borrowed {
+		return errors.New("can't reload streams borrowed from another pool")
+	}
+	sm.resizeLock.Lock()
+	defer sm.resizeLock.Unlock()
+	sm.holdAll()
+	numStreams := len(sm.streams)
+	err := destroyStreams(sm.streams)
+	// Whether or not that worked, the old streams mustn't be used again
+	sm.waitLock.Lock()
+	sm.healthLock.Lock()
+	sm.holding = false
+	sm.streams = nil
+	sm.generation++
+	generation := sm.generation
+	sm.failures = make(map[unsafe.Pointer]int, numStreams)
+	sm.disabled = make(map[unsafe.Pointer]bool, numStreams)
+	sm.retired = make(map[unsafe.Pointer]bool, numStreams)
+	sm.batches = make(map[unsafe.Pointer]uint64, numStreams)
+	sm.healthLock.Unlock()
+	sm.waitLock.Unlock()
+	if err != nil {
+		return err
+	}
+	err = initCuda()
+	if err != nil {
+		return err
+	}
+	streams, err := createStreams(numStreams, sm.memSize)
+	if err != nil {
+		return wrapMemlock(err, numStreams*sm.memSize)
+	}
+	for i := range streams {
+		streams[i].generation = generation
+	}
+
+	sm.waitLock.Lock()
+	sm.healthLock.Lock()
+	sm.streams = streams
+	sm.healthLock.Unlock()
+	sm.waitLock.Unlock()
+	for i := range streams {
+		// Callers may have started waiting while the streams were reloaded
+		sm.returnStream(streams[i], false)
+	}
+	return nil
+}
+
+// holdAll takes every stream out of rotation, waiting for the ones in use to
+// be returned. Disabled streams don't go back into rotation, so they're
+// counted once they've been returned instead. Until the caller clears
+// holding, returned streams aren't handed to waiters, as under load they
+// would never reach streamChan. resizeLock must be held.
+func (sm *StreamPool) holdAll() {
+	sm.waitLock.Lock()
+	sm.holding = true
+	sm.waitLock.Unlock()
+	sm.waitForAll(func(Stream) {})
+}
+
+// waitForAll passes each stream in rotation to held as it comes back into
+// streamChan, and returns once every other stream has been retired
+func (sm *StreamPool) waitForAll(held func(Stream)) {
+	numHeld := 0
+	for {
+		sm.healthLock.Lock()
+		done := numHeld+len(sm.retired) >= len(sm.streams)
+		sm.healthLock.Unlock()
+		if done {
+			return
+		}
+		select {
+		case s := <-sm.streamChan:
+			held(s)
+			numHeld++
+		case <-sm.retire:
+		}
+	}
+}
+
+// Retries returns the number of times a batch has been rerun after a
+// transient error since the pool was created
+func (sm *StreamPool) Retries() uint64 {
+	return atomic.LoadUint64(&sm.retries)
+}
+
+// runWithRetry runs a batch on the stream. If the batch fails with a
+// transient error, the stream is returned to the pool and the batch is rerun
+// on another stream from the pool, if there is one. The stream that the batch last ran on is returned for
+// the caller to continue with (and eventually return to the pool).
+func (sm *StreamPool) runWithRetry(stream Stream, batch func(Stream) chan error) (Stream, error) {
+	if sm.cancelled.has(stream.tags) {
+		return stream, ErrCancelled
+	}
+	err := <-batch(stream)
+	sm.recordBatch(stream, err)
+	for i := 0; i < maxRetries && IsTransient(err); i++ {
+		atomic.AddUint64(&sm.retries, 1)
+		jww.WARN.Printf("Retrying batch after transient error: %v", err)
+		sm.ReturnStream(stream)
+		stream = sm.takeForRetry(stream)
+		err = <-batch(stream)
+		sm.recordBatch(stream, err)
+	}
+	if !sm.deadline.IsZero() && time.Now().After(sm.deadline) {
+		atomic.AddUint64(sm.deadlineMisses, 1)
+	}
+	// A batch that was running when its tag was cancelled is abandoned
+	if err == nil && sm.cancelled.has(stream.tags) {
+		return stream, ErrCancelled
+	}
+	return stream, err
+}
+
+// takeForRetry takes a stream to rerun a batch that failed on failed. It's a
+// different stream, unless failed is the only one left in rotation.
+func (sm *StreamPool) takeForRetry(failed Stream) Stream {
+	exclude := failed.s
+	if sm.streamsInRotation() < 2 {
+		exclude = nil
+	}
+	return sm.takeStream(sm.deadline, exclude)
+}
+
+// numStreams returns the number of streams in the pool, including disabled
+// ones. The auto resizer can change it at any time.
+func (sm *StreamPool) numStreams() int {
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	return len(sm.streams)
+}
+
+// streamsInRotation returns the number of streams TakeStream can hand out,
+// which are the ones that haven't been disabled
+func (sm *StreamPool) streamsInRotation() int {
+	if sm.parent != nil {
+		return sm.parent.streamsInRotation()
+	}
+	if sm.origin != nil && sm.isClosed() {
+		return sm.origin.streamsInRotation()
+	}
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	return len(sm.streams) - len(sm.disabled)
+}
+
+// busy returns true if there's no stream free to take right now
+func (sm *StreamPool) busy() bool {
+	if sm.parent != nil {
+		return len(sm.quota) == cap(sm.quota) || sm.parent.busy()
+	}
+	// streamChan is replaced when the pool grows
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	return len(sm.streamChan) == 0
+}
+
+// runHybrid runs slots [0, numSlots) of an op. Chunks smaller than the op's
+// crossover run on the CPU alone. Otherwise, if the GPU can't run them all
+// in one kernel, or the pool was busy when the op was called, a share of the
+// slots goes to the CPU in proportion to how fast each side has been running
+// the op, and both sides run at the same time. gpu and cpu each run the slots
+// in [start, end), and the slots they're given never overlap.
+func (sm *StreamPool) runHybrid(op string, numSlots, maxSlots uint32,
+	busy bool, gpu func(start, end uint32) error, cpu func(start, end uint32)) error {
+	gpuSlots := numSlots
+	if sm.throughput.belowCrossover(op, numSlots) {
+		gpuSlots = 0
+	} else if numSlots > maxSlots || busy {
+		gpuSlots -= sm.throughput.cpuSlots(op, numSlots)
+	}
+
+	cpuDone := make(chan struct{})
+	go func() {
+		start := time.Now()
+		cpu(gpuSlots, numSlots)
+		sm.throughput.record(op, false, numSlots-gpuSlots, time.Since(start))
+		close(cpuDone)
+	}()
+	var err error
+	// The GPU side takes a stream, so it's skipped if it has nothing to run
+	if gpuSlots > 0 {
+		start := time.Now()
+		err = gpu(0, gpuSlots)
+		if err == nil {
+			sm.throughput.record(op, true, gpuSlots, time.Since(start))
+		}
+	}
+	<-cpuDone
+	return err
+}
+
+// recordBatch keeps count of the batches in a row that have failed on a
+// stream, and takes the stream out of rotation once too many have failed.
+// The last working stream is never disabled, as that would leave every
+// caller blocked in TakeStream forever.
+func (sm *StreamPool) recordBatch(s Stream, err error) {
+	if sm.parent != nil {
+		sm.parent.recordBatch(s, err)
+		return
+	}
+	// The pool the streams came from needs to know about failing streams
+	// before a round session gives them back
+	if sm.origin != nil {
+		sm.origin.recordBatch(s, err)
+	}
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	if sm.batches != nil {
+		sm.batches[s.s]++
+	}
+	// A batch whose results a hook rejected still ran fine on the stream
+	if err == nil || isRejected(err) {
+		sm.failures[s.s] = 0
+		return
+	}
+	sm.failures[s.s]++
+	if sm.failures[s.s] >= maxConsecutiveFailures && !sm.disabled[s.s] &&
+		len(sm.disabled) < len(sm.streams)-1 {
+		sm.disabled[s.s] = true
+		jww.ERROR.Printf("Stream failed %v batches in a row and will no "+
+			"longer be scheduled. %v of %v streams are still in use. Last "+
+			"error: %v", sm.failures[s.s], len(sm.streams)-len(sm.disabled),
+			len(sm.streams), err)
+	}
+}
+
+// isClosed returns whether a round session's pool has given its streams back
+func (sm *StreamPool) isClosed() bool {
+	sm.waitLock.Lock()
+	defer sm.waitLock.Unlock()
+	return sm.closed
+}
+
+// inRotation returns whether a returned stream should go back into
+// rotation. Streams from before a reload are dropped, and disabled streams
+// are noted as retired, so Reload knows nothing is running on them.
+func (sm *StreamPool) inRotation(s Stream) bool {
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	if s.generation != sm.generation {
+		return false
+	}
+	if !sm.disabled[s.s] {
+		return true
+	}
+	if sm.retired != nil {
+		sm.retired[s.s] = true
+		select {
+		case sm.retire <- struct{}{}:
+		default:
+		}
+	}
+	return false
+}
+
+// DisabledStreams returns the number of streams that have been taken out of
+// rotation for failing too many batches in a row
+func (sm *StreamPool) DisabledStreams() int {
+	if sm.parent != nil {
+		return sm.parent.DisabledStreams()
+	}
+	sm.healthLock.Lock()
+	defer sm.healthLock.Unlock()
+	return len(sm.disabled)
+}
@@ -9,7 +9,13 @@
 
 package gpumaths
 
-import "testing"
+import (
+	"errors"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+	"time"
+	"unsafe"
+)
 
 func TestMaxSlots(t *testing.T) {
 	env := gpumaths4096{}
@@ -21,3 +27,612 @@ func TestMaxSlots(t *testing.T) {
 		t.Errorf("The same memory should be able to hold about 2x powm odd slots as elgamal slots, but the actual mem size capacity ratio was %v off from that", offOfHalf/2)
 	}
 }
+
+// Makes a pool of streams that can't run anything, for testing the pool's
+// bookkeeping without the GPU
+func newDummyPool(numStreams int) *StreamPool {
+	pool := &StreamPool{
+		streamChan:     make(chan Stream, numStreams),
+		failures:       make(map[unsafe.Pointer]int, numStreams),
+		disabled:       make(map[unsafe.Pointer]bool, numStreams),
+		retired:        make(map[unsafe.Pointer]bool, numStreams),
+		retire:         make(chan struct{}, 1),
+		throughput:     newThroughputs(),
+		governor:       newGovernor(),
+		estimates:      newEstimator(),
+		deadlineMisses: new(uint64),
+		cancelled:      newCancelledTags(),
+		returned:       make(map[unsafe.Pointer]time.Time),
+		batches:        make(map[unsafe.Pointer]uint64),
+	}
+	for i := 0; i < numStreams; i++ {
+		cpuData := make([]byte, 64)
+		pool.streams = append(pool.streams, Stream{
+			s:       unsafe.Pointer(&cpuData[0]),
+			cpuData: cpuData,
+		})
+		pool.streamChan <- pool.streams[i]
+	}
+	return pool
+}
+
+// Makes a dummy pool that has no room for any slots and has measured the
+// CPU as far faster than the GPU, so ops send every slot to the CPU
+func newCPUOnlyPool(ops ...string) *StreamPool {
+	pool := newDummyPool(1)
+	for _, op := range ops {
+		pool.throughput.cpu[op] = 1
+		pool.throughput.gpu[op] = 1e-30
+	}
+	return pool
+}
+
+// A stream that keeps failing should be taken out of rotation, but the last
+// stream should stay in the pool
+func TestStreamPool_DisableFailingStream(t *testing.T) {
+	pool := newDummyPool(2)
+	fail := errors.New("invalid argument")
+	first := pool.TakeStream()
+	for i := 0; i < maxConsecutiveFailures; i++ {
+		pool.recordBatch(first, fail)
+	}
+	pool.ReturnStream(first)
+	if pool.DisabledStreams() != 1 {
+		t.Fatalf("Expected 1 disabled stream, got %v", pool.DisabledStreams())
+	}
+	if len(pool.streamChan) != 1 {
+		t.Errorf("Disabled stream shouldn't have been returned to the pool")
+	}
+
+	second := pool.TakeStream()
+	for i := 0; i < maxConsecutiveFailures*2; i++ {
+		pool.recordBatch(second, fail)
+	}
+	pool.ReturnStream(second)
+	if pool.DisabledStreams() != 1 {
+		t.Errorf("Last stream shouldn't have been disabled")
+	}
+}
+
+// Once the last stream in rotation keeps failing too, the GPU path should
+// no longer be live
+func TestCheckHealth_EveryStreamFailing(t *testing.T) {
+	resetHealth()
+	defer resetHealth()
+	pool := newDummyPool(2)
+	initialized.Lock()
+	initialized.pool = pool
+	initialized.Unlock()
+	defer func() {
+		initialized.Lock()
+		initialized.pool = nil
+		initialized.Unlock()
+	}()
+
+	fail := errors.New("invalid argument")
+	for _, s := range pool.streams {
+		for i := 0; i < maxConsecutiveFailures-1; i++ {
+			pool.recordBatch(s, fail)
+		}
+	}
+	if h := CheckHealth(); !h.Live {
+		t.Errorf("Expected live before any stream failed enough, got %+v", h)
+	}
+	for _, s := range pool.streams {
+		pool.recordBatch(s, fail)
+	}
+	if pool.DisabledStreams() != 1 {
+		t.Fatalf("Expected 1 disabled stream, got %v", pool.DisabledStreams())
+	}
+	if h := CheckHealth(); h.Live {
+		t.Errorf("Expected not live with every stream failing, got %+v", h)
+	}
+}
+
+// Results that a hook rejected shouldn't count against the stream
+func TestStreamPool_RejectedResults(t *testing.T) {
+	pool := newDummyPool(2)
+	stream := pool.TakeStream()
+	rejected := ErrRejected{Kernel: "mul2",
+		Err: errors.New("result out of range")}
+	for i := 0; i < maxConsecutiveFailures; i++ {
+		pool.recordBatch(stream, rejected)
+	}
+	if pool.DisabledStreams() != 0 {
+		t.Error("Stream was disabled for results a hook rejected")
+	}
+}
+
+// A successful batch should reset the stream's failure count
+func TestStreamPool_SuccessResetsFailures(t *testing.T) {
+	pool := newDummyPool(2)
+	stream := pool.TakeStream()
+	for i := 0; i < maxConsecutiveFailures*2; i++ {
+		if i%2 == 0 {
+			pool.recordBatch(stream, errors.New("invalid argument"))
+		} else {
+			pool.recordBatch(stream, nil)
+		}
+	}
+	if pool.DisabledStreams() != 0 {
+		t.Error("Stream without enough consecutive failures was disabled")
+	}
+}
+
+// Transient errors should be retried, and the retries counted
+func TestStreamPool_RunWithRetry(t *testing.T) {
+	pool := newDummyPool(2)
+	stream := pool.TakeStream()
+	attempts := 0
+	var ranOn []unsafe.Pointer
+	stream, err := pool.runWithRetry(stream, func(s Stream) chan error {
+		attempts++
+		ranOn = append(ranOn, s.s)
+		result := make(chan error, 1)
+		if attempts == 1 {
+			result <- errors.New("uncorrectable ECC error encountered")
+		} else {
+			result <- nil
+		}
+		return result
+	})
+	pool.ReturnStream(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("Batch should have run twice, but ran %v times", attempts)
+	}
+	if pool.Retries() != 1 {
+		t.Errorf("Expected 1 retry, got %v", pool.Retries())
+	}
+	if len(ranOn) == 2 && ranOn[0] == ranOn[1] {
+		t.Error("Batch was retried on the stream it failed on")
+	}
+}
+
+// A retry should wait for another stream rather than take the one that
+// failed, but use the failed one if it's the only one left
+func TestStreamPool_TakeForRetry(t *testing.T) {
+	pool := newDummyPool(2)
+	failed := pool.TakeStream()
+	other := pool.TakeStream()
+	pool.ReturnStream(failed)
+	got := make(chan Stream, 1)
+	go func() { got <- pool.takeForRetry(failed) }()
+	select {
+	case s := <-got:
+		t.Fatalf("Retry took stream %v while the other was busy", s.s)
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.ReturnStream(other)
+	select {
+	case s := <-got:
+		if s.s != other.s {
+			t.Error("Retry took the stream that failed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Retry didn't get the other stream once it was returned")
+	}
+
+	// If the other stream is disabled while the retry waits for it, the
+	// one that failed is all that's left
+	go func() { got <- pool.takeForRetry(failed) }()
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < maxConsecutiveFailures; i++ {
+		pool.recordBatch(other, errors.New("invalid argument"))
+	}
+	pool.ReturnStream(other)
+	select {
+	case s := <-got:
+		if s.s != failed.s {
+			t.Error("Retry should have taken the last stream in rotation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Retry waited for a stream that was disabled")
+	}
+
+	single := newDummyPool(1)
+	only := single.TakeStream()
+	single.ReturnStream(only)
+	if s := single.takeForRetry(only); s.s != only.s {
+		t.Error("Retry in a pool of one should use the only stream")
+	}
+}
+
+// The ops take their stream in the GPU's share, so the CPU's share has to
+// get going while the GPU's is still waiting for one
+func TestStreamPool_RunHybridWhileWaiting(t *testing.T) {
+	pool := newDummyPool(1)
+	held := pool.TakeStream()
+	cpuStarted := make(chan struct{})
+	go func() {
+		<-cpuStarted
+		pool.ReturnStream(held)
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.runHybrid("Mul2Chunk", 8, 8, true,
+			func(start, end uint32) error {
+				pool.ReturnStream(pool.TakeStream())
+				return nil
+			}, func(start, end uint32) {
+				close(cpuStarted)
+			})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("CPU share didn't run while the GPU share waited")
+	}
+}
+
+// Chunks smaller than the op's crossover should run on the CPU alone, and
+// bigger ones on the GPU
+func TestStreamPool_RunHybridCrossover(t *testing.T) {
+	pool := newDummyPool(1)
+	pool.SetCrossover("Mul2Chunk", 16)
+	for _, numSlots := range []uint32{8, 16} {
+		var gpuSlots, cpuSlots uint32
+		err := pool.runHybrid("Mul2Chunk", numSlots, 16, false,
+			func(start, end uint32) error {
+				gpuSlots = end - start
+				return nil
+			}, func(start, end uint32) {
+				cpuSlots = end - start
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		onCPU := numSlots < 16
+		if onCPU && (gpuSlots != 0 || cpuSlots != numSlots) ||
+			!onCPU && (gpuSlots != numSlots || cpuSlots != 0) {
+			t.Errorf("%v slots: %v ran on the GPU and %v on the CPU",
+				numSlots, gpuSlots, cpuSlots)
+		}
+	}
+}
+
+// Warming up should run every kernel and leave all the streams in the pool
+func TestStreamPool_WarmUp(t *testing.T) {
+	streamPool, err := NewStreamPool(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = streamPool.WarmUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamPool.streamChan) != 2 {
+		t.Errorf("Expected 2 streams back in the pool, got %v",
+			len(streamPool.streamChan))
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reload should wait for a disabled stream that's still in use before it
+// destroys the streams, and a stream from before the reload shouldn't go
+// back into rotation
+func TestStreamPool_HoldAll(t *testing.T) {
+	pool := newDummyPool(2)
+	held := pool.TakeStream()
+	fail := errors.New("invalid argument")
+	for i := 0; i < maxConsecutiveFailures; i++ {
+		pool.recordBatch(held, fail)
+	}
+	if pool.DisabledStreams() != 1 {
+		t.Fatal("Stream should have been disabled")
+	}
+	done := make(chan struct{})
+	go func() {
+		pool.holdAll()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Streams were all held while a disabled one was in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.ReturnStream(held)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Returning the disabled stream didn't finish holding")
+	}
+
+	pool.generation++
+	pool.ReturnStream(pool.streams[0])
+	if len(pool.streamChan) != 0 {
+		t.Error("Stream from before the reload went back into rotation")
+	}
+}
+
+// A returned stream should go to Reload rather than to a caller waiting for
+// one, or Reload would never get hold of every stream under load
+func TestStreamPool_HoldAllWithWaiters(t *testing.T) {
+	pool := newDummyPool(1)
+	held := pool.TakeStream()
+	waiter := make(chan Stream, 1)
+	go func() { waiter <- pool.TakeStream() }()
+	for pool.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan struct{})
+	go func() {
+		pool.holdAll()
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.ReturnStream(held)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Returned stream went to a waiter instead of Reload")
+	}
+	select {
+	case <-waiter:
+		t.Error("Waiter got a stream while every stream was held")
+	default:
+	}
+
+	pool.waitLock.Lock()
+	pool.holding = false
+	pool.waitLock.Unlock()
+	pool.ReturnStream(held)
+	select {
+	case <-waiter:
+	case <-time.After(time.Second):
+		t.Fatal("Waiter didn't get a stream once holding was over")
+	}
+}
+
+// Scrubbing a stream's device memory runs a batch of zeros through it,
+// without it being seen as work, and fails on a stream no kernel fits in
+func TestStream_ScrubDevice(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(chan Job, 1)
+	defer OnEnqueue(func(j Job) {
+		select {
+		case seen <- j:
+		default:
+		}
+	})()
+	stream := streamPool.TakeStream()
+	err = stream.scrubDevice()
+	streamPool.ReturnStream(stream)
+	if err != nil {
+		t.Error(err)
+	}
+	select {
+	case j := <-seen:
+		t.Errorf("Scrub was seen as a batch: %+v", j)
+	default:
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiny := Stream{cpuData: make([]byte, 64), cpuDataWords: make(large.Bits, 8)}
+	if tiny.scrubDevice() == nil {
+		t.Error("Scrubbing a stream that no kernel fits in should fail")
+	}
+}
+
+// When callers are waiting, the one with the earliest deadline should get
+// the next stream, and callers without a deadline should go last
+func TestStreamPool_TakeStreamBy(t *testing.T) {
+	pool := newDummyPool(1)
+	stream := pool.TakeStream()
+	got := make(chan string, 3)
+	now := time.Now()
+	callers := []struct {
+		name     string
+		deadline time.Time
+	}{
+		{"none", time.Time{}},
+		{"late", now.Add(2 * time.Second)},
+		{"early", now.Add(time.Second)},
+	}
+	for i, c := range callers {
+		c := c
+		go func() {
+			s := pool.TakeStreamBy(c.deadline)
+			got <- c.name
+			pool.ReturnStream(s)
+		}()
+		// Wait for the caller to start waiting
+		for {
+			pool.waitLock.Lock()
+			n := pool.waiting.Len()
+			pool.waitLock.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	pool.ReturnStream(stream)
+	for _, expected := range []string{"early", "late", "none"} {
+		if name := <-got; name != expected {
+			t.Errorf("Expected %v to get the stream, but %v did", expected, name)
+		}
+	}
+}
+
+// Batches that finish after the pool's deadline should be counted
+func TestStreamPool_DeadlineMisses(t *testing.T) {
+	pool := newDummyPool(1)
+	batch := func(s Stream) chan error {
+		result := make(chan error, 1)
+		result <- nil
+		return result
+	}
+	stream, _ := pool.runWithRetry(pool.TakeStream(), batch)
+	pool.ReturnStream(stream)
+	if pool.DeadlineMisses() != 0 {
+		t.Error("Batch without a deadline shouldn't miss it")
+	}
+	pool.deadline = time.Now().Add(-time.Second)
+	stream, _ = pool.runWithRetry(pool.TakeStream(), batch)
+	pool.ReturnStream(stream)
+	if pool.DeadlineMisses() != 1 {
+		t.Errorf("Expected 1 deadline miss, got %v", pool.DeadlineMisses())
+	}
+}
+
+// Scrubbing should zero everything a batch used, and nothing after it
+func TestStream_Scrub(t *testing.T) {
+	env := &gpumathsEnv2048
+	used := env.streamSizeContaining(2, kernelMul2) / 8
+	stream := Stream{cpuDataWords: make(large.Bits, used+1)}
+	for i := range stream.cpuDataWords {
+		stream.cpuDataWords[i] = 1
+	}
+	stream.scrub(env, kernelMul2, 2)
+	for i := 0; i < used; i++ {
+		if stream.cpuDataWords[i] != 0 {
+			t.Fatalf("Word %v wasn't scrubbed", i)
+		}
+	}
+	if stream.cpuDataWords[used] != 1 {
+		t.Error("Word past the end of the batch was scrubbed")
+	}
+}
+
+// Only streams that have been idle long enough and were used since they
+// were last scrubbed should be taken for scrubbing
+func TestStreamPool_TakeIdle(t *testing.T) {
+	pool := newDummyPool(3)
+	pool.idleSince = make(map[unsafe.Pointer]time.Time)
+	// Stream 0 is clean, 1 was returned long ago, and 2 was just returned
+	pool.TakeStream()
+	pool.TakeStream()
+	pool.TakeStream()
+	pool.ReturnStream(pool.streams[1])
+	pool.ReturnStream(pool.streams[2])
+	pool.returnStream(pool.streams[0], false)
+	pool.idleSince[pool.streams[1].s] = time.Now().Add(-time.Minute)
+
+	idle := pool.takeIdle(time.Second)
+	if len(idle) != 1 || idle[0].s != pool.streams[1].s {
+		t.Fatalf("Expected only stream 1 to be idle, got %v", idle)
+	}
+	if len(pool.streamChan) != 2 {
+		t.Errorf("The other streams should still be available, but %v are",
+			len(pool.streamChan))
+	}
+	// Once scrubbed, it shouldn't be taken again until it's used
+	pool.returnStream(idle[0], false)
+	if idle := pool.takeIdle(0); len(idle) != 1 ||
+		idle[0].s != pool.streams[2].s {
+		t.Errorf("Expected only stream 2 to need scrubbing, got %v", idle)
+	}
+}
+
+// The policy should decide which of the free streams is taken, and the
+// statuses should show which ones are busy
+func TestStreamPool_SelectionPolicy(t *testing.T) {
+	pool := newDummyPool(3)
+	for i := 0; i < 3; i++ {
+		pool.TakeStream()
+	}
+	for _, s := range pool.streams {
+		pool.ReturnStream(s)
+	}
+	pool.recordBatch(pool.streams[0], nil)
+	pool.recordBatch(pool.streams[0], nil)
+	pool.recordBatch(pool.streams[2], nil)
+
+	pool.SetSelectionPolicy(MostRecent)
+	if s := pool.TakeStream(); s.s != pool.streams[2].s {
+		t.Error("MostRecent should take the last stream returned")
+	}
+	pool.SetSelectionPolicy(LeastUsed)
+	if s := pool.TakeStream(); s.s != pool.streams[1].s {
+		t.Error("LeastUsed should take the stream with the fewest batches")
+	}
+
+	statuses := pool.StreamStatuses()
+	expected := []StreamStatus{
+		{Busy: false, Batches: 2},
+		{Busy: true, Batches: 0},
+		{Busy: true, Batches: 1},
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Errorf("Stream %v: expected %+v, got %+v", i, expected[i],
+				statuses[i])
+		}
+	}
+	if pool.Waiting() != 0 {
+		t.Errorf("Nobody should be waiting, but %v are", pool.Waiting())
+	}
+}
+
+// Streams should carry the tags of the pool they were taken from, whether
+// they were free or waited for
+func TestStreamPool_SetTags(t *testing.T) {
+	pool := newDummyPool(1)
+	pool.SetTags("round 7", "realtime decrypt")
+	s := pool.TakeStream()
+	if s.tags != "round 7, realtime decrypt" {
+		t.Errorf("Expected the pool's tags, got %v", s.tags)
+	}
+	taken := make(chan Stream)
+	go func() { taken <- pool.TakeStream() }()
+	for pool.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.SetTags("round 8")
+	pool.ReturnStream(s)
+	s = <-taken
+	if s.tags != "round 7, realtime decrypt" {
+		t.Errorf("A waiter should get the tags from when it asked, got %v",
+			s.tags)
+	}
+	pool.ReturnStream(s)
+	if s := pool.TakeStream(); s.tags != "round 8" {
+		t.Errorf("Expected the new tags, got %v", s.tags)
+	}
+}
+
+// Batches with a cancelled tag shouldn't run, and a batch that was running
+// when its tag was cancelled should be abandoned
+func TestStreamPool_CancelTag(t *testing.T) {
+	pool := newDummyPool(1)
+	pool.SetTags("round 7", "precomp")
+	stream := pool.TakeStream()
+	ran := 0
+	batch := func(s Stream) chan error {
+		ran++
+		pool.CancelTag("round 7")
+		result := make(chan error, 1)
+		result <- nil
+		return result
+	}
+	stream, err := pool.runWithRetry(stream, batch)
+	if err != ErrCancelled || ran != 1 {
+		t.Errorf("Running batch should have been abandoned, got %v", err)
+	}
+	stream, err = pool.runWithRetry(stream, batch)
+	if err != ErrCancelled || ran != 1 {
+		t.Errorf("Cancelled batch shouldn't have run, got %v", err)
+	}
+	pool.ReturnStream(stream)
+	pool.SetTags("round 8")
+	stream = pool.TakeStream()
+	_, err = pool.runWithRetry(stream, batch)
+	if err != nil || ran != 2 {
+		t.Errorf("Other rounds' batches should still run, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"sync/atomic"
+)
+
+// strict.go decides what happens when the package finds a bug in itself or
+// in the library, like a kernel the library has no sizes for, or a panic
+// while a batch is packed. Normally the op that ran into it returns an
+// error, so one bad batch can't take down the server. Tests can turn on
+// strict mode to panic instead, and stop right where it happened.
+
+// Set while strict mode is on
+var strictMode uint32
+
+// SetStrict turns strict mode on or off
+func SetStrict(strict bool) {
+	value := uint32(0)
+	if strict {
+		value = 1
+	}
+	atomic.StoreUint32(&strictMode, value)
+}
+
+// broken logs err, which should never happen unless there's a bug, and
+// returns it. In strict mode it panics with err instead.
+func broken(err error) error {
+	if atomic.LoadUint32(&strictMode) == 1 {
+		panic(err)
+	}
+	jww.ERROR.Print(err)
+	return err
+}
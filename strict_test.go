@@ -0,0 +1,32 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"errors"
+	"testing"
+)
+
+// broken should return its error normally, and only panic in strict mode
+func TestBroken(t *testing.T) {
+	bug := errors.New("bug")
+	if err := broken(bug); err != bug {
+		t.Errorf("Expected the error back, got %v", err)
+	}
+
+	SetStrict(true)
+	defer SetStrict(false)
+	defer func() {
+		if r := recover(); r != bug {
+			t.Errorf("Expected a panic with the error in strict mode, got %v",
+				r)
+		}
+	}()
+	broken(bug)
+	t.Error("broken returned in strict mode")
+}
@@ -0,0 +1,167 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package testvectors
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"os"
+)
+
+// corpus.go reads and adds to the corpus of operands that have given wrong
+// answers before, or that sit on an edge the kernels have to get right:
+// operands equal to p-1, values with leading zero limbs, and products whose
+// carries run the whole length of the bignum. Every backend's tests run the
+// whole corpus, so a bug that was fixed once stays fixed. The corpus is kept
+// in testdata/corpus.jsonl, one case per line, and new cases from production
+// can be added with AppendCase or the corpus command.
+
+// CorpusFile is where the corpus is kept, relative to this package
+const CorpusFile = "testdata/corpus.jsonl"
+
+// Case is one entry in the corpus. Operands are in hex, in the order the op
+// takes them: base and exponent for Exp, and the factors for Mul2 and Mul3.
+type Case struct {
+	Name     string
+	Op       string
+	Bits     int
+	Operands []string
+	// Where the case came from, or what it tests
+	Note string `json:",omitempty"`
+}
+
+// How many operands each op in the corpus takes
+var caseOperands = map[string]int{
+	"Exp":  2,
+	"Mul2": 2,
+	"Mul3": 3,
+}
+
+// Load returns the case's operands and expected result as one slot buffers
+// in its group. The result is worked out with plain big integer arithmetic,
+// rather than the group code, so that it also checks the CPU backend.
+func (c Case) Load() (Vectors, error) {
+	numOperands, ok := caseOperands[c.Op]
+	if !ok {
+		return Vectors{}, fmt.Errorf("case %v: unknown op %v", c.Name, c.Op)
+	}
+	if len(c.Operands) != numOperands {
+		return Vectors{}, fmt.Errorf("case %v: %v takes %v operands, not %v",
+			c.Name, c.Op, numOperands, len(c.Operands))
+	}
+	if _, ok := primes[c.Bits]; !ok {
+		return Vectors{}, fmt.Errorf("case %v: no %v bit group", c.Name,
+			c.Bits)
+	}
+	g := RFC3526Group(c.Bits)
+	values := make([]*large.Int, numOperands)
+	for i, hex := range c.Operands {
+		values[i] = large.NewIntFromString(hex, 16)
+		if values[i] == nil || values[i].Cmp(g.GetP()) >= 0 ||
+			values[i].Cmp(large.NewInt(0)) < 0 {
+			return Vectors{}, fmt.Errorf("case %v: operand %v isn't a "+
+				"value mod p", c.Name, i)
+		}
+	}
+
+	expected := large.NewInt(1)
+	if c.Op == "Exp" {
+		expected.Exp(values[0], values[1], g.GetP())
+	} else {
+		for _, v := range values {
+			expected.Mul(expected, v)
+			expected.Mod(expected, g.GetP())
+		}
+	}
+	v := Vectors{
+		Group:    g,
+		X:        g.NewIntBuffer(1, g.NewIntFromLargeInt(values[0])),
+		Y:        g.NewIntBuffer(1, g.NewIntFromLargeInt(values[1])),
+		Expected: g.NewIntBuffer(1, g.NewIntFromLargeInt(expected)),
+	}
+	if numOperands == 3 {
+		v.Z = g.NewIntBuffer(1, g.NewIntFromLargeInt(values[2]))
+	}
+	return v, nil
+}
+
+// LoadCorpus reads every case from a corpus file
+func LoadCorpus(path string) ([]Case, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cases []Case
+	scanner := bufio.NewScanner(f)
+	// 4096 bit operands are over a kilobyte of hex each
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var c Case
+		err = json.Unmarshal(scanner.Bytes(), &c)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
+
+// AppendCase adds c to the end of a corpus file, creating it if it doesn't
+// exist. It returns an error without changing the file if c isn't valid, or
+// if the corpus already has a case with the same name.
+func AppendCase(path string, c Case) error {
+	if c.Name == "" {
+		return fmt.Errorf("corpus cases need a name")
+	}
+	_, err := c.Load()
+	if err != nil {
+		return err
+	}
+	cases, err := LoadCorpus(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, existing := range cases {
+		if existing.Name == c.Name {
+			return fmt.Errorf("corpus already has a case named %v", c.Name)
+		}
+	}
+
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// CaseFromSlot makes a case from one slot of a batch's operands, like the
+// ones in a dumped batch frame
+func CaseFromSlot(name, op string, bits int, operands []*cyclic.IntBuffer,
+	slot uint32) Case {
+	c := Case{Name: name, Op: op, Bits: bits}
+	for _, o := range operands {
+		c.Operands = append(c.Operands, o.Get(slot).TextVerbose(16, 0))
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package testvectors
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Every case in the corpus should load, and the group code should agree
+// with the expected result
+func TestCorpus(t *testing.T) {
+	cases, err := LoadCorpus(CorpusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("Corpus is empty")
+	}
+	names := make(map[string]bool)
+	for _, c := range cases {
+		if names[c.Name] {
+			t.Errorf("Corpus has more than one case named %v", c.Name)
+		}
+		names[c.Name] = true
+		v, err := c.Load()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		g := v.Group
+		z := g.NewInt(1)
+		switch c.Op {
+		case "Exp":
+			g.Exp(v.X.Get(0), v.Y.Get(0), z)
+		case "Mul2":
+			g.Mul(v.X.Get(0), v.Y.Get(0), z)
+		case "Mul3":
+			g.Mul(v.X.Get(0), v.Y.Get(0), z)
+			g.Mul(z, v.Z.Get(0), z)
+		}
+		if z.Cmp(v.Expected.Get(0)) != 0 {
+			t.Errorf("%v: got %v", c.Name, z.Text(16))
+		}
+	}
+}
+
+// Appended cases should load back, and bad or repeated ones should be
+// refused
+func TestAppendCase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "corpus.jsonl")
+
+	g := RFC3526Group(2048)
+	x := g.NewIntBuffer(2, g.NewInt(5))
+	y := g.NewIntBuffer(2, g.NewInt(7))
+	c := CaseFromSlot("dump", "Mul2", 2048, []*cyclic.IntBuffer{x, y}, 1)
+	err = AppendCase(path, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AppendCase(path, c)
+	if err == nil {
+		t.Error("Appending a case with a name that's taken should fail")
+	}
+	c.Name = "bad"
+	c.Op = "Mul3"
+	err = AppendCase(path, c)
+	if err == nil {
+		t.Error("Appending a case with too few operands should fail")
+	}
+
+	cases, err := LoadCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 1 || cases[0].Name != "dump" {
+		t.Fatalf("Expected just the first case, got %+v", cases)
+	}
+	v, err := cases[0].Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Expected.Get(0).Cmp(g.NewInt(35)) != 0 {
+		t.Errorf("Expected 35, got %v", v.Expected.Get(0).Text(10))
+	}
+}
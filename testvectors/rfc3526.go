@@ -0,0 +1,476 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package testvectors
+
+import (
+	"fmt"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// rfc3526.go has known answers for exponentiation in the MODP groups from
+// RFC 3526, which are the groups the servers run with. Unlike the seeded
+// vectors, these were computed once outside this repository and are fixed,
+// so a backend that agrees with itself but not with anyone else still fails.
+
+// KnownAnswer is one exponentiation with a fixed result. Values are in hex.
+type KnownAnswer struct {
+	Name string
+	// Which RFC 3526 group the values are in
+	Bits     int
+	X        string
+	Y        string
+	Expected string
+}
+
+// RFC3526Group returns the MODP group from RFC 3526 with a prime of the given
+// length, which must be 2048, 3072 or 4096. The generator is 2.
+func RFC3526Group(bits int) *cyclic.Group {
+	prime, ok := primes[bits]
+	if !ok {
+		panic(fmt.Sprintf("no %v bit group in RFC 3526 known answers", bits))
+	}
+	return cyclic.NewGroup(large.NewIntFromString(prime, 16), large.NewInt(2))
+}
+
+// Load returns the operands and expected result of the known answer in its
+// group, as one slot buffers
+func (ka KnownAnswer) Load() Vectors {
+	g := RFC3526Group(ka.Bits)
+	return Vectors{
+		Group:    g,
+		X:        g.NewIntBuffer(1, g.NewIntFromString(ka.X, 16)),
+		Y:        g.NewIntBuffer(1, g.NewIntFromString(ka.Y, 16)),
+		Expected: g.NewIntBuffer(1, g.NewIntFromString(ka.Expected, 16)),
+	}
+}
+
+// The primes of the MODP groups, in hex
+var primes = map[int]string{
+	2048: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF",
+	3072: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF",
+	4096: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+		"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+		"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+		"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+		"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF",
+}
+
+// KnownAnswers has a few exponentiations in each of the RFC 3526 groups
+var KnownAnswers = []KnownAnswer{
+	{
+		Name: "g to a 256 bit exponent",
+		Bits: 2048,
+		X:    "2",
+		Y:    "8F6A2463D7199BAFA5A713FD57C993F6C73E878A6353ADD2C28651B06AD7F35D",
+		Expected: "FAB6AE9194553838E2CE6674CCB92C0770AEC16C440A3E1E638625A255020018" +
+			"A1B332CC9D6E125AEE22FCE192F0BA9CC25F59322EEEF3D850B9E4C6C6FBC003" +
+			"C5BAD91C59421B454C0E0F33B4653332AE813125DA067628AEA2E8A0CB5C0D55" +
+			"D93F8FA2BD5180D12FEBD98B3A5B2D29838A4AF5DE9C7611D3024285BCD4FA2F" +
+			"37801708A6EFAAB8AD9319803E0ABA0C0BE026B1329EFBBAFC3FAE248E96E232" +
+			"7349BBC7B89749B6FF070FB785C07D2395F0E0164949D5D3057DA724B699925F" +
+			"CF961A8C7B33943D48A56431DCBF0BC77BEF9F38852433552C954A7390790244" +
+			"247DBD447FAEA151A7D533CE59C393037F54671D0047763518550AB7D4AE0192",
+	},
+	{
+		Name: "inverse of a full length element",
+		Bits: 2048,
+		X: "D6C81E3923A9D43D446F30C17FADFDB9A30DB788C5E468C27CABD9E0D117464F" +
+			"A515B8B77175F32A9C17E20608187ED1C9165A702B186A04F027AE4E3F72279E" +
+			"EDD5657FB42D50D098FE6E16EDD64B0C174C01CB2561CCB22035B7D75013FE07" +
+			"0DA7C753B234830F8602402014C916805DC9E5146ED953DC2598A550E1FC5EEE" +
+			"4C9EA3F4E546796B423E285FD84C8C7464B1ABEACFE6DBF421BDF672249BDF98" +
+			"40F08019C989608153BB2F713CADA00A0D0A76BCFF9B7B12688BE903F3AE4E29" +
+			"ED535798A75C46605A02FD49647662FA6FD27902599B3A9DFA88AC6BB348C809" +
+			"C96C344D741C77A2A543682800394A86DCDC20887BBF951A76FD719D086B4CCF",
+		Y: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFD",
+		Expected: "75EB00856F5718D042A880FC9042045F0FAB01B159C1832D3F78A8FB45337119" +
+			"90DE3911885B22E4C0E85B51FBE4C65B4AFDAC10ACDF48D8AED341D978573523" +
+			"D84FBDFB4961FBDC99413D886822A5E9666FA68277952A4F5BCE95FD08692A6E" +
+			"9785D74965F7493E8AF0A244FF553FCDDCC2152324BB6C9200912EF65B7F5E17" +
+			"CDB5C3200D2ADF0AFE28B3ABA18937D453060F1D18660DADCE3C56EE85E30A6B" +
+			"81B1F037377997BAB25C905497D4D89DD50E9E6383F5DA003C7CCAB8AA946661" +
+			"F96DC6E3C39C32D9B8A27C412D020A2831B9FAB937F3B318749DAA3E8245AF2A" +
+			"9D2EAC76C33CE83F4D6AC98E36FAB810E65F8AB512FF64AC87776332B97AE322",
+	},
+	{
+		Name: "full length element and exponent",
+		Bits: 2048,
+		X: "8D7D0C4CDAB5E08EF5B6DCBBA6D5A3747DC85E4FD696DABD34BEF1DC234E8AE3" +
+			"FDBFAD7511107A2543F4987796AB144CF20CF84E2D447BEAA95668EC4ACD240F" +
+			"4C0DF7E5EB826E778AF4F9A0406B3F7DD0DEB390605A2073B779ADC2DD4F3323" +
+			"F8EB44A259A92EDC67961DF4931918379D146F16B0A83CB42ECDF8609B33FFCC" +
+			"CD820BAD05DC45E7BF0C489E9222EE4BEBCC4CF88333E6CB1B3449B7B78CA99C" +
+			"1D063EDCC96B8D5CDA9E42870FC14A122575E8BE8182D6EAF764C1FA97BD5D10" +
+			"CA15FD16CF5444B1642096A727C0FB49A3D72555D96F687024E3166262D17BA0" +
+			"90214B0669176322D6968DA65A2B2A6016BB94EDB8F88599C7B44FCB4F754F1",
+		Y: "8AE0BF296A10F4A849913D47220E3BCACEAE17233BE82E0590DECA557CB6121A" +
+			"2D422048B367C15BE1937520B132A051F63AB6D292D5D3520E7B501E8DADB9E5" +
+			"BA14B9573280BA973A1B29BC9719AF6947D4F7D310EEA6229ED58BB869C757D6" +
+			"9E81928DE40A7A9A96EC438BB15466E4FCA73200A21196ECB82D1398E973A5BB" +
+			"4CC5EF5BCDA8F138F5131ABF9D10DDD5A25B8CC9DE084CCE1801F5F8F50F1009" +
+			"E4B6AA52B21EC05FE4C8B95DCC2630BC090A7C22A7582F4D74F64BA73CC21DB7" +
+			"2B3385E048C171FE931BF102BFAF5B7EDB2DD152A129C4825EB7D7F6C7165819" +
+			"119BE8BCCE19000CDC8678F744BA8A403D47C1C221C501DB7F5E0CB45546ACE0",
+		Expected: "D1EC0F5E64777A0A41CA8EEE990AC0DDC313BD318CA6F04C866A4D7F33609249" +
+			"8BAD9C63601647E26CBAD07E6123CE74A1F3E9A5777AA36A19114E23EFF9013E" +
+			"51B6657DDA03C06CF8B13F70C9EDFD3C97E9D79157C14049232D4EC4E542E776" +
+			"BB5873CE78C47F4C234D7DE99F42AEAA3A14ED8D0AC47CB8909B5E17DB9E5AD7" +
+			"6B7F98F8E54F4FC0D0DE44D8F4B1F97EE59845103BD8A0E6732F67C2D9D870AF" +
+			"F0567686060C4B7E92A62EA7A8441F6AF5C42CF0503D89AA8CD1FBDEEADB9E5C" +
+			"37492251FAA4D1FB05325E28BB95283254A9A7BFAF430D10FA51DD668C4DB820" +
+			"27266A025535E23ED65535923194EEF0827B832F8007F30A902DECB13E859947",
+	},
+	{
+		Name: "p-1 to an odd power",
+		Bits: 2048,
+		X: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFE",
+		Y: "3",
+		Expected: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFE",
+	},
+	{
+		Name: "g to a 256 bit exponent",
+		Bits: 3072,
+		X:    "2",
+		Y:    "F75F09196DB2250387EE0E3F4D92C2746C8DB4F405FBEF66D710FEF2C6ADCB53",
+		Expected: "272872BF0F742A2AD56D0586413D1DB3F100A9B6D1FB0F1CB00D3751ADFE465D" +
+			"F1D60E615DBF2248ABD56C202144CFF1177C30CB5E8F278A15FBB54805D1C653" +
+			"6A53F830A58A6B29AFF06218C32CD748DAEDD5E05DF07EA63976E3B58AAF7952" +
+			"D04C6C3DE798EC68F14BEC12CF943C449A2FF7F1BB148063E499FFBF54E8B92E" +
+			"3A3709199E7748F3F80DF55A725D09297168BB6D48EFCC2342100EC8989AF6CC" +
+			"D1EE3D9EDC42D24F9CDCF7B7767C7F4E29E3BE8196EDC96FF746B2D19B2DD200" +
+			"5340872F15426ED76E04C910295FECE9904F592F88787D0569BEC02C56E60CC6" +
+			"058BE72D56C6F66A0B7735EE70CD57773FE10D6DA5B5682A82DB5558EB645C0F" +
+			"A0FF684D261B0C99A4C637643F0FB5F7DBD6DCAC5D5B2E6D9BF339DF9A6E2E04" +
+			"EADB67BE6DF24F22F7C0F995D86428EAD0116E75DC80AFE96BD26390D9D8417D" +
+			"363830ECBEBEE96D8789C0DDCEAC6B9E7529B6F86334A0E98EF51AFD073059FA" +
+			"12EF008D0949C521B9E21B560649A8309686A4B08524DA0AE8DDADAF5B882FB4",
+	},
+	{
+		Name: "inverse of a full length element",
+		Bits: 3072,
+		X: "E2DEB9C8DF3FE8004A4AE7719A74E9CB2C81C30EF17D56CEEE577B638DF67812" +
+			"9DBDF2D2B6F7C4D41D0F01DE679B2BF55844296604E553FF47F75185B1FF086C" +
+			"0EEBBB8D8E7131B5A3C9729ACA70C934FBFC06FDB2C8DF2CDD09C6B2E85CB3E7" +
+			"F747CA8209517E75A31857A487A3F19BD7FF7FD367DC316C2A04F2C8A759CB24" +
+			"64F594FE3AB044CAC941AD1D7C231896D2419595F2204667F8279FA791CCA932" +
+			"F790073EA1F29696E1B2E76A8E78DB5DB4A20B074B648ECBCD88823EE3CFAA3A" +
+			"322E0025CDA1C6B833F40BBD53B0CF0F665C72842B2E11370930BB947886B898" +
+			"E41BF64F62C4AB1AEEEEA04CD878C778373396862422FB820AFABD1C2B2F2C1B" +
+			"2EED46646922E5F6B9577EE890F0068DD1AF97C0AEE19C836A4A39446E890DB5" +
+			"AD7C0CB8076F63FAB7C9158135EEBF80E87C71D927C81C79AFF0EFD28A19838B" +
+			"1BDB229CB3A66A7FF9FA07FE26852EDD79B2C20C1DDD947199F2D765052D579E" +
+			"EC9841C4D33578877FD6AFCD66E3F98C465743A8C0D1D611D7DC1B3F0D17ED91",
+		Y: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+			"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+			"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+			"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+			"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFD",
+		Expected: "6136EFE0128A1CA338306257A6535644470202A99604331F77B5C6C25493A945" +
+			"BBFEE1D1E064C3C3A4D6BAF1DD17A63F6D278FE4930B436DB7ACC0C5B86C80AA" +
+			"C5F3E41EB8F9978CA215952AC7D4FECF717A768F17B95FF07BF500323FD8EF30" +
+			"3275FE5FE98108C9FA1CC640B11DDC980CF03EA38DA1F113A9074091690ABDD9" +
+			"F348C211A4BFE0F624F57121A9F8F2AF7BC072404A9CBC0CCBDCEC743EBBEA0F" +
+			"CDF684F8E4E500845D6AD22E8B7AC780A25966EFEB710C8A0F219B064F3F8E36" +
+			"8EA9E4DE07F69494AD65A91C8BAF666933C831E1B40A85ABF7BB1A60D7AE556B" +
+			"5C757F4014C5306BCFB7FBE4788ABF4BAE69E4FAAC0C2043A50DC0DFF803245F" +
+			"539D42276B843425D4ABDEE14ABDED24B3248F1374378A5B4BACEE9D0D9ED72C" +
+			"024D097AEB3053173F318FE3B58ED5FE7F4399E6DBE3F0259E4DD53253E65850" +
+			"AE7DDF0CD962B20437F6DB9C4F42F3DEAC6D24EBDAE466E546943198517BFDB5" +
+			"81FBEB7C49163FC967D4A97F4C51477F77DC1E747E9B3D59F5808E7866FECD5E",
+	},
+	{
+		Name: "full length element and exponent",
+		Bits: 3072,
+		X: "369FEB0141441BA5BE33C754C464F6EAC57B47C910F4E20D305A370DCBC39A1F" +
+			"3CA3E921455391030DFB1C55EA360F208CF74B2491F129DE75A44DBD9107654F" +
+			"D3213DFBA4921F807B70926CA0C7CDEF6E9D0EA785E52753D6FE6D30DFC7DC9B" +
+			"8ADC589CD47FC3593AFB8F90A31576771A8D61C3EC917ED17D0D128590387B98" +
+			"319A9A9031DD68EB479376C1429564060976FBAFD8E7E404C84AFD648596FE95" +
+			"109602689C053C2DD51F8851B7D4DE424B6DDD6FDF0425AE50999E583F3CCA77" +
+			"D43365E0A522181E396DBF56C9D969D9CA5AA86C9098DD6165D5EAAF9202D16A" +
+			"FB3BB83A167C6C10C96770F922507C3465BB177E95A56ACAED17AD23358A8ABA" +
+			"3A4F48456F3D8693C5598CE4C7C505475252C5E4A8B1338A81497E7F015500A1" +
+			"092559286D04E8659172E90896B1D63F6D876E1C9E1A4C8AF1D8F45726DCE461" +
+			"4E279A5EAAEE3BA3FFFCAB96502D2A6BD7924E63B5857D8442D0C239D8A853E4" +
+			"FE9CFF06FCBB6AEB01294964C0B0CC5B00E7F429CEB796F67ED0ED2841B3270F",
+		Y: "191CDD1CC4DBE78CE2E0A66D2F4FD01AA9A4A26E2EB46671D75F1FFE6D046700" +
+			"F9FF7837EA31CFADFDD4633B2519DF682F8AC8A9A96666F89ADF28E2994893B0" +
+			"B27F9C2AF6750B9A82B0CE9E90284283A11BD141CB160A4292E46F59964DBC6B" +
+			"2CAD4DE1775C5AFB2B248082A1F86890F0F3CC11B710FD4750351E3F91D1AEF9" +
+			"EB278840E82B4C4DACFAD7F1E00543FF796380A90AC940923BC09823997DE041" +
+			"2FB22258DCC59878702E0D08ACA2424EA56B1F8B486DED2561E7B07EC7544B7D" +
+			"AB8E8E5019F5D692CBA1A76267B114F9FEBE99B9A38713AE7DEE2093DDC1590B" +
+			"6EA704E99BFFAB617595E5DA41018687D6C6A48BA5E867D57492B3794F2E43B4" +
+			"8724CDE783516B041DBBF70B5F58B7620FEF475656F2C252892534C1A387CCC1" +
+			"F21281C34AEE19858D88CB44A9C64ADCF96C69B834C2B7C934D60B535B8D6467" +
+			"2D999650DBF23C2D0C5AB877B7757838433B8FAB647E7A5D5BAB749DEF34E897" +
+			"BBFB18C073D0F995E04EFEDA375D68FC3D071AADF5D7D5C567CC3984492E2F4F",
+		Expected: "D5177913C7843F0CF1F84BC505EF63CB824BD8BA7DFFF4E0685B4D9721E48E70" +
+			"3A45E886B889605221604C0943A8695710A6B828DDA338C750DC1AE6CE28BDD3" +
+			"BFD710E4F0EC051AA0324744CB34CD8AE07D5C2897F2582F4BA96A7FE866C2F9" +
+			"1A4F06B0DB7D5E1BCEC7FCA7DE732FB0159C812E4FD3267C634A01E864278BF2" +
+			"B7175F6F69768C7004FC01EF23BF5B920AB91F21DA5584B729C8080DB50F5B33" +
+			"A8515533F64139086E21CA25E76A520DF92294AF3CE36F5AE5A3AF9437A995D8" +
+			"C5613EEFA7C11ACF00274936223C6F213A0B0BB110B6F5AB23150E1CE0DE5A17" +
+			"9FD9F6177E9F064DFAD1931A53AEE0E92C0E0DFA8A799DCA4257A12D4B67964E" +
+			"499C723994D9BEE4D6960E67093750C403F88C7FCF8F4062E1F36C9AE6698D42" +
+			"3E9508B35CDC1A0B01431353BD86EBA5CC0565548FECD3DC6E711F3E1128F655" +
+			"EBB069A156915FA476380C50CD514651E295FE3C954E87BBCA8D7005736BB69C" +
+			"745F93EF13DF671318E6F904E7A96775792408C6E13C2B996549A787249A2CCE",
+	},
+	{
+		Name: "p-1 to an odd power",
+		Bits: 3072,
+		X: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+			"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+			"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+			"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+			"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFE",
+		Y: "3",
+		Expected: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+			"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+			"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+			"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+			"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFE",
+	},
+	{
+		Name: "g to a 256 bit exponent",
+		Bits: 4096,
+		X:    "2",
+		Y:    "4A590AA16932C9154EF326798DCDABA93B076EAF4AD3896956DE96C0030CB359",
+		Expected: "E0D0B5CDD79AD59607029A48E06C087F36EF47692B4F9B6439E66D459FB29226" +
+			"85FF017C04EF7E3F3F2EDE78D1D77F7A855123BA28B06AD1DED2198D6D705ECF" +
+			"A5B94471213FD2C82E3D3EFBE589E3BD0CFD3AE8BDFC98C169BCFD7C56F5D55E" +
+			"D6AF73628D1BB8F180F67C04B5171FCED7170547EFCC5CA183E55F2BA54D1DA5" +
+			"5808F60E19D41DBBF3284752FFA64B29CCBDA911731BB1479A9361672E932002" +
+			"17547DDA331ECD4E391C403797B74B2C02C8C8ABEA633864DDD9C10284352E00" +
+			"AC70D572564F375358D114E477833E63340C54F940BEFBD8497112FC8D02330E" +
+			"0AE1755D9396E19B28CF43F938B49935107231B85C84BC28F37DA05F828A7E68" +
+			"C8742763B12D910EF3404072C612C6B887E6CB63FDF42627A752C9BF911E4083" +
+			"08D825344901CD1FD8445C7CE51944C9E4AA6216933F2E769C90F9380027800F" +
+			"946AC798C059148150546A9496607D80B5B0F26B5A2D18033A3F417E6E4D3AA7" +
+			"193A78F5A51293AACC3F1F82099AB912A8DCA5625378D29452AEC7AB8A2C8DA9" +
+			"3A8BD271778F9EA92585A3866228A6EC003DD6C0B4A278C27FB712241E56C7D1" +
+			"9975198B8CD700C019EBE9234AB24956E93CB6DE7C191C345224635ADDAC68B3" +
+			"9598F2D609223996BE9BD8B75F95E242C36D4E28A02758568E5E269C259136DE" +
+			"C79B66B37653A6B0D31B94722BED0E1B61EDC4EC02D86931180FEFE119188E5A",
+	},
+	{
+		Name: "inverse of a full length element",
+		Bits: 4096,
+		X: "7A4DED89CCE95FE7BE3ED933C3073DF61D97C0489C82AE1F12C6885A8D43B151" +
+			"B9FC9E3E03014DC5F599FC295FCB25E6005FF66DD8186FD4CCF16D767B192CC9" +
+			"0843574A21558CB351D4E1C1556283CECABEF5C0A3CB279170CFEFFEA65BA973" +
+			"D2CE9503B459B71F37CE0D2F2412B695129D72F06CAD930EA8FD2555FC04EB29" +
+			"819BEA247FD229DF4441BAAEE9152773A4283AAC3A1A26F426DADD127D8054AE" +
+			"C9395119D5DF0F8480C8B4D52C1E8CC7299AA832299A5757944D1831DEA825E3" +
+			"A111D7C5AF2CFB48C60D97C3BB5CF512F3ED180EA77EAA70E730AB2E2DB46281" +
+			"51BCFE3516EDD87AC4D107EBDAF5D4C64F0D55CDFCF2646AA035DE8FF74CC058" +
+			"A65DFDEDBA9F2EE52B26AAB65F669048CCD4DBC978D56BBBD0784AD42DBD260F" +
+			"BB377F15CD879746B8CEA5E82B43B1CEFB32B7F12F232DD77B1C507285E17F05" +
+			"DE85F5861C6B21CDDDB55E58A9F105780A47C2BBBE16B4BA6E9F4202CDD9C1A8" +
+			"C66532F07442B2535303D22020851BD8B1068DF134D57DF8956CB31797F01F07" +
+			"A7BAEAB161AB8479F35C567D32DBCC982C3D57C7ED4D4F9E5C8EEE7220D027B6" +
+			"9AF7B893C8C0617B81D886AEFF5F788D07479D346B909761B77E93E02D469CB0" +
+			"CA10E87687F5F0ABF2172DF641DFF9FC6C5E265F1066424E79D4692B0D4A02B8" +
+			"0C75CB87FEC6A1362AE15CA6FD61137EE0E63A5783B84DE740E3B7F50A26EAF0",
+		Y: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+			"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+			"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+			"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+			"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+			"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+			"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+			"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+			"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFD",
+		Expected: "4B9A11B8AF7A5FC4ED0E61D6B21BEFC534AAA49072348B3C18C82263E395EE03" +
+			"CFF8C5939A5943548D4EE3F698A5B7260FEAAF296D795CD841654263252E5913" +
+			"AEB2E5CF2A735295632BD6A9564885790AE35C9331AF4A3CF16E7402C93A0A0D" +
+			"D96300C32D9BD372328FD092DBB8BA5DD93C09C170B96585D1DA1E2A4D230C22" +
+			"2E8CEA212E6ACFEB1A8623CF5885C20D6F7B015E8C09C3096F65F2742A4D18EC" +
+			"83B7323C454821561117C6723A49F1C6951A0531E7D44F5867355E86BB129ECD" +
+			"BD8D67ADBD0C2B0D56160B986A80CD000F0F5DE03E998E1FC88E664C594B2ED1" +
+			"04CEC347D3A22219B9E90D09BCB4442BD3D64C040A10876803375A184D3646AB" +
+			"D13CBB1D2091F2F90A1A1D582C5939959727581C0D045055D0852DB6E0367A7C" +
+			"5D73A671B95D34F0B1976D714CDC546F5283A7427B52E36AB8EED4A863AE2F19" +
+			"1224C5FAC55133E4CF1387D005D17810FED12451F58753C5C22C0B600E0FE6A4" +
+			"DA1710A0646ED2435D5804BCD08228D28DB39DF1217B2269ECF8E52EB2E947B8" +
+			"BBA13742AD78F7FB39218160E3301263E67329403CFC6E5C1644E03A95809833" +
+			"56F63E8FCACBCE2F98665F8632548C13A5BEB4DC95B48FD2C464F85A4487395B" +
+			"77F1A4069D28FC96F6533B8518E2A3EB4660D6DC256328F592A8511C25DF88CC" +
+			"579BC1A2C1BA15CBA8385005D886FD6E83B914711A96CF9FC9EC76E4F11DC506",
+	},
+	{
+		Name: "full length element and exponent",
+		Bits: 4096,
+		X: "F202D73C80E76910D49A6B9B57B409C7D68DB5FB328C8447060B8708E2E9AE6A" +
+			"CF754BDBC9CC8D351AFE0652B5E1A520D5F9B2BDF16BD4D427417B8A63B7E4E9" +
+			"7B95EBF3369D7A1A22F75F615D6CF82CBF957C62DDF06C28C11A477DC3160023" +
+			"AE3C9C191F2A20D2583E3733A597284226D9B921BC7025339253ACFC935A88B6" +
+			"CCB6F961EE89A154DFEF3D107027E1DB58ACDA1028FB91C1A44811AEA8DEE3C6" +
+			"75CCEF451F83E5AB2A95EC286B865E9F7F24F66656DD2E188273FF5CE3B5CDC7" +
+			"6F2CE93700BF1320D633FE81124F5A7B71E43597D0D8A71009A5D3A800CA03BE" +
+			"9D04C9904E083609956C3F62FB8249A28098B3E5D282D47FFED5F6438A78872F" +
+			"047F5EFD07A4D3922A04DB141B2DA9FAEE72FEB7E678287D68C951D6927E4973" +
+			"60A93A68C278EF17CADB20AC9733C8A181A328DA8BF4B19E9ADBCF56A48A510C" +
+			"CB11E61EEB4F202EA114584B1B4EFE65652D4BFFCEB239199AC5DCE717D8136A" +
+			"E2E9C14119FB21CD2B9AEF74E81804778B02FBF231DDB10A4241B7709ABD84E5" +
+			"8DFA7AC63768C840582D9547148C246049DFD82BC1599A8701628472114762AB" +
+			"A9B7D9BB4463E2D8316F56072DC05D82A11BE88455698CFA88645DCF419BE4AD" +
+			"224F195DABE49B7DC427C2BBD339698BD54636B4AEF31C8631FAD72E138C4082" +
+			"6730C9B87A085A2EFE02898656FEAE71DBE53FE5DF5E164EA04A1AC31829B755",
+		Y: "5C914498F51175CEDDE869E19EE334EBEBA98E6D34372C0BD7F239640DA3443D" +
+			"17203ABAABD75431F79FAA296933A75E77D3E41C667D3ECBDA84E7C0DC646178" +
+			"9D741C145674CCF0D579BCB96F0992EA74259DB55D3BAE177527A06063EE2759" +
+			"5C3C993D4384884F140F38F74150A1CDE7CD50D8081EE6E1687DF3A68F26ED44" +
+			"AD0665D4C3A0486B2CA985F00DB690AF5FB1D1E3379969A3CD17F6BA5AD2EEBB" +
+			"B4E568E4B4995D898AB4A93DF55F9C6C2DC8B0D961F3CCA5FEC43CBC8E161D7D" +
+			"941BEC8A9F237029F10B6BA817347DA97D7EE3B71B100E736E40DE9A38D35F48" +
+			"8B77BD2EBBFD358A1F342056B80ABC361F141F0383D8342E2CD756971576BEFF" +
+			"0ECC5AAD5B052FD83AB826B8CE2C7C4A70FE729AD7684B80DB865B27D41AB6FB" +
+			"CD0E97D48BE85425A943C8B12267F5E11A01D4222243432D553BA18CB815D7D9" +
+			"553BE15995E971329988EFD2B6807ABE31A3E04D37DCE82991CA6F15FB314EF3" +
+			"697D1598E2150447D7BBF281F645FC015DADCE8D168B10CDF551DA077D726D4E" +
+			"50444EF71B88095A9C14DBB35548EE61A7F4004AA97E1D0EA4FB4F260D0D1A28" +
+			"DB15A952B06CC12CDE36549B6A391E0F03FEE18EE51513E5FA5D99E27B930184" +
+			"26AB17B35C2639810A18021FD15BEC67241993EC805C097C9C50EA696B9D8514" +
+			"9F64C8B5B6C8B6B640AA227705418C8A2C52EC5B2E2838B01ACFDB9ACA46A892",
+		Expected: "EAAAC2147F5D27FFA113BD8BB40A69C787A056DC32D7892E4C1C2B2215335EC5" +
+			"176778AA7BC8B26B214731AD488547DE9721BAEE8CC79BFF718E8F1C01A9C10B" +
+			"7A10995743201E7B0D2C8E2BCBD17416BE4F94B5FFBDD4D2C382138ADD3015D7" +
+			"EB763D4E4CBE753D7AAB30337FEF8BFD415A6187BD8E3D6FCE5FA7D0C3AC7F00" +
+			"37968EDDE528B9C67D4C0696BF4FEECC2A4BDFD67029B8F5F34A65490E692CA6" +
+			"F7C5CFE4273C1AF84270ACE31D34FE487E7E89E92D9126E2333D01ABDF5C4819" +
+			"D73743032A7A2780C357A69CD7AF4507A06D08411F98552E973360A6210E2D1A" +
+			"E5400A0328BE16016B56EF4E651101241EB2B4BE120AF6678F7F98051F0500A3" +
+			"FDE5BAEFBF0379C4E5C167E830DED9B424F022ABE69E63FDD6DA806465C231A0" +
+			"AB5D913CF2461DF19C58D937C40D6F8DB735C3D49118CAB50818C858D730661E" +
+			"429A2C932DDB20808762824EA68AC859865AE3EF8528E9082B541883ED3055A9" +
+			"5199475AF4AC9515C22E579F4FE06F2278B2F443C134BB7EBC31524904ECA890" +
+			"D5952E53D330BD5E1919941D95E8D73471F4842616FCFF0D70C057A3D3B5B666" +
+			"7CABCBC2D003A889A424ABDF73EFE2973EDF355C63F06F5307D3FEFF60333F79" +
+			"C688D2460B70DBE03CC8739E013912401F512EF73BDD6E4D1FAC040B74AF1617" +
+			"32B70342E07A37220854407EEB82B6F16507608823EB117C3527C037AA319DD9",
+	},
+	{
+		Name: "p-1 to an odd power",
+		Bits: 4096,
+		X: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+			"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+			"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+			"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+			"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+			"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+			"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+			"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+			"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFE",
+		Y: "3",
+		Expected: "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+			"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+			"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+			"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+			"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+			"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+			"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+			"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+			"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFE",
+	},
+}
@@ -0,0 +1,77 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// Package testvectors makes batches of operands and the results the ops
+// should give for them, computed on the CPU with the same group code the
+// server uses. The same seed always gives the same vectors, so unit tests,
+// fuzzing, stress runs and comparisons between backends can all share them
+// and reproduce each other's failures.
+package testvectors
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"math/rand"
+)
+
+// Vectors are the operands and expected results of one op for a batch of
+// slots
+type Vectors struct {
+	Group *cyclic.Group
+	X     *cyclic.IntBuffer
+	Y     *cyclic.IntBuffer
+	// Only set for ops with a third operand, like Mul3
+	Z        *cyclic.IntBuffer
+	Expected *cyclic.IntBuffer
+}
+
+// Exp returns numSlots vectors for exponentiation: Expected[i] = X[i]**Y[i]
+// mod p. X are in the group and Y are exponents in [1, p-1).
+func Exp(seed int64, g *cyclic.Group, numSlots uint32) Vectors {
+	v := generate(seed, g, numSlots)
+	for i := uint32(0); i < numSlots; i++ {
+		g.Exp(v.X.Get(i), v.Y.Get(i), v.Expected.Get(i))
+	}
+	return v
+}
+
+// Mul2 returns numSlots vectors for multiplication: Expected[i] =
+// X[i]*Y[i] mod p. X and Y are both in the group.
+func Mul2(seed int64, g *cyclic.Group, numSlots uint32) Vectors {
+	v := generate(seed, g, numSlots)
+	for i := uint32(0); i < numSlots; i++ {
+		g.Mul(v.X.Get(i), v.Y.Get(i), v.Expected.Get(i))
+	}
+	return v
+}
+
+// generate fills X and Y with values in [1, p-1) from a generator seeded with
+// seed
+func generate(seed int64, g *cyclic.Group, numSlots uint32) Vectors {
+	rng := rand.New(rand.NewSource(seed))
+	v := Vectors{
+		Group:    g,
+		X:        g.NewIntBuffer(numSlots, g.NewInt(1)),
+		Y:        g.NewIntBuffer(numSlots, g.NewInt(1)),
+		Expected: g.NewIntBuffer(numSlots, g.NewInt(1)),
+	}
+	// Extra random bytes make the bias from reducing mod p-2 negligible
+	randomBytes := make([]byte, len(g.GetPBytes())+8)
+	pSub2 := large.NewInt(0).Sub(g.GetP(), large.NewInt(2))
+	one := large.NewInt(1)
+	for _, buf := range []*cyclic.IntBuffer{v.X, v.Y} {
+		for i := uint32(0); i < numSlots; i++ {
+			// Never fails
+			rng.Read(randomBytes)
+			value := large.NewIntFromBytes(randomBytes)
+			value.Mod(value, pSub2)
+			value.Add(value, one)
+			g.SetBits(buf.Get(i), value.Bits())
+		}
+	}
+	return v
+}
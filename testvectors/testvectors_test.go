@@ -0,0 +1,85 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package testvectors
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+func testGroup() *cyclic.Group {
+	// 2**127 - 1
+	p := large.NewInt(1).LeftShift(large.NewInt(1), 127)
+	p.Sub(p, large.NewInt(1))
+	return cyclic.NewGroup(p, large.NewInt(3))
+}
+
+// The same seed should give the same vectors, and different seeds different
+// ones
+func TestExp_Deterministic(t *testing.T) {
+	g := testGroup()
+	a := Exp(42, g, 16)
+	b := Exp(42, g, 16)
+	c := Exp(43, g, 16)
+	same := 0
+	for i := uint32(0); i < 16; i++ {
+		if a.X.Get(i).Cmp(b.X.Get(i)) != 0 || a.Y.Get(i).Cmp(b.Y.Get(i)) != 0 ||
+			a.Expected.Get(i).Cmp(b.Expected.Get(i)) != 0 {
+			t.Errorf("Slot %v differed between runs with the same seed", i)
+		}
+		if a.X.Get(i).Cmp(c.X.Get(i)) == 0 {
+			same++
+		}
+	}
+	if same == 16 {
+		t.Error("Different seeds gave the same vectors")
+	}
+}
+
+// Operands should be in the group, and expected results should be right
+func TestVectors(t *testing.T) {
+	g := testGroup()
+	exp := Exp(1, g, 32)
+	mul := Mul2(1, g, 32)
+	for i := uint32(0); i < 32; i++ {
+		for _, v := range []*cyclic.Int{exp.X.Get(i), exp.Y.Get(i),
+			mul.X.Get(i), mul.Y.Get(i)} {
+			if !g.Inside(v.GetLargeInt()) {
+				t.Errorf("Slot %v had %v, which isn't in the group", i,
+					v.Text(16))
+			}
+		}
+		expected := large.NewInt(0).Exp(exp.X.Get(i).GetLargeInt(),
+			exp.Y.Get(i).GetLargeInt(), g.GetP())
+		if exp.Expected.Get(i).GetLargeInt().Cmp(expected) != 0 {
+			t.Errorf("Wrong exp result in slot %v", i)
+		}
+		expected.Mul(mul.X.Get(i).GetLargeInt(), mul.Y.Get(i).GetLargeInt())
+		expected.Mod(expected, g.GetP())
+		if mul.Expected.Get(i).GetLargeInt().Cmp(expected) != 0 {
+			t.Errorf("Wrong mul2 result in slot %v", i)
+		}
+	}
+}
+
+// The known answers should hold for the CPU's group code
+func TestKnownAnswers(t *testing.T) {
+	if len(KnownAnswers) != 12 {
+		t.Errorf("Expected 4 known answers for each of 3 groups, got %v",
+			len(KnownAnswers))
+	}
+	for _, ka := range KnownAnswers {
+		v := ka.Load()
+		z := v.Group.NewInt(1)
+		v.Group.Exp(v.X.Get(0), v.Y.Get(0), z)
+		if z.Cmp(v.Expected.Get(0)) != 0 {
+			t.Errorf("%v bit %v: got %v", ka.Bits, ka.Name, z.Text(16))
+		}
+	}
+}
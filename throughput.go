@@ -0,0 +1,93 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"time"
+)
+
+// throughput.go keeps track of how quickly each op has been running on the
+// GPU and on the CPU, so that work can be split between them in proportion,
+// and of the chunk sizes below which the CPU is faster outright.
+
+// How much weight the newest measurement gets in the running average
+const throughputWeight = 0.2
+
+// throughputs holds running averages of slots per second for each op
+type throughputs struct {
+	sync.Mutex
+	gpu map[string]float64
+	cpu map[string]float64
+	// Smallest chunk of each op that's faster on the GPU than on the CPU
+	crossovers map[string]uint32
+}
+
+func newThroughputs() *throughputs {
+	return &throughputs{
+		gpu:        make(map[string]float64),
+		cpu:        make(map[string]float64),
+		crossovers: make(map[string]uint32),
+	}
+}
+
+// record adds a measurement of running numSlots slots of op in elapsed time
+func (t *throughputs) record(op string, onGPU bool, numSlots uint32,
+	elapsed time.Duration) {
+	if numSlots == 0 || elapsed <= 0 {
+		return
+	}
+	rate := float64(numSlots) / elapsed.Seconds()
+	t.Lock()
+	defer t.Unlock()
+	rates := t.cpu
+	if onGPU {
+		rates = t.gpu
+	}
+	if rates[op] == 0 {
+		rates[op] = rate
+	} else {
+		rates[op] = (1-throughputWeight)*rates[op] + throughputWeight*rate
+	}
+}
+
+// cpuSlots returns how many of numSlots slots of op should run on the CPU
+// so that both sides finish at about the same time. Until both sides have
+// been measured, the CPU gets a single slot so that it gets measured too.
+func (t *throughputs) cpuSlots(op string, numSlots uint32) uint32 {
+	if numSlots == 0 {
+		return 0
+	}
+	t.Lock()
+	gpuRate, cpuRate := t.gpu[op], t.cpu[op]
+	t.Unlock()
+	if gpuRate == 0 || cpuRate == 0 {
+		return 1
+	}
+	return uint32(float64(numSlots) * cpuRate / (cpuRate + gpuRate))
+}
+
+// setCrossover sets the smallest chunk of op that should run on the GPU.
+// Zero sends every chunk to the GPU.
+func (t *throughputs) setCrossover(op string, numSlots uint32) {
+	t.Lock()
+	defer t.Unlock()
+	if numSlots == 0 {
+		delete(t.crossovers, op)
+	} else {
+		t.crossovers[op] = numSlots
+	}
+}
+
+// belowCrossover returns whether a chunk of numSlots slots of op is small
+// enough to run faster on the CPU alone
+func (t *throughputs) belowCrossover(op string, numSlots uint32) bool {
+	t.Lock()
+	defer t.Unlock()
+	return numSlots < t.crossovers[op]
+}
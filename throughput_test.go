@@ -0,0 +1,58 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"testing"
+	"time"
+)
+
+// Before both sides are measured, the CPU should get one slot to measure
+func TestThroughputs_CpuSlotsUnmeasured(t *testing.T) {
+	tp := newThroughputs()
+	if tp.cpuSlots("ExpChunk", 100) != 1 {
+		t.Error("Unmeasured CPU should get one slot")
+	}
+	tp.record("ExpChunk", true, 100, time.Second)
+	if tp.cpuSlots("ExpChunk", 100) != 1 {
+		t.Error("Unmeasured CPU should get one slot")
+	}
+	if tp.cpuSlots("ExpChunk", 0) != 0 {
+		t.Error("Empty chunk shouldn't have any CPU slots")
+	}
+}
+
+// Slots should be split in proportion to throughput
+func TestThroughputs_CpuSlots(t *testing.T) {
+	tp := newThroughputs()
+	tp.record("ExpChunk", true, 300, time.Second)
+	tp.record("ExpChunk", false, 100, time.Second)
+	if tp.cpuSlots("ExpChunk", 1000) != 250 {
+		t.Errorf("CPU should get a quarter of the slots, but got %v",
+			tp.cpuSlots("ExpChunk", 1000))
+	}
+	// Other ops should be unaffected
+	if tp.cpuSlots("Mul2Chunk", 1000) != 1 {
+		t.Error("Unmeasured op should give the CPU one slot")
+	}
+}
+
+// New measurements should move the average towards them
+func TestThroughputs_Record(t *testing.T) {
+	tp := newThroughputs()
+	tp.record("ExpChunk", false, 100, time.Second)
+	tp.record("ExpChunk", false, 200, time.Second)
+	rate := tp.cpu["ExpChunk"]
+	if rate <= 100 || rate >= 200 {
+		t.Errorf("Average rate %v should be between the measurements", rate)
+	}
+	tp.record("ExpChunk", false, 0, time.Second)
+	if tp.cpu["ExpChunk"] != rate {
+		t.Error("Empty measurement shouldn't change the average")
+	}
+}
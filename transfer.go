@@ -0,0 +1,68 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync"
+	"time"
+)
+
+// transfer.go adds up how much data each kernel moves over PCIe. The library
+// uploads, runs and downloads a batch in one call, so the time here includes
+// the kernel itself, and the bandwidth is what the batches got overall. If it
+// comes close to what the bus can do, the node is transfer bound; if it's far
+// below, the GPU's arithmetic is what's holding it up.
+
+// TransferStat is the data moved by a kernel's successful batches
+type TransferStat struct {
+	Batches         uint64
+	BytesUploaded   uint64
+	BytesDownloaded uint64
+	// Total time from the start of each upload to the end of its download
+	Elapsed time.Duration
+}
+
+// Bandwidth returns the bytes moved in both directions per second of batch
+// time
+func (ts TransferStat) Bandwidth() float64 {
+	if ts.Elapsed <= 0 {
+		return 0
+	}
+	return float64(ts.BytesUploaded+ts.BytesDownloaded) / ts.Elapsed.Seconds()
+}
+
+var transfers struct {
+	sync.Mutex
+	stats map[string]TransferStat
+}
+
+// TransferStats returns the data moved so far by each kernel, by the
+// kernel's name in Job
+func TransferStats() map[string]TransferStat {
+	transfers.Lock()
+	defer transfers.Unlock()
+	stats := make(map[string]TransferStat, len(transfers.stats))
+	for kernel, stat := range transfers.stats {
+		stats[kernel] = stat
+	}
+	return stats
+}
+
+func recordTransfer(job Job, elapsed time.Duration) {
+	transfers.Lock()
+	defer transfers.Unlock()
+	if transfers.stats == nil {
+		transfers.stats = make(map[string]TransferStat)
+	}
+	stat := transfers.stats[job.Kernel]
+	stat.Batches++
+	stat.BytesUploaded += uint64(job.UploadBytes)
+	stat.BytesDownloaded += uint64(job.DownloadBytes)
+	stat.Elapsed += elapsed
+	transfers.stats[job.Kernel] = stat
+}
@@ -0,0 +1,39 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"testing"
+	"time"
+)
+
+// Batches should add up by kernel, and bandwidth should be the bytes in both
+// directions over the time taken
+func TestTransferStats(t *testing.T) {
+	// A kernel name no other test uses, since the totals are global
+	job := Job{Kernel: "transfer test", UploadBytes: 3000, DownloadBytes: 1000}
+	recordTransfer(job, time.Millisecond)
+	recordTransfer(job, time.Millisecond)
+
+	stat := TransferStats()[job.Kernel]
+	expected := TransferStat{
+		Batches:         2,
+		BytesUploaded:   6000,
+		BytesDownloaded: 2000,
+		Elapsed:         2 * time.Millisecond,
+	}
+	if stat != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stat)
+	}
+	if bw := stat.Bandwidth(); bw != 4e6 {
+		t.Errorf("Expected 4MB/s, got %v", bw)
+	}
+	if (TransferStat{}).Bandwidth() != 0 {
+		t.Error("No batches should mean no bandwidth")
+	}
+}
@@ -0,0 +1,194 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tuning.go saves what a pool has learned about how fast each op runs, so
+// that a node that restarts can carry on from there instead of learning it
+// again over its first rounds.
+
+// Tuning is what a pool has learned about each op, keyed by op name
+type Tuning struct {
+	// The target latency that BatchLimits were learned for. They're only
+	// restored into a pool with the same target.
+	TargetLatency time.Duration     `json:"targetLatency"`
+	BatchLimits   map[string]uint32 `json:"batchLimits,omitempty"`
+	// Slots per second on the GPU and the CPU, for splitting chunks
+	GPURates map[string]float64 `json:"gpuRates,omitempty"`
+	CPURates map[string]float64 `json:"cpuRates,omitempty"`
+	// Chunks with fewer slots than this run on the CPU alone
+	Crossovers map[string]uint32 `json:"crossovers,omitempty"`
+	// What Estimate has learned about each op's batches
+	Estimates map[string]EstimateModel `json:"estimates,omitempty"`
+}
+
+// EstimateModel is the weighted sums that Estimate fits batch times to
+type EstimateModel struct {
+	Weight       float64 `json:"weight"`
+	Slots        float64 `json:"slots"`
+	SlotsSquared float64 `json:"slotsSquared"`
+	Seconds      float64 `json:"seconds"`
+	SlotSeconds  float64 `json:"slotSeconds"`
+	MaxBatch     uint32  `json:"maxBatch"`
+}
+
+// SaveTuning writes tuning to path as JSON. The file is replaced in one
+// step, so a crash while saving leaves the old file as it was.
+func SaveTuning(path string, tuning Tuning) error {
+	data, err := json.MarshalIndent(tuning, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode tuning")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return errors.Wrap(err, "couldn't save tuning")
+	}
+	_, err = tmp.Write(data)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "couldn't save tuning")
+	}
+	return nil
+}
+
+// LoadTuning reads tuning that SaveTuning wrote to path
+func LoadTuning(path string) (Tuning, error) {
+	var tuning Tuning
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return tuning, err
+	}
+	err = json.Unmarshal(data, &tuning)
+	if err != nil {
+		return Tuning{}, errors.Wrapf(err, "couldn't read tuning from %v",
+			path)
+	}
+	return tuning, nil
+}
+
+// getLimits returns a copy of the batch sizes the governor has learned
+func (gv *governor) getLimits() map[string]uint32 {
+	gv.Lock()
+	defer gv.Unlock()
+	limits := make(map[string]uint32, len(gv.limits))
+	for op, limit := range gv.limits {
+		limits[op] = limit
+	}
+	return limits
+}
+
+// setLimits replaces the learned batch sizes, if they were learned for the
+// governor's target
+func (gv *governor) setLimits(target time.Duration, limits map[string]uint32) {
+	gv.Lock()
+	defer gv.Unlock()
+	if target != gv.target {
+		return
+	}
+	gv.limits = make(map[string]uint32, len(limits))
+	for op, limit := range limits {
+		gv.limits[op] = limit
+	}
+}
+
+// getRates returns copies of the GPU and CPU rates
+func (t *throughputs) getRates() (gpu, cpu map[string]float64) {
+	t.Lock()
+	defer t.Unlock()
+	return copyRates(t.gpu), copyRates(t.cpu)
+}
+
+// setRates replaces the GPU and CPU rates
+func (t *throughputs) setRates(gpu, cpu map[string]float64) {
+	t.Lock()
+	defer t.Unlock()
+	t.gpu, t.cpu = copyRates(gpu), copyRates(cpu)
+}
+
+// getCrossovers returns a copy of the crossover chunk sizes
+func (t *throughputs) getCrossovers() map[string]uint32 {
+	t.Lock()
+	defer t.Unlock()
+	crossovers := make(map[string]uint32, len(t.crossovers))
+	for op, numSlots := range t.crossovers {
+		crossovers[op] = numSlots
+	}
+	return crossovers
+}
+
+// setCrossovers replaces the crossover chunk sizes
+func (t *throughputs) setCrossovers(crossovers map[string]uint32) {
+	t.Lock()
+	defer t.Unlock()
+	t.crossovers = make(map[string]uint32, len(crossovers))
+	for op, numSlots := range crossovers {
+		t.crossovers[op] = numSlots
+	}
+}
+
+func copyRates(rates map[string]float64) map[string]float64 {
+	c := make(map[string]float64, len(rates))
+	for op, rate := range rates {
+		c[op] = rate
+	}
+	return c
+}
+
+// getModels returns a copy of each op's model
+func (e *estimator) getModels() map[string]EstimateModel {
+	e.Lock()
+	defer e.Unlock()
+	models := make(map[string]EstimateModel, len(e.models))
+	for op, m := range e.models {
+		models[op] = EstimateModel{
+			Weight:       m.w,
+			Slots:        m.n,
+			SlotsSquared: m.nn,
+			Seconds:      m.t,
+			SlotSeconds:  m.nt,
+			MaxBatch:     m.maxBatch,
+		}
+	}
+	return models
+}
+
+// setModels replaces the models. Models without any batches in them are
+// left out, as estimate would divide by zero.
+func (e *estimator) setModels(models map[string]EstimateModel) {
+	e.Lock()
+	defer e.Unlock()
+	e.models = make(map[string]*batchModel, len(models))
+	for op, m := range models {
+		if m.Weight <= 0 || m.Slots <= 0 || m.MaxBatch == 0 {
+			continue
+		}
+		e.models[op] = &batchModel{
+			w:        m.Weight,
+			n:        m.Slots,
+			nn:       m.SlotsSquared,
+			t:        m.Seconds,
+			nt:       m.SlotSeconds,
+			maxBatch: m.MaxBatch,
+		}
+	}
+}
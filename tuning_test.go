@@ -0,0 +1,101 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// What's learned should come back the same after being saved and loaded
+func TestSaveTuning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpumaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuning.json")
+	_, err = LoadTuning(path)
+	if !os.IsNotExist(err) {
+		t.Errorf("Loading a missing file should say it's missing, got %v", err)
+	}
+
+	gv := newGovernor()
+	gv.setTarget(10 * time.Millisecond)
+	gv.record("ExpChunk", 1000, 20*time.Millisecond)
+	tp := newThroughputs()
+	tp.record("ExpChunk", true, 1000, time.Second)
+	tp.record("ExpChunk", false, 10, time.Second)
+	tp.setCrossover("Mul2Chunk", 64)
+	e := newEstimator()
+	e.record("ExpChunk", 100, 20*time.Millisecond)
+	e.record("ExpChunk", 200, 30*time.Millisecond)
+	gpuRates, cpuRates := tp.getRates()
+	tuning := Tuning{
+		TargetLatency: gv.getTarget(),
+		BatchLimits:   gv.getLimits(),
+		GPURates:      gpuRates,
+		CPURates:      cpuRates,
+		Crossovers:    tp.getCrossovers(),
+		Estimates:     e.getModels(),
+	}
+	err = SaveTuning(path, tuning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTuning(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, tuning) {
+		t.Errorf("Loaded %+v, saved %+v", loaded, tuning)
+	}
+
+	restored := newEstimator()
+	restored.setModels(loaded.Estimates)
+	if restored.estimate("ExpChunk", 5000) != e.estimate("ExpChunk", 5000) {
+		t.Error("Restored estimator gives a different estimate")
+	}
+	restoredTp := newThroughputs()
+	restoredTp.setCrossovers(loaded.Crossovers)
+	if !restoredTp.belowCrossover("Mul2Chunk", 63) ||
+		restoredTp.belowCrossover("Mul2Chunk", 64) {
+		t.Error("Restored crossover doesn't match the saved one")
+	}
+	restoredGv := newGovernor()
+	restoredGv.setLimits(loaded.TargetLatency, loaded.BatchLimits)
+	if len(restoredGv.getLimits()) != 0 {
+		t.Error("Limits for a different target shouldn't be restored")
+	}
+	restoredGv.setTarget(loaded.TargetLatency)
+	restoredGv.setLimits(loaded.TargetLatency, loaded.BatchLimits)
+	if restoredGv.batchSize("ExpChunk", 1000) != gv.batchSize("ExpChunk", 1000) {
+		t.Error("Restored governor gives a different batch size")
+	}
+}
+
+func TestLoadTuning_Corrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpumaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuning.json")
+	err = ioutil.WriteFile(path, []byte("{\"gpuRates\": "), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadTuning(path)
+	if err == nil || os.IsNotExist(err) {
+		t.Errorf("Expected an error reading a truncated file, got %v", err)
+	}
+}
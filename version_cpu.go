@@ -0,0 +1,16 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+// cudaVersions has nothing to look at in the stubbed build, which fails to
+// create a pool anyway
+func cudaVersions() (driver, runtime int, err error) {
+	return 0, 0, nil
+}
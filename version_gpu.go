@@ -0,0 +1,84 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+// version_gpu.go finds the CUDA versions that Init's preflight compares.
+// Both functions are looked up at run time rather than linked, so that a
+// machine without the driver's library still gets as far as the preflight
+// and can be told what's missing.
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+
+// cuDriverGetVersion and cudaRuntimeGetVersion both take an int pointer and
+// return 0 on success
+typedef int (*getVersion)(int *version);
+
+static int callGetVersion(void *f, int *version) {
+	return ((getVersion)f)(version);
+}
+*/
+import "C"
+import (
+	"github.com/pkg/errors"
+	"unsafe"
+)
+
+// The driver's library, which the container runtime mounts from the host
+const driverLibrary = "libcuda.so.1"
+
+// cudaVersions returns the newest CUDA version that the installed driver
+// supports, and the version of the CUDA runtime that the kernel library
+// loaded, both as 1000*major + 10*minor. runtime is 0 if the library's
+// runtime doesn't export its version, as a statically linked one may not.
+func cudaVersions() (driver, runtime int, err error) {
+	name := C.CString(driverLibrary)
+	defer C.free(unsafe.Pointer(name))
+	lib := C.dlopen(name, C.RTLD_NOW|C.RTLD_LOCAL)
+	if lib == nil {
+		return 0, 0, errors.Errorf("can't load %v: %v. If this is a "+
+			"container, run it with the NVIDIA runtime so the driver's "+
+			"libraries are mounted", driverLibrary, C.GoString(C.dlerror()))
+	}
+	defer C.dlclose(lib)
+	driver, err = callVersion(lib, "cuDriverGetVersion")
+	if err != nil {
+		return 0, 0, err
+	}
+	// A nil name opens the program itself, along with the kernel library
+	// and the runtime it was linked with
+	self := C.dlopen(nil, C.RTLD_NOW)
+	if self != nil {
+		defer C.dlclose(self)
+		runtime, err = callVersion(self, "cudaRuntimeGetVersion")
+		if err != nil {
+			runtime = 0
+		}
+	}
+	return driver, runtime, nil
+}
+
+// callVersion calls the version function called name in lib
+func callVersion(lib unsafe.Pointer, name string) (int, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	f := C.dlsym(lib, cName)
+	if f == nil {
+		return 0, errors.Errorf("%v isn't available", name)
+	}
+	var version C.int
+	result := C.callGetVersion(f, &version)
+	if result != 0 {
+		return 0, errors.Errorf("%v failed with error %v", name, result)
+	}
+	return int(version), nil
+}
@@ -0,0 +1,95 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"time"
+)
+
+// warmup_gpu.go runs every kernel once on every stream before any real work
+// is done. The first launch of a kernel pays for loading its module onto the
+// device, and the first batches after the GPU has been idle run while the
+// clocks are still ramping up, which shows up as a latency spike in the
+// first round.
+
+// WarmUp runs a single slot of each kernel at each bit length on every
+// stream in the pool, waiting for any streams that are in use to become
+// free. Call it after creating the pool and before the first round.
+// The slots use a tiny group, so no real values pass through the streams.
+func (sm *StreamPool) WarmUp() error {
+	start := time.Now()
+	// 5 generates the group mod 23, and 3 is coprime to 22 so it can be
+	// used as the key for reveal
+	g := cyclic.NewGroup(large.NewInt(23), large.NewInt(5))
+	one := func() *cyclic.IntBuffer {
+		return g.NewIntBuffer(1, g.NewInt(2))
+	}
+	key := g.NewInt(3)
+	kernels := []struct {
+		kernel int
+		name   string
+		run    func(env gpumathsEnv, s Stream) chan error
+	}{
+		{int(kernelPowmOdd), kernelLayouts[kernelPowmOdd].name, func(env gpumathsEnv, s Stream) chan error {
+			return exp(g, one(), one(), one(), env, s)
+		}},
+		{int(kernelElgamal), kernelLayouts[kernelElgamal].name, func(env gpumathsEnv, s Stream) chan error {
+			return elGamal(g, one(), one(), key, one(), one(), env, s)
+		}},
+		{int(kernelReveal), kernelLayouts[kernelReveal].name, func(env gpumathsEnv, s Stream) chan error {
+			return reveal(g, key, one(), one(), env, s)
+		}},
+		{int(kernelMul2), kernelLayouts[kernelMul2].name, func(env gpumathsEnv, s Stream) chan error {
+			return mul2(g, one(), one(), one(), env, s)
+		}},
+		{int(kernelMul3), kernelLayouts[kernelMul3].name, func(env gpumathsEnv, s Stream) chan error {
+			return mul3(g, one(), one(), one(), one(), env, s)
+		}},
+	}
+
+	// The pool mustn't shrink while every stream is being taken
+	sm.resizeLock.Lock()
+	defer sm.resizeLock.Unlock()
+	numInUse := len(sm.streams) - sm.DisabledStreams()
+	streams := make([]Stream, 0, numInUse)
+	for i := 0; i < numInUse; i++ {
+		streams = append(streams, sm.TakeStream())
+	}
+	defer func() {
+		for _, s := range streams {
+			s.zero()
+			sm.ReturnStream(s)
+		}
+	}()
+	for _, s := range streams {
+		for _, env := range allEnvs {
+			for _, k := range kernels {
+				// Streams that are too small for a kernel will never run
+				// it, and neither will a library that was built without it
+				if !hasKernel(env, k.kernel) ||
+					env.streamSizeContaining(1, k.kernel) > sm.memSize {
+					continue
+				}
+				err := <-k.run(env, s)
+				if err != nil {
+					return errors.Wrapf(err, "couldn't warm up %v bit %v "+
+						"kernel", env.getBitLen(), k.name)
+				}
+			}
+		}
+	}
+	jww.INFO.Printf("Warmed up %v streams in %v", len(streams),
+		time.Since(start))
+	return nil
+}
@@ -0,0 +1,179 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"hash/crc32"
+)
+
+// wire.go defines how a batch of operands or results is framed when it's
+// sent to or from a remote GPU service. A frame is:
+//
+//   length    uint32  bytes in the rest of the frame, including the checksum
+//   version   uint8   wireVersion
+//   op        uint8   a WireOp
+//   bitLen    uint16  bit length of the group the batch is in
+//   operands  uint8   number of buffers in the batch
+//   slots     uint32  number of slots in each buffer
+//   values            for each buffer, for each slot: a uvarint byte count
+//                     followed by that many big-endian bytes
+//   checksum  uint32  CRC-32C of everything from version to the last value
+//
+// All fixed size fields are big-endian. Values are written without their
+// leading zero bytes, so operands that are mostly small, like the ones in the
+// first phases of a round, take far less space than a full bignum per slot.
+
+// wireVersion is the version of the frame format this package writes
+const wireVersion = 1
+
+// wireHeaderLen is the number of bytes from version to slots
+const wireHeaderLen = 1 + 1 + 2 + 1 + 4
+
+// WireOp says which operation a batch is for
+type WireOp uint8
+
+const (
+	WireExp WireOp = iota + 1
+	WireElGamal
+	WireReveal
+	WireMul2
+	WireMul3
+)
+
+var wireChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// MarshalBatch frames operands, which must all have the same length, for
+// sending to or from a remote GPU service
+func MarshalBatch(op WireOp, bitLen uint32,
+	operands ...*cyclic.IntBuffer) ([]byte, error) {
+	if bitLen > 0xffff || len(operands) > 0xff {
+		return nil, errors.Errorf("can't frame %v operands of %v bits",
+			len(operands), bitLen)
+	}
+	getters := make([]intGetter, len(operands))
+	for i := range operands {
+		getters[i] = operands[i]
+	}
+	err := checkLengths("MarshalBatch", getters...)
+	if err != nil {
+		return nil, err
+	}
+	numSlots := 0
+	if len(operands) > 0 {
+		numSlots = operands[0].Len()
+	}
+
+	frame := make([]byte, 4+wireHeaderLen,
+		4+wireHeaderLen+len(operands)*numSlots*(int(bitLen)/8+1)+4)
+	frame[4] = wireVersion
+	frame[5] = byte(op)
+	binary.BigEndian.PutUint16(frame[6:], uint16(bitLen))
+	frame[8] = byte(len(operands))
+	binary.BigEndian.PutUint32(frame[9:], uint32(numSlots))
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, operand := range operands {
+		for i := uint32(0); i < uint32(numSlots); i++ {
+			value := operand.Get(i).Bytes()
+			n := binary.PutUvarint(lenBuf[:], uint64(len(value)))
+			frame = append(frame, lenBuf[:n]...)
+			frame = append(frame, value...)
+		}
+	}
+	frame = append(frame, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(frame[len(frame)-4:],
+		crc32.Checksum(frame[4:len(frame)-4], wireChecksumTable))
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(frame)-4))
+	return frame, nil
+}
+
+// PeekBatch returns the op and bit length from a frame's header, so that the
+// receiver can pick the group to unmarshal it in. The rest of the frame isn't
+// checked until it's unmarshalled.
+func PeekBatch(frame []byte) (op WireOp, bitLen uint32, err error) {
+	if len(frame) < 4+wireHeaderLen+4 {
+		return 0, 0, errors.Errorf("frame of %v bytes is too short",
+			len(frame))
+	}
+	if frame[4] != wireVersion {
+		return 0, 0, errors.Errorf("frame version %v isn't supported",
+			frame[4])
+	}
+	return WireOp(frame[5]), uint32(binary.BigEndian.Uint16(frame[6:])), nil
+}
+
+// UnmarshalBatch reads a frame written by MarshalBatch into new buffers in
+// the group g. It returns an error if the frame is truncated, corrupted, from
+// a version it doesn't know, for a different bit length than g's prime, or
+// has values that aren't in g.
+func UnmarshalBatch(g *cyclic.Group, frame []byte) (op WireOp, bitLen uint32,
+	operands []*cyclic.IntBuffer, err error) {
+	if len(frame) < 4+wireHeaderLen+4 {
+		return 0, 0, nil, errors.Errorf("frame of %v bytes is too short",
+			len(frame))
+	}
+	length := binary.BigEndian.Uint32(frame[:4])
+	if uint64(length) != uint64(len(frame)-4) {
+		return 0, 0, nil, errors.Errorf("frame says it has %v bytes, but "+
+			"has %v", length, len(frame)-4)
+	}
+	body := frame[4 : len(frame)-4]
+	checksum := binary.BigEndian.Uint32(frame[len(frame)-4:])
+	if crc32.Checksum(body, wireChecksumTable) != checksum {
+		return 0, 0, nil, errors.New("frame checksum doesn't match")
+	}
+	if body[0] != wireVersion {
+		return 0, 0, nil, errors.Errorf("frame version %v isn't supported",
+			body[0])
+	}
+	op = WireOp(body[1])
+	bitLen = uint32(binary.BigEndian.Uint16(body[2:]))
+	if int(bitLen) != g.GetP().BitLen() {
+		return 0, 0, nil, errors.Errorf("frame is for a %v bit group, but "+
+			"the group has %v bits", bitLen, g.GetP().BitLen())
+	}
+	numOperands := int(body[4])
+	numSlots := binary.BigEndian.Uint32(body[5:])
+	maxBytes := uint64(bitLen+7) / 8
+
+	values := body[wireHeaderLen:]
+	operands = make([]*cyclic.IntBuffer, numOperands)
+	for o := range operands {
+		// Each slot takes at least a byte, so this can't allocate much more
+		// than the frame already holds
+		if uint64(numSlots) > uint64(len(values)) {
+			return 0, 0, nil, errors.Errorf("frame has %v slots, but only "+
+				"%v bytes left", numSlots, len(values))
+		}
+		operands[o] = g.NewIntBuffer(numSlots, g.NewInt(1))
+		for i := uint32(0); i < numSlots; i++ {
+			valueLen, n := binary.Uvarint(values)
+			if n <= 0 || valueLen > maxBytes ||
+				valueLen > uint64(len(values)-n) {
+				return 0, 0, nil, errors.Errorf("bad value for slot %v of "+
+					"operand %v", i, o)
+			}
+			values = values[n:]
+			value := g.SetBytes(operands[o].Get(i), values[:valueLen])
+			values = values[valueLen:]
+			// The frame comes from another process, and the ops assume
+			// their operands are in the group
+			if !g.Inside(value.GetLargeInt()) {
+				return 0, 0, nil, errors.Errorf("slot %v of operand %v isn't "+
+					"in the group", i, o)
+			}
+		}
+	}
+	if len(values) != 0 {
+		return 0, 0, nil, errors.Errorf("frame has %v bytes after its "+
+			"last value", len(values))
+	}
+	return op, bitLen, operands, nil
+}
@@ -0,0 +1,105 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"encoding/binary"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// A batch should come back the same as it went in
+func TestMarshalBatch(t *testing.T) {
+	g := makeTestGroup2048()
+	x := g.NewIntBuffer(3, g.NewInt(1))
+	g.Random(x.Get(0))
+	g.Random(x.Get(2))
+	y := g.NewIntBuffer(3, g.NewInt(1))
+
+	frame, err := MarshalBatch(WireMul2, 2048, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, bitLen, operands, err := UnmarshalBatch(g, frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != WireMul2 || bitLen != 2048 || len(operands) != 2 {
+		t.Fatalf("Got op %v, %v bits, and %v operands", op, bitLen,
+			len(operands))
+	}
+	op, bitLen, err = PeekBatch(frame)
+	if err != nil || op != WireMul2 || bitLen != 2048 {
+		t.Errorf("Peeking got op %v and %v bits, error %v", op, bitLen, err)
+	}
+	for o, expected := range []intGetter{x, y} {
+		for i := uint32(0); i < 3; i++ {
+			if operands[o].Get(i).Cmp(expected.Get(i)) != 0 {
+				t.Errorf("Slot %v of operand %v was %v, not %v", i, o,
+					operands[o].Get(i).Text(16), expected.Get(i).Text(16))
+			}
+		}
+	}
+	// The ones should only take a few bytes between them
+	if len(frame) > 4+wireHeaderLen+2*(2+256)+2+3*2+4 {
+		t.Errorf("Small values weren't compacted: frame is %v bytes",
+			len(frame))
+	}
+}
+
+// Damaged, truncated, or mismatched frames should be rejected
+func TestUnmarshalBatch_Errors(t *testing.T) {
+	g := makeTestGroup2048()
+	x := g.NewIntBuffer(2, g.NewInt(7))
+	frame, err := MarshalBatch(WireExp, 2048, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, frame...)
+	corrupt[len(corrupt)-5] ^= 1
+	truncated := append([]byte{}, frame[:len(frame)-1]...)
+	binary.BigEndian.PutUint32(truncated, uint32(len(truncated)-4))
+	futureVersion := append([]byte{}, frame...)
+	futureVersion[4] = wireVersion + 1
+	otherBitLen, err := MarshalBatch(WireExp, 3200, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Values outside the group can't be made with NewInt, but can still be
+	// sent by a remote service
+	outside := g.NewIntBuffer(2, g.NewInt(1))
+	g.OverwriteBits(outside.Get(1), g.GetP().Bits())
+	tooBig, err := MarshalBatch(WireExp, 2048, outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.OverwriteBits(outside.Get(1), large.Bits{})
+	zero, err := MarshalBatch(WireExp, 2048, outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, bad := range map[string][]byte{
+		"corrupt":    corrupt,
+		"truncated":  truncated,
+		"short":      frame[:8],
+		"version":    futureVersion,
+		"bit length": otherBitLen,
+		"too big":    tooBig,
+		"zero":       zero,
+	} {
+		if _, _, _, err := UnmarshalBatch(g, bad); err == nil {
+			t.Errorf("%v frame should have been rejected", name)
+		}
+	}
+
+	if _, err := MarshalBatch(WireMul2, 2048, x,
+		g.NewIntBuffer(3, g.NewInt(1))); err == nil {
+		t.Error("Operands of different lengths should have been rejected")
+	}
+}